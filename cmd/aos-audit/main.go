@@ -0,0 +1,44 @@
+// Command aos-audit verifies the hash chain of an AOS audit log.
+//
+//	aos-audit [-file data/audit.jsonl] [-seq N -hash H]
+//
+// It exits with status 1 when an entry was edited, reordered or deleted.
+// Pass the head (-seq/-hash) recorded by a previous run to also detect
+// entries deleted from the end of the log.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/audit"
+)
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("aos-audit", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	file := fs.String("file", os.Getenv("AUDIT_LOG"), "audit log to verify (default $AUDIT_LOG)")
+	seq := fs.Uint64("seq", 0, "seq of a head recorded earlier; the log must contain it")
+	hash := fs.String("hash", "", "hash of the entry -seq")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" {
+		fmt.Fprintln(stderr, "aos-audit: falta -file (o AUDIT_LOG)")
+		return 2
+	}
+
+	head, err := audit.VerifyFileAnchored(*file, audit.Head{Seq: *seq, Hash: *hash})
+	if err != nil {
+		fmt.Fprintf(stdout, "FAIL %s: %v\n", *file, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "OK %s: %d entradas, head seq=%d hash=%s\n", *file, head.Seq, head.Seq, head.Hash)
+	return 0
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/audit"
+)
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := audit.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := l.Append(audit.Entry{TaskID: "t1", Tool: "send", Mode: "dangerous", Method: "POST", URL: "http://bank/send", Status: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	head := l.Head()
+	_ = l.Close()
+
+	var out bytes.Buffer
+	if code := run([]string{"-file", path}, &out, &out); code != 0 || !strings.HasPrefix(out.String(), "OK") {
+		t.Fatalf("expected OK, got %d %q", code, out.String())
+	}
+
+	// borrar la última entrada: la cadena sigue siendo válida salvo contra el head
+	data, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(string(data), "\n")
+	if err := os.WriteFile(path, []byte(strings.Join(lines[:2], "")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if code := run([]string{"-file", path}, &out, &out); code != 0 {
+		t.Fatalf("truncated log without anchor should verify, got %q", out.String())
+	}
+	out.Reset()
+	args := []string{"-file", path, "-seq", strconv.FormatUint(head.Seq, 10), "-hash", head.Hash}
+	if code := run(args, &out, &out); code != 1 || !strings.HasPrefix(out.String(), "FAIL") {
+		t.Fatalf("expected FAIL against the recorded head, got %d %q", code, out.String())
+	}
+
+	t.Setenv("AUDIT_LOG", "")
+	out.Reset()
+	if code := run(nil, &out, &out); code != 2 {
+		t.Fatalf("expected usage error without -file, got %d", code)
+	}
+}
@@ -14,14 +14,16 @@ type Analyst struct {
 	inbox     chan bus.Message
 	llmClient llm.LLMClient
 	uiStore   *ui.UIStore
+	tasks     TaskStore
 }
 
-func NewAnalyst(b *bus.Bus, llmClient llm.LLMClient, ui *ui.UIStore) *Analyst {
+func NewAnalyst(b *bus.Bus, llmClient llm.LLMClient, ui *ui.UIStore, tasks TaskStore) *Analyst {
 	return &Analyst{
 		bus:       b,
 		inbox:     make(chan bus.Message, 16),
 		llmClient: llmClient,
 		uiStore:   ui,
+		tasks:     tasks,
 	}
 }
 
@@ -67,11 +69,12 @@ func (a *Analyst) handleSummarize(msg bus.Message) {
     id := msg.Payload["id"].(string)
     intentType, _ := msg.Payload["intent"].(string)
     rawAny := msg.Payload["rawResult"]
+    extra, _ := msg.Payload["extra"].(map[string]any)
 
 	raw, ok := rawAny.(map[string]any)
 	if !ok {
 		logx.Error("Analyst", "rawResult invalid for id=%s", id)
-		storeResult(id, Result{
+		storeResult(a.tasks, id, Result{
 			Status: "error",
 			Err:    "resultado bruto inválido",
 		})
@@ -88,28 +91,47 @@ func (a *Analyst) handleSummarize(msg bus.Message) {
  }
 
  timer := logx.Start(id, "Analyst", "SummarizeLLM")
- summary, err := llm.SummarizeResult(taskCtx, a.llmClient, intentType, raw)
+ shadow, _ := extra["shadow"].(bool)
+ summarize := llm.SummarizeResult
+ if shadow {
+     summarize = llm.SummarizeShadowResult
+ }
+ summary, err := summarize(taskCtx, a.llmClient, intentType, raw)
  timer.End()
 
 	if err != nil {
 		logx.Error("Analyst", "error calling to the LLM: %v", err)
 		// Degradamos de forma elegante: devolvemos solo el raw.
-		storeResult(id, Result{
+		if shadow {
+			extra["summary"] = llm.ShadowNotice
+		}
+		storeResult(a.tasks, id, Result{
 			Status: "ok",
-			Data: map[string]any{
+			Data: withExtra(map[string]any{
 				"raw": raw,
-			},
+			}, extra),
 		})
 		return
 	}
 	logx.Info("Analyst", "summary generated: %s", summary)
 	a.uiStore.AddEvent(id, "Analyst", "summary", "summary LLM generado", "")
 
-	storeResult(id, Result{
+	storeResult(a.tasks, id, Result{
 		Status: "ok",
-		Data: map[string]any{
+		Data: withExtra(map[string]any{
 			"raw":     raw,
 			"summary": summary,
-		},
+		}, extra),
 	})
 }
+
+// withExtra adds the Verifier's extra result fields (approvals, …) to the
+// final result data without overriding raw or summary.
+func withExtra(data, extra map[string]any) map[string]any {
+	for k, v := range extra {
+		if _, exists := data[k]; !exists {
+			data[k] = v
+		}
+	}
+	return data
+}
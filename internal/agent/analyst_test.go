@@ -24,7 +24,7 @@ var _ llm.LLMClient = (*fakeLLM)(nil)
 func TestAnalyst_HandleSummarize_InvalidRawStoresError(t *testing.T) {
     b := bus.New()
     uiStore := ui.NewUIStore()
-    a := NewAnalyst(b, &fakeLLM{}, uiStore)
+    a := NewAnalyst(b, &fakeLLM{}, uiStore, NewMemoryTaskStore(0))
 
     id := "task-analyst-1"
     // rawResult is not a map[string]any → should store error
@@ -40,9 +40,7 @@ func TestAnalyst_HandleSummarize_InvalidRawStoresError(t *testing.T) {
     // Call dispatch synchronously
     a.dispatch(msg)
 
-    resultsMu.Lock()
-    res, ok := results[id]
-    resultsMu.Unlock()
+    res, ok := getResult(a.tasks, id)
     if !ok {
         t.Fatalf("expected result to be stored for id=%s", id)
     }
@@ -57,7 +55,7 @@ func TestAnalyst_HandleSummarize_InvalidRawStoresError(t *testing.T) {
 func TestAnalyst_HandleSummarize_LLMError_DegradesToRaw(t *testing.T) {
     b := bus.New()
     uiStore := ui.NewUIStore()
-    a := NewAnalyst(b, &fakeLLM{err: errors.New("down")}, uiStore)
+    a := NewAnalyst(b, &fakeLLM{err: errors.New("down")}, uiStore, NewMemoryTaskStore(0))
 
     id := "task-analyst-2"
     raw := map[string]any{"ok": true}
@@ -72,9 +70,7 @@ func TestAnalyst_HandleSummarize_LLMError_DegradesToRaw(t *testing.T) {
 
     a.dispatch(msg)
 
-    resultsMu.Lock()
-    res := results[id]
-    resultsMu.Unlock()
+    res, _ := getResult(a.tasks, id)
 
     if res.Status != "ok" {
         t.Fatalf("expected status=ok on degrade path, got %s", res.Status)
@@ -96,7 +92,7 @@ func TestAnalyst_HandleSummarize_LLMError_DegradesToRaw(t *testing.T) {
 func TestAnalyst_HandleSummarize_SuccessStoresSummary(t *testing.T) {
     b := bus.New()
     uiStore := ui.NewUIStore()
-    a := NewAnalyst(b, &fakeLLM{out: "Resumen breve"}, uiStore)
+    a := NewAnalyst(b, &fakeLLM{out: "Resumen breve"}, uiStore, NewMemoryTaskStore(0))
 
     id := "task-analyst-3"
     raw := map[string]any{"balance": 123.0}
@@ -111,9 +107,7 @@ func TestAnalyst_HandleSummarize_SuccessStoresSummary(t *testing.T) {
 
     a.dispatch(msg)
 
-    resultsMu.Lock()
-    res := results[id]
-    resultsMu.Unlock()
+    res, _ := getResult(a.tasks, id)
 
     if res.Status != "ok" {
         t.Fatalf("expected status=ok, got %s", res.Status)
@@ -4,14 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"html/template"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/audit"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/auth"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/logx"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
 )
@@ -20,8 +27,19 @@ type APIAgent struct {
 	bus     *bus.Bus
 	inbox   chan bus.Message
 	uiStore *ui.UIStore // <-- nuevo
-	// minimal auth and rate limiting
-	apiKey string
+	tasks   TaskStore
+	// webhooks delivers the final result to the callback_url of /ask
+	webhooks *webhookNotifier
+	// idempotency maps Idempotency-Key headers to the tasks they launched
+	idempotency *idempotencyStore
+	// audit is queried by GET /audit; nil when the audit log is disabled
+	audit *audit.Log
+	// authn maps credentials to the caller of the task
+	authn *auth.Authenticator
+	// approverRoles may list and decide approvals (APPROVER_ROLES)
+	approverRoles map[string]bool
+	// auditorRoles may read every entry of the audit log (AUDITOR_ROLES)
+	auditorRoles map[string]bool
 	// naive fixed-window rate limiter per client key
 	rl struct {
 		Window  time.Duration
@@ -31,12 +49,27 @@ type APIAgent struct {
 	}
 }
 
-func NewAPIAgent(b *bus.Bus, ui *ui.UIStore) *APIAgent {
+// NewAPIAgent crea el agente HTTP. Con authn nil solo se admite la API_KEY
+// compartida del entorno, sin identidades.
+func NewAPIAgent(b *bus.Bus, ui *ui.UIStore, tasks TaskStore, auditLog *audit.Log, authn *auth.Authenticator) *APIAgent {
+	if authn == nil {
+		authn, _ = auth.New(os.Getenv("API_KEY"), nil, "")
+	}
 	a := &APIAgent{
 		bus:     b,
 		inbox:   make(chan bus.Message, 16),
 		uiStore: ui,
-		apiKey:  strings.TrimSpace(os.Getenv("API_KEY")),
+		tasks:   tasks,
+		audit:   auditLog,
+		authn:   authn,
+
+		approverRoles: rolesFromEnv("APPROVER_ROLES", defaultApproverRoles),
+		auditorRoles:  rolesFromEnv("AUDITOR_ROLES", defaultAuditorRoles),
+	}
+	a.webhooks = newWebhookNotifier(tasks, ui)
+	a.idempotency = newIdempotencyStore(idempotencyTTLFromEnv())
+	if ui != nil {
+		ui.OnEvent(publishUIEvent) // alimenta /task/stream
 	}
 	// initialize rate limiter defaults
 	a.rl.Window = 1 * time.Minute
@@ -94,20 +127,21 @@ func getClientKey(r *http.Request) string {
 	return "ip:" + host
 }
 
-// checkAuth enforces API key when configured via API_KEY env var
+// checkAuth enforces the credentials configured in the authenticator
+// (API_KEY, principals, JWT); with none configured auth is disabled.
 func (a *APIAgent) checkAuth(r *http.Request) bool {
-	if a.apiKey == "" {
-		return true // auth disabled
-	}
-	if k := r.Header.Get("X-API-Key"); k != "" && k == a.apiKey {
-		return true
-	}
-	auth := r.Header.Get("Authorization")
-	if strings.HasPrefix(strings.ToLower(auth), "bearer ") {
-		token := strings.TrimSpace(auth[7:])
-		return token == a.apiKey
+	_, ok := a.authn.Authenticate(r)
+	return ok
+}
+
+// authenticate returns the caller of a request, or writes 401.
+func (a *APIAgent) authenticate(w http.ResponseWriter, r *http.Request) (guard.Caller, bool) {
+	caller, ok := a.authn.Authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer, X-API-Key")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 	}
-	return false
+	return caller, ok
 }
 
 var idRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
@@ -152,6 +186,21 @@ type askRequest struct {
 	Message   string         `json:"message"`
 }
 
+type taskReplyRequest struct {
+	ID      string         `json:"id"`
+	Params  map[string]any `json:"params,omitempty"`
+	Message string         `json:"message,omitempty"`
+}
+
+type taskCancelRequest struct {
+	ID string `json:"id"`
+}
+
+type approvalDecisionRequest struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason,omitempty"`
+}
+
 type askNLPRequest struct {
 	Message string `json:"message"`
 }
@@ -165,9 +214,17 @@ type askResponse struct {
 
 // RegisterHTTP registra endpoints HTTP
 func (a *APIAgent) RegisterHTTP(mux *http.ServeMux) {
-	mux.HandleFunc("/ask", a.handleAsk)             // async NLP-like mode (message)
-	mux.HandleFunc("/ask_structured", a.handleAsk2) // sync: operation + params
-	mux.HandleFunc("/task", a.handleTask)           // fetch task status/result
+	mux.HandleFunc("/ask", a.handleAsk)                // async NLP-like mode (message)
+	mux.HandleFunc("/ask_structured", a.handleAsk2)    // sync: operation + params
+	mux.HandleFunc("/plan", a.handlePlan)              // dry-run: what would run, nothing executed
+	mux.HandleFunc("/task", a.handleTask)              // fetch task status/result
+	mux.HandleFunc("/task/stream", a.handleTaskStream) // SSE: events, state changes and final result
+	mux.HandleFunc("/task/reply", a.handleTaskReply)   // answer a needs_input task
+	mux.HandleFunc("/task/cancel", a.handleTaskCancel) // cancel a running or parked task
+	mux.HandleFunc("/approvals", a.handleApprovals)    // list dangerous steps waiting for approval
+	mux.HandleFunc("/approvals/approve", a.handleApprovalDecision(true))
+	mux.HandleFunc("/approvals/reject", a.handleApprovalDecision(false))
+	mux.HandleFunc("/audit", a.handleAudit) // executed write/dangerous tool calls
 	//mux.HandleFunc("/ask_nlp", a.handleAskNLP) // modo lenguaje natural
 }
 
@@ -178,9 +235,8 @@ func (a *APIAgent) handleAsk(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Auth check (optional)
-	if !a.checkAuth(r) {
-		w.Header().Set("WWW-Authenticate", "Bearer, X-API-Key")
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	caller, ok := a.authenticate(w, r)
+	if !ok {
 		return
 	}
 	// Rate limit
@@ -195,7 +251,8 @@ func (a *APIAgent) handleAsk(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	type Req struct {
-		Message string `json:"message"`
+		Message     string `json:"message"`
+		CallbackURL string `json:"callback_url,omitempty"`
 	}
 
 	// Limit request body size
@@ -215,11 +272,29 @@ func (a *APIAgent) handleAsk(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "message requerido", http.StatusBadRequest)
 		return
 	}
+	if req.CallbackURL != "" {
+		if err := a.webhooks.validate(req.CallbackURL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
-	id := randomID()
+	id, replay, ok := a.claimIdempotency(w, r, req, caller)
+	if !ok {
+		return
+	}
+	if replay {
+		a.replayAsk(w, id)
+		return
+	}
 
 	logx.Info("Api", "new request id=%s message='%s'", id, req.Message)
 	a.uiStore.AddEvent(id, "Api", "request", req.Message, "")
+	setState(a.tasks, id, stateAccepted)
+	setOwner(a.tasks, id, caller)
+	if req.CallbackURL != "" {
+		a.webhooks.register(id, req.CallbackURL)
+	}
 
 	// Create and register a task context with a default TTL. We deliberately
 	// do NOT tie this context to the request context, because /ask returns
@@ -234,6 +309,7 @@ func (a *APIAgent) handleAsk(w http.ResponseWriter, r *http.Request) {
 			"id":      id,
 			"mode":    "structured",
 			"message": req.Message, // ← ¡IMPORTANTE!
+			"caller":  caller,
 		},
 	})
 
@@ -252,6 +328,10 @@ func (a *APIAgent) handleAsk2(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
+	caller, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
 
 	var req askRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -266,30 +346,149 @@ func (a *APIAgent) handleAsk2(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	id, replay, ok := a.claimIdempotency(w, r, req, caller)
+	if !ok {
+		return
+	}
+	if replay {
+		// Misma petición: se devuelve el resultado de la tarea original.
+		w.Header().Set("Idempotent-Replayed", "true")
+	} else {
+		setState(a.tasks, id, stateAccepted)
+		setOwner(a.tasks, id, caller)
+
+		a.bus.Send("inspector", bus.Message{
+			Type: "new_task",
+			Payload: map[string]any{
+				"id":        id,
+				"mode":      "structured",
+				"operation": req.Operation,
+				"params":    req.Params,
+				"caller":    caller,
+			},
+		})
+	}
+
+	res := waitForResult(a.tasks, id, 30*time.Second)
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	if res.Err != "" {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	enc.Encode(askResponse{
+		ID:     id,
+		Status: res.Status,
+		Result: res.Data,
+		Error:  res.Err,
+	})
+}
+
+// handlePlan devuelve lo que AOS haría con una petición sin ejecutar nada:
+// detecta el intent, extrae params, pasa el guard y renderiza las tools,
+// pero no llama a ninguna tool ni al Analyst.
+// POST /plan {"message": "..."} | {"operation": "...", "params": {...}}
+func (a *APIAgent) handlePlan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	caller, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+	if err := a.acquireRL(getClientKey(r)); err != nil {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	ct := r.Header.Get("Content-Type")
+	if ct == "" || !strings.HasPrefix(strings.ToLower(ct), "application/json") {
+		http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAskBodyBytes)
+	var req askRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Operation == "" && req.Message == "" {
+		http.Error(w, "operation o message requerido", http.StatusBadRequest)
+		return
+	}
+
 	id := randomID()
+	logx.Info("Api", "dry-run request id=%s", id)
+	a.uiStore.AddEvent(id, "Api", "plan", req.Message, "")
+	setState(a.tasks, id, stateAccepted)
+	setOwner(a.tasks, id, caller)
+	_ = NewTaskContext(context.Background(), id, 60*time.Second)
 
 	a.bus.Send("inspector", bus.Message{
 		Type: "new_task",
 		Payload: map[string]any{
 			"id":        id,
 			"mode":      "structured",
+			"message":   req.Message,
 			"operation": req.Operation,
 			"params":    req.Params,
+			"caller":    caller,
+			"dry_run":   true,
 		},
 	})
 
-	res := waitForResult(id, 30*time.Second)
+	res := waitForResult(a.tasks, id, 30*time.Second)
 
 	w.Header().Set("Content-Type", "application/json")
-	enc := json.NewEncoder(w)
-	if res.Err != "" {
-		w.WriteHeader(http.StatusInternalServerError)
+	switch res.Status {
+	case statusPlanned:
+	case "timeout":
+		w.WriteHeader(http.StatusGatewayTimeout)
+	default:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	_ = json.NewEncoder(w).Encode(taskResultBody(id, res))
+}
+
+// claimIdempotency returns the id of the task to run for a request. With an
+// Idempotency-Key already used by the same client and endpoint it returns
+// the original task and replay=true. On an invalid or conflicting key it
+// writes the error and returns ok=false.
+func (a *APIAgent) claimIdempotency(w http.ResponseWriter, r *http.Request, req any, caller guard.Caller) (id string, replay, ok bool) {
+	id = randomID()
+	scope, err := idempotencyScope(r, caller)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false, false
+	}
+	if scope == "" {
+		return id, false, true
+	}
+	id, replay, err = a.idempotency.claim(scope, requestFingerprint(req), id, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return "", false, false
+	}
+	if replay {
+		logx.Info("Api", "idempotent replay of task id=%s", id)
+	}
+	return id, replay, true
+}
+
+// replayAsk answers a repeated /ask with the task it launched the first
+// time: its result if it has finished, otherwise the original 202.
+func (a *APIAgent) replayAsk(w http.ResponseWriter, id string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
+	if res, ok := getResult(a.tasks, id); ok {
+		_ = json.NewEncoder(w).Encode(taskResultBody(id, res))
+		return
 	}
-	enc.Encode(askResponse{
-		ID:     id,
-		Status: res.Status,
-		Result: res.Data,
-		Error:  res.Err,
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"id":     id,
+		"status": "accepted",
 	})
 }
 
@@ -301,9 +500,8 @@ func (a *APIAgent) handleTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Auth check (optional)
-	if !a.checkAuth(r) {
-		w.Header().Set("WWW-Authenticate", "Bearer, X-API-Key")
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	caller, ok := a.authenticate(w, r)
+	if !ok {
 		return
 	}
 	// Rate limit
@@ -320,30 +518,453 @@ func (a *APIAgent) handleTask(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "id inválido", http.StatusBadRequest)
 		return
 	}
+	if !ownsTask(a.tasks, id, caller) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
 
-	// Consultar si ya hay resultado
-	if res, ok := getResult(id); ok {
-		// Limpiar almacenamiento para evitar fugas
-		deleteResult(id)
-		w.Header().Set("Content-Type", "application/json")
+	rec, _ := a.tasks.Get(id)
+
+	// Consultar si ya hay resultado. La lectura es idempotente: el
+	// TaskStore conserva la tarea hasta que caduca su TTL.
+	out := map[string]any{
+		"id":     id,
+		"status": "pending", // aún pendiente
+	}
+	if rec.Result != nil {
 		// Mapear al formato de respuesta anterior
-		_ = json.NewEncoder(w).Encode(map[string]any{
-			"id":     id,
-			"status": res.Status,
-			"data":   res.Data,
-			"error":  res.Err,
-		})
+		out = taskResultBody(id, *rec.Result)
+	}
+	if rec.State.State != "" {
+		out["state"] = rec.State
+	}
+	if rec.Callback != nil {
+		out["callback"] = rec.Callback
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// streamKeepAlive is how often an idle stream sends a comment so proxies
+// do not close it.
+const streamKeepAlive = 15 * time.Second
+
+// handleTaskStream envía por SSE lo que le ocurre a una tarea según pasa:
+// los eventos del timeline (event: ui), los cambios de estado (event: state)
+// y, como último evento, el resultado final (event: result). Al conectar se
+// reenvía lo que ya había ocurrido. Una tarea aparcada en needs_input o
+// pending_approval no cierra el stream.
+// GET /task/stream?id=...
+func (a *APIAgent) handleTaskStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	caller, ok := a.authenticate(w, r)
+	if !ok {
 		return
 	}
+	if err := a.acquireRL(getClientKey(r)); err != nil {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if !idRe.MatchString(id) {
+		http.Error(w, "id inválido", http.StatusBadRequest)
+		return
+	}
+	if !ownsTask(a.tasks, id, caller) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming no soportado", http.StatusInternalServerError)
+		return
+	}
+
+	// Registrar el watcher antes de leer el estado para no perder nada.
+	watcher, stop := watchTask(id, true)
+	defer stop()
+
+	var history []ui.Event
+	if a.uiStore != nil {
+		history = a.uiStore.Events(id)
+	}
+	state, hasState := getState(a.tasks, id)
+	res, hasResult := getResult(a.tasks, id)
+	if !hasState && !hasResult && len(history) == 0 {
+		http.Error(w, "task no encontrada", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	lastEvent := 0
+	for _, ev := range history {
+		writeSSE(w, eventUI, ev)
+		lastEvent = ev.Seq
+	}
+	if hasState {
+		writeSSE(w, eventState, state)
+	}
+	if hasResult && !waitingStatuses[res.Status] {
+		writeSSE(w, "result", taskResultBody(id, res))
+		flusher.Flush()
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-watcher.events:
+			writeTaskEvent(w, ev, lastEvent, len(state.History))
+		case res := <-watcher.result:
+			if waitingStatuses[res.Status] {
+				continue // el evento de estado ya indica que está aparcada
+			}
+			// Lo publicado antes del resultado sale antes que él.
+			for drained := false; !drained; {
+				select {
+				case ev := <-watcher.events:
+					writeTaskEvent(w, ev, lastEvent, len(state.History))
+				default:
+					drained = true
+				}
+			}
+			writeSSE(w, "result", taskResultBody(id, res))
+			flusher.Flush()
+			return
+		case <-keepAlive.C:
+			_, _ = io.WriteString(w, ": keepalive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// writeTaskEvent writes a live event, skipping the ones already sent when
+// the stream replayed the task history. Events are matched by their
+// position in the timeline and the state history, not by time: several
+// can share a timestamp.
+func writeTaskEvent(w io.Writer, ev taskEvent, lastEvent, stateTransitions int) {
+	switch ev.Kind {
+	case eventUI:
+		if ev.UI.Seq > lastEvent {
+			writeSSE(w, eventUI, ev.UI)
+		}
+	case eventState:
+		if len(ev.State.History) > stateTransitions {
+			writeSSE(w, eventState, ev.State)
+		}
+	}
+}
+
+// writeSSE writes one server-sent event with a JSON payload.
+func writeSSE(w io.Writer, event string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logx.Warn("Stream", "cannot encode %s event: %v", event, err)
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+// taskResultBody is the result of a task in the format of /task.
+func taskResultBody(id string, res Result) map[string]any {
+	return map[string]any{
+		"id":     id,
+		"status": res.Status,
+		"data":   res.Data,
+		"error":  res.Err,
+	}
+}
+
+// handleTaskReply reanuda una tarea en estado needs_input con los datos
+// aportados por el usuario (params explícitos y/o texto libre).
+// POST /task/reply {"id": "...", "params": {...}, "message": "..."}
+func (a *APIAgent) handleTaskReply(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	// Auth check (optional)
+	caller, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+	// Rate limit
+	if err := a.acquireRL(getClientKey(r)); err != nil {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	ct := r.Header.Get("Content-Type")
+	if ct == "" || !strings.HasPrefix(strings.ToLower(ct), "application/json") {
+		http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAskBodyBytes)
+	var req taskReplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !idRe.MatchString(req.ID) {
+		http.Error(w, "id inválido", http.StatusBadRequest)
+		return
+	}
+	if len(req.Params) == 0 && strings.TrimSpace(req.Message) == "" {
+		http.Error(w, "params o message requerido", http.StatusBadRequest)
+		return
+	}
+	if !ownsTask(a.tasks, req.ID, caller) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if !hasPendingInput(a.tasks, req.ID) {
+		http.Error(w, "la tarea no está esperando datos", http.StatusConflict)
+		return
+	}
+
+	logx.Info("Api", "reply for task id=%s", req.ID)
+	a.uiStore.AddEvent(req.ID, "Api", "reply", req.Message, "")
+
+	// The original task context may have expired while waiting for the user.
+	_ = NewTaskContext(context.Background(), req.ID, 60*time.Second)
+
+	a.bus.Send("planner", bus.Message{
+		Type: "resume_task",
+		Payload: map[string]any{
+			"id":      req.ID,
+			"params":  req.Params,
+			"message": req.Message,
+		},
+	})
 
-	// Aún pendiente
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
 	_ = json.NewEncoder(w).Encode(map[string]any{
-		"id":     id,
-		"status": "pending",
+		"id":     req.ID,
+		"status": "accepted",
 	})
 }
 
+// handleTaskCancel cancela una tarea en curso o aparcada. El Verifier no
+// lanza más steps y compensa los ya ejecutados.
+// POST /task/cancel {"id": "..."}
+func (a *APIAgent) handleTaskCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	caller, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+	if err := a.acquireRL(getClientKey(r)); err != nil {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	ct := r.Header.Get("Content-Type")
+	if ct == "" || !strings.HasPrefix(strings.ToLower(ct), "application/json") {
+		http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAskBodyBytes)
+	var req taskCancelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !idRe.MatchString(req.ID) {
+		http.Error(w, "id inválido", http.StatusBadRequest)
+		return
+	}
+
+	if !ownsTask(a.tasks, req.ID, caller) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	parked, err := cancelTask(a.tasks, req.ID)
+	switch {
+	case errors.Is(err, errTaskNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	logx.Info("Api", "task id=%s cancelled", req.ID)
+	a.uiStore.AddEvent(req.ID, "Api", "cancel", "tarea cancelada", "")
+	if parked {
+		a.bus.Send("verifier", bus.Message{
+			Type:    "cancel_task",
+			Payload: map[string]any{"id": req.ID},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"id":     req.ID,
+		"status": "cancelled",
+	})
+}
+
+// handleApprovals lista las aprobaciones pendientes con la petición
+// renderizada (secretos enmascarados) que se enviaría al aprobar. Solo
+// para llamantes con un rol de APPROVER_ROLES.
+// GET /approvals
+func (a *APIAgent) handleApprovals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := a.authorizeApprover(w, r); !ok {
+		return
+	}
+	if err := a.acquireRL(getClientKey(r)); err != nil {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"approvals": listPendingApprovals(),
+	})
+}
+
+// handleAudit consulta el audit log de llamadas a tools write/dangerous.
+// Los llamantes con un rol de AUDITOR_ROLES ven todas las entradas; el
+// resto solo las suyas y las de sus tareas.
+// GET /audit?task=<id>&caller=<id>&limit=<n>
+func (a *APIAgent) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	caller, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+	if err := a.acquireRL(getClientKey(r)); err != nil {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	if a.audit == nil {
+		http.Error(w, "audit log deshabilitado (AUDIT_LOG)", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	task := query.Get("task")
+	if task != "" && !idRe.MatchString(task) {
+		http.Error(w, "invalid task id", http.StatusBadRequest)
+		return
+	}
+	if !hasRole(caller, a.auditorRoles) {
+		if caller.IsZero() {
+			http.Error(w, "el audit log requiere un llamante identificado", http.StatusForbidden)
+			return
+		}
+		if task != "" && !ownsTask(a.tasks, task, caller) {
+			http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		query.Set("caller", caller.ID)
+		r.URL.RawQuery = query.Encode()
+	}
+	a.audit.HandleQuery(w, r)
+}
+
+// handleApprovalDecision aprueba o rechaza la tool peligrosa de una tarea
+// aparcada en pending_approval y avisa al Verifier. El aprobador es el
+// llamante autenticado, que debe tener un rol de APPROVER_ROLES y no puede
+// ser quien solicitó la operación.
+// POST /approvals/approve | /approvals/reject {"id": "...", "reason": "..."}
+func (a *APIAgent) handleApprovalDecision(approve bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		caller, ok := a.authorizeApprover(w, r)
+		if !ok {
+			return
+		}
+		if err := a.acquireRL(getClientKey(r)); err != nil {
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		ct := r.Header.Get("Content-Type")
+		if ct == "" || !strings.HasPrefix(strings.ToLower(ct), "application/json") {
+			http.Error(w, "unsupported media type", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxAskBodyBytes)
+		var req approvalDecisionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !idRe.MatchString(req.ID) {
+			http.Error(w, "id inválido", http.StatusBadRequest)
+			return
+		}
+
+		ap, err := decideApproval(req.ID, approve, caller.ID, req.Reason)
+		if errors.Is(err, errApprovalNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, errSelfApproval) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		logx.Info("Api", "approval id=%s tool=%s status=%s approver=%s", ap.TaskID, ap.Tool, ap.Status, ap.Approver)
+		if approve {
+			// The original task context may have expired while waiting.
+			_ = NewTaskContext(context.Background(), req.ID, 60*time.Second)
+		}
+		a.bus.Send("verifier", bus.Message{
+			Type:    "approval_decision",
+			Payload: map[string]any{"id": req.ID},
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(ap)
+	}
+}
+
+// HandleApprovalsUI muestra las aprobaciones pendientes en /ui/approvals.
+// Las decisiones se envían desde la página a /approvals/approve|reject.
+// Como GET /approvals, solo la ven los llamantes con rol de aprobador.
+func (a *APIAgent) HandleApprovalsUI(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.authorizeApprover(w, r); !ok {
+		return
+	}
+	tpl := template.Must(template.ParseFiles(
+		filepath.Join("templates", "ui", "approvals.html"),
+	))
+	if err := tpl.Execute(w, listPendingApprovals()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 // /ask_nlp → message (texto libre)
 func (a *APIAgent) handleAskNLP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -376,7 +997,7 @@ func (a *APIAgent) handleAskNLP(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
-	res := waitForResult(id, 30*time.Second)
+	res := waitForResult(a.tasks, id, 30*time.Second)
 
 	w.Header().Set("Content-Type", "application/json")
 	enc := json.NewEncoder(w)
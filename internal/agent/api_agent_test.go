@@ -1,13 +1,19 @@
 package agent
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/auth"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
 	"github.com/stretchr/testify/require"
@@ -17,7 +23,7 @@ func TestAPIAgent_HandleAsk_AsyncAndTaskFetch(t *testing.T) {
 	// Setup dependencies
 	messageBus := bus.New()
 	uiStore := ui.NewUIStore()
-	apiAgent := NewAPIAgent(messageBus, uiStore)
+	apiAgent := NewAPIAgent(messageBus, uiStore, NewMemoryTaskStore(0), nil, nil)
 
 	// Subscribe to inspector channel to intercept the message
 	inspectorChan := make(chan bus.Message, 1)
@@ -53,7 +59,7 @@ func TestAPIAgent_HandleAsk_AsyncAndTaskFetch(t *testing.T) {
 			time.Sleep(50 * time.Millisecond)
 
 			// Store result
-			storeResult(id, Result{
+			storeResult(apiAgent.tasks, id, Result{
 				Status: "completed",
 				Data:   map[string]string{"reply": "processed"},
 			})
@@ -99,4 +105,247 @@ func TestAPIAgent_HandleAsk_AsyncAndTaskFetch(t *testing.T) {
     data, ok := done["data"].(map[string]any)
     require.True(t, ok)
     require.Equal(t, "processed", data["reply"])
+
+    // Reading the task again returns the same result
+    r3, err := http.Get(ts.URL+"/task?id="+id)
+    require.NoError(t, err)
+    defer r3.Body.Close()
+    var again map[string]any
+    require.NoError(t, json.NewDecoder(r3.Body).Decode(&again))
+    require.Equal(t, "completed", again["status"])
+}
+
+func TestAPIAgent_TaskReply_ConflictWhenNotWaiting(t *testing.T) {
+	apiAgent := NewAPIAgent(bus.New(), ui.NewUIStore(), NewMemoryTaskStore(0), nil, nil)
+	mux := http.NewServeMux()
+	apiAgent.RegisterHTTP(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	body, _ := json.Marshal(map[string]any{"id": "no-such-task", "params": map[string]any{"accountId": "1"}})
+	resp, err := http.Post(ts.URL+"/task/reply", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestAPIAgent_TaskReply_ForwardsToPlanner(t *testing.T) {
+	messageBus := bus.New()
+	apiAgent := NewAPIAgent(messageBus, ui.NewUIStore(), NewMemoryTaskStore(0), nil, nil)
+	plannerCh := make(chan bus.Message, 1)
+	messageBus.Subscribe("planner", plannerCh)
+
+	mux := http.NewServeMux()
+	apiAgent.RegisterHTTP(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	id := "task-reply-ok"
+	savePendingInput(apiAgent.tasks, id, pendingInput{Intent: "banking.get_balance", Missing: []string{"accountId"}})
+
+	body, _ := json.Marshal(map[string]any{"id": id, "params": map[string]any{"accountId": "1"}})
+	resp, err := http.Post(ts.URL+"/task/reply", "application/json", bytes.NewBuffer(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	select {
+	case msg := <-plannerCh:
+		require.Equal(t, "resume_task", msg.Type)
+		require.Equal(t, id, msg.Payload["id"])
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting resume_task")
+	}
+}
+
+func TestAPIAgent_TaskCancel(t *testing.T) {
+	messageBus := bus.New()
+	apiAgent := NewAPIAgent(messageBus, ui.NewUIStore(), NewMemoryTaskStore(0), nil, nil)
+	verifierCh := make(chan bus.Message, 1)
+	messageBus.Subscribe("verifier", verifierCh)
+
+	mux := http.NewServeMux()
+	apiAgent.RegisterHTTP(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	post := func(id string) *http.Response {
+		body, _ := json.Marshal(map[string]any{"id": id})
+		resp, err := http.Post(ts.URL+"/task/cancel", "application/json", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := post("task-cancel-api-unknown")
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	id := "task-cancel-api"
+	requestApproval(Approval{TaskID: id, Tool: "send", ExpiresAt: time.Now().Add(time.Minute)})
+	resp = post(id)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	select {
+	case msg := <-verifierCh:
+		require.Equal(t, "cancel_task", msg.Type)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting cancel_task")
+	}
+	res, _ := getResult(apiAgent.tasks, id)
+	require.Equal(t, "cancelled", res.Status)
+}
+
+func TestAPIAgent_HandleTask_ReturnsLifecycleState(t *testing.T) {
+	apiAgent := NewAPIAgent(bus.New(), ui.NewUIStore(), NewMemoryTaskStore(0), nil, nil)
+	mux := http.NewServeMux()
+	apiAgent.RegisterHTTP(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	id := "task-state-api"
+	setState(apiAgent.tasks, id, stateAccepted)
+	setState(apiAgent.tasks, id, stateDetectingIntent)
+
+	resp, err := http.Get(ts.URL + "/task?id=" + id)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var out struct {
+		Status string    `json:"status"`
+		State  TaskState `json:"state"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	require.Equal(t, "pending", out.Status)
+	require.Equal(t, stateDetectingIntent, out.State.State)
+	require.Len(t, out.State.History, 2)
+}
+
+func TestAPIAgent_TaskStream_PushesEventsAndEndsWithResult(t *testing.T) {
+	uiStore := ui.NewUIStore()
+	apiAgent := NewAPIAgent(bus.New(), uiStore, NewMemoryTaskStore(0), nil, nil)
+	mux := http.NewServeMux()
+	apiAgent.RegisterHTTP(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/task/stream?id=task-stream-unknown")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	id := "task-stream"
+	uiStore.AddEvent(id, "Api", "request", "hola", "")
+	setState(apiAgent.tasks, id, stateAccepted)
+
+	resp, err = http.Get(ts.URL + "/task/stream?id=" + id)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	go func() {
+		// esperar a que el stream esté suscrito
+		for watcherCount(id) == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		uiStore.AddEvent(id, "Planner", "intent", "banking.get_balance", "")
+		setState(apiAgent.tasks, id, stateDetectingIntent)
+		storeResult(apiAgent.tasks, id, Result{Status: "error", Err: "boom"})
+	}()
+
+	var events []string
+	var last string
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if ev, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, ev)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			last = data
+		}
+	}
+	require.Equal(t, []string{"ui", "state", "ui", "state", "state", "result"}, events)
+
+	var res map[string]any
+	require.NoError(t, json.Unmarshal([]byte(last), &res))
+	require.Equal(t, "error", res["status"])
+	require.Equal(t, "boom", res["error"])
+	require.Eventually(t, func() bool { return watcherCount(id) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWriteTaskEvent_SameTimestampIsNotDropped(t *testing.T) {
+	at := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	replayed := ui.Event{Seq: 1, Time: at, Message: "replayed"}
+	state := TaskState{State: stateAccepted, Since: at, History: []StateTransition{{State: stateAccepted, At: at}}}
+
+	var buf bytes.Buffer
+	writeTaskEvent(&buf, taskEvent{Kind: eventUI, UI: replayed}, 1, len(state.History))
+	writeTaskEvent(&buf, taskEvent{Kind: eventUI, UI: ui.Event{Seq: 2, Time: at, Message: "live"}}, 1, len(state.History))
+	next := state
+	next.State = stateDetectingIntent
+	next.History = append(next.History, StateTransition{State: stateDetectingIntent, At: at})
+	writeTaskEvent(&buf, taskEvent{Kind: eventState, State: next}, 1, len(state.History))
+
+	out := buf.String()
+	require.NotContains(t, out, "replayed")
+	require.Contains(t, out, `"message":"live"`)
+	require.Contains(t, out, `"state":"detecting_intent"`)
+}
+
+func TestAPIAgent_TaskStream_FinishedTaskReturnsResultAtOnce(t *testing.T) {
+	apiAgent := NewAPIAgent(bus.New(), ui.NewUIStore(), NewMemoryTaskStore(0), nil, nil)
+	mux := http.NewServeMux()
+	apiAgent.RegisterHTTP(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	id := "task-stream-done"
+	storeResult(apiAgent.tasks, id, Result{Status: "ok", Data: map[string]any{"reply": "hecho"}})
+
+	resp, err := http.Get(ts.URL + "/task/stream?id=" + id)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(string(body), "event: result\ndata: {\"data\":{\"reply\":\"hecho\"},\"error\":\"\",\"id\":\"task-stream-done\",\"status\":\"ok\"}\n\n"), string(body))
+}
+
+func TestAPIAgent_TaskEndpoints_OnlyTheOwner(t *testing.T) {
+	principal := func(id, key string) auth.Principal {
+		sum := sha256.Sum256([]byte(key))
+		return auth.Principal{ID: id, KeySHA256: hex.EncodeToString(sum[:])}
+	}
+	authn, err := auth.New("shared-key", []auth.Principal{principal("ana", "ana-key"), principal("luis", "luis-key")}, "")
+	require.NoError(t, err)
+	messageBus := bus.New()
+	messageBus.Subscribe("inspector", make(chan bus.Message, 1))
+	apiAgent := NewAPIAgent(messageBus, ui.NewUIStore(), NewMemoryTaskStore(0), nil, authn)
+	mux := http.NewServeMux()
+	apiAgent.RegisterHTTP(mux)
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/ask", "ana-key", `{"message":"saldo"}`)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var accepted struct{ ID string }
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&accepted))
+	id := accepted.ID
+	stored, _ := apiAgent.tasks.Get(id)
+	require.Equal(t, "ana", stored.Owner)
+
+	for _, key := range []string{"luis-key", "shared-key"} {
+		require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/task?id="+id, key, "").Code, key)
+		require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/task/stream?id="+id, key, "").Code, key)
+		require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/task/reply", key, `{"id":"`+id+`","message":"600111222"}`).Code, key)
+		require.Equal(t, http.StatusNotFound, do(http.MethodPost, "/task/cancel", key, `{"id":"`+id+`"}`).Code, key)
+	}
+	require.Equal(t, http.StatusOK, do(http.MethodGet, "/task?id="+id, "ana-key", "").Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/task/cancel", "ana-key", `{"id":"`+id+`"}`).Code)
 }
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/audit"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/tools"
+)
+
+// Approval is a human sign-off request for a dangerous tool. The Verifier
+// creates it before the tool runs and parks the task until a decision
+// arrives or the approval expires.
+type Approval struct {
+	TaskID      string                `json:"taskId"`
+	Intent      string                `json:"intent"`
+	Step        string                `json:"step"`
+	Tool        string                `json:"tool"`
+	Request     tools.RenderedRequest `json:"request"` // secrets masked
+	Status      string                `json:"status"`  // pending, approved, rejected, expired
+	RequestedAt time.Time             `json:"requestedAt"`
+	ExpiresAt   time.Time             `json:"expiresAt"`
+	DecidedAt   *time.Time            `json:"decidedAt,omitempty"`
+	RequestedBy string                `json:"requestedBy,omitempty"` // caller ID of the task
+	Approver    string                `json:"approver,omitempty"`
+	Reason      string                `json:"reason,omitempty"`
+}
+
+var (
+	errApprovalNotFound = errors.New("aprobación no encontrada")
+	errApprovalDecided  = errors.New("la aprobación ya no está pendiente")
+	errSelfApproval     = errors.New("quien solicita la operación no puede aprobarla")
+)
+
+// maskRequest hides the secrets of a rendered request before it is shown
+// to a human: authorization headers, URL user info and the values of
+// body fields and query keys that look like secrets.
+func maskRequest(rendered tools.RenderedRequest) tools.RenderedRequest {
+	rendered.URL = audit.RedactURL(rendered.URL)
+	rendered.Headers = tools.MaskHeaders(rendered.Headers)
+	rendered.Body = audit.RedactParams(rendered.Body)
+	return rendered
+}
+
+var (
+	approvalsMu sync.Mutex
+	approvals   = make(map[string]*Approval)
+)
+
+func requestApproval(a Approval) {
+	approvalsMu.Lock()
+	defer approvalsMu.Unlock()
+	a.Status = "pending"
+	approvals[a.TaskID] = &a
+}
+
+// listPendingApprovals returns the approvals still waiting for a decision,
+// oldest first.
+func listPendingApprovals() []Approval {
+	approvalsMu.Lock()
+	defer approvalsMu.Unlock()
+	out := make([]Approval, 0, len(approvals))
+	for _, a := range approvals {
+		if a.Status == "pending" {
+			out = append(out, *a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].RequestedAt.Before(out[j].RequestedAt)
+	})
+	return out
+}
+
+// decideApproval records the approver's decision on a pending approval.
+// The caller who requested the operation cannot decide on it.
+func decideApproval(taskID string, approve bool, approver, reason string) (Approval, error) {
+	approvalsMu.Lock()
+	defer approvalsMu.Unlock()
+	a, ok := approvals[taskID]
+	if !ok {
+		return Approval{}, errApprovalNotFound
+	}
+	if a.Status != "pending" {
+		return *a, errApprovalDecided
+	}
+	if a.RequestedBy != "" && a.RequestedBy == approver {
+		return *a, errSelfApproval
+	}
+	now := time.Now()
+	if now.After(a.ExpiresAt) {
+		a.Status = "expired"
+		a.DecidedAt = &now
+		return *a, errApprovalDecided
+	}
+	a.Status = "rejected"
+	if approve {
+		a.Status = "approved"
+	}
+	a.DecidedAt = &now
+	a.Approver = approver
+	a.Reason = reason
+	return *a, nil
+}
+
+// expireApproval marks a still-pending approval as expired. It reports
+// false when a decision was already taken.
+func expireApproval(taskID string) (Approval, bool) {
+	approvalsMu.Lock()
+	defer approvalsMu.Unlock()
+	a, ok := approvals[taskID]
+	if !ok || a.Status != "pending" {
+		return Approval{}, false
+	}
+	now := time.Now()
+	a.Status = "expired"
+	a.DecidedAt = &now
+	return *a, true
+}
+
+// takeApproval returns and removes the approval of a task once the
+// Verifier has acted on its decision.
+func takeApproval(taskID string) (Approval, bool) {
+	approvalsMu.Lock()
+	defer approvalsMu.Unlock()
+	a, ok := approvals[taskID]
+	if !ok {
+		return Approval{}, false
+	}
+	delete(approvals, taskID)
+	return *a, true
+}
@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"errors"
+)
+
+var (
+	errTaskNotFound = errors.New("tarea no encontrada")
+	errTaskFinished = errors.New("la tarea ya ha terminado")
+)
+
+// cancelTask stops a running or parked task. It cancels the task context,
+// so in-flight tool calls abort and the Verifier does not start any other
+// step, drops pending input and approvals, and records a "cancelled"
+// result. It reports whether the task was parked in the Verifier, which
+// then has to release (and compensate) the parked run.
+func cancelTask(tasks TaskStore, id string) (parked bool, err error) {
+	rec, known := tasks.Get(id)
+	if known && rec.Result != nil && !waitingStatuses[rec.Result.Status] {
+		if rec.Result.Status == stateCancelled {
+			return false, nil
+		}
+		return false, errTaskFinished
+	}
+	if known && terminalStates[rec.State.State] {
+		return false, errTaskFinished
+	}
+	_, running := GetTaskContext(id)
+	_, approval := takeApproval(id)
+	_, input := takePendingInput(tasks, id)
+	if !known && !running && !approval && !input {
+		return false, errTaskNotFound
+	}
+
+	storeResult(tasks, id, Result{Status: stateCancelled, Err: "tarea cancelada"})
+	return approval, nil
+}
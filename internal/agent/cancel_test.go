@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+func TestStoreResult_ReleasesTaskContext(t *testing.T) {
+	tasks := NewMemoryTaskStore(0)
+	id := "task-ctx-release"
+	NewTaskContext(context.Background(), id, time.Minute)
+	storeResult(tasks, id, Result{Status: "completed"})
+
+	if _, ok := GetTaskContext(id); ok {
+		t.Fatalf("task context must be released once the task has a result")
+	}
+}
+
+func TestCancelTask_ResultIsNotOverwritten(t *testing.T) {
+	tasks := NewMemoryTaskStore(0)
+	id := "task-cancel-sticky"
+	ctx := NewTaskContext(context.Background(), id, time.Minute)
+
+	if _, err := cancelTask(tasks, id); err != nil {
+		t.Fatalf("cancelTask: %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatalf("task context must be cancelled")
+	}
+	storeResult(tasks, id, Result{Status: "completed"})
+	if res := mustResult(tasks, id); res.Status != "cancelled" {
+		t.Fatalf("late result must not overwrite cancelled, got %+v", res)
+	}
+	if _, err := cancelTask(tasks, id); err != nil {
+		t.Fatalf("cancelling twice should be a no-op, got %v", err)
+	}
+}
+
+func TestCancelTask_Errors(t *testing.T) {
+	tasks := NewMemoryTaskStore(0)
+	if _, err := cancelTask(tasks, "task-cancel-unknown"); err != errTaskNotFound {
+		t.Fatalf("expected errTaskNotFound, got %v", err)
+	}
+	id := "task-cancel-finished"
+	storeResult(tasks, id, Result{Status: "completed"})
+	if _, err := cancelTask(tasks, id); err != errTaskFinished {
+		t.Fatalf("expected errTaskFinished, got %v", err)
+	}
+}
+
+func TestVerifier_CancelledTask_StopsBeforeNextStep(t *testing.T) {
+	tasks := NewMemoryTaskStore(0)
+	id := "task-cancel-running"
+	var second int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/first":
+			// la tarea se cancela mientras el primer step está en curso
+			if _, err := cancelTask(tasks, id); err != nil {
+				t.Errorf("cancelTask: %v", err)
+			}
+		case "/second":
+			atomic.AddInt32(&second, 1)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}))
+	defer ts.Close()
+
+	cfg := &config.Config{Tools: map[string]config.Tool{
+		"first":  {Name: "first", Method: "POST", URL: ts.URL + "/first", Mode: "write", TimeoutMs: 500},
+		"second": {Name: "second", Method: "POST", URL: ts.URL + "/second", Mode: "write", TimeoutMs: 500},
+	}}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{
+		{Tool: "first"},
+		{Tool: "second"},
+	}}
+
+	NewTaskContext(context.Background(), id, time.Minute)
+	NewVerifier(bus.New(), cfg, ui.NewUIStore(), tasks, guard.NewMemoryCounterStore(), nil).dispatch(runPipelineMsg(id, pipe))
+
+	if atomic.LoadInt32(&second) != 0 {
+		t.Fatalf("no step may start after the task is cancelled")
+	}
+	res := mustResult(tasks, id)
+	if res.Status != "cancelled" {
+		t.Fatalf("expected cancelled, got %+v", res)
+	}
+	if _, ok := GetTaskContext(id); ok {
+		t.Fatalf("task context must be released")
+	}
+}
+
+func TestVerifier_CancelParkedTask(t *testing.T) {
+	v, _, pipe, sent := approvalFixture(t)
+	pipe.Steps[0].Compensate = &config.Compensation{Tool: "check"}
+	id := "task-cancel-parked"
+	v.dispatch(runPipelineMsg(id, pipe))
+
+	parked, err := cancelTask(v.tasks, id)
+	if err != nil || !parked {
+		t.Fatalf("expected parked task to be cancelled, parked=%v err=%v", parked, err)
+	}
+	v.dispatch(bus.Message{Type: "cancel_task", Payload: map[string]any{"id": id}})
+
+	res := mustResult(v.tasks, id)
+	if res.Status != "cancelled" {
+		t.Fatalf("expected cancelled, got %+v", res)
+	}
+	if comps, _ := res.Data.(map[string]any)["compensations"].([]compensationOutcome); len(comps) != 1 {
+		t.Fatalf("executed steps of a cancelled task must be compensated, got %+v", res.Data)
+	}
+	if _, err := decideApproval(id, true, "alice", ""); err == nil {
+		t.Fatalf("a cancelled task cannot be approved")
+	}
+	if atomic.LoadInt32(sent) != 0 {
+		t.Fatalf("dangerous tool must not run")
+	}
+}
+
+func TestVerifier_CancelWinsApprovalRace_CompensatesParkedRun(t *testing.T) {
+	v, _, pipe, sent := approvalFixture(t)
+	pipe.Steps[0].Compensate = &config.Compensation{Tool: "check"}
+	id := "task-cancel-race"
+	v.dispatch(runPipelineMsg(id, pipe))
+
+	// the approval is decided and the task cancelled before the Verifier
+	// handles either message: approval_decision arrives first
+	if _, err := decideApproval(id, true, "alice", ""); err != nil {
+		t.Fatalf("decideApproval: %v", err)
+	}
+	if parked, err := cancelTask(v.tasks, id); err != nil || !parked {
+		t.Fatalf("expected parked task to be cancelled, parked=%v err=%v", parked, err)
+	}
+	v.dispatch(bus.Message{Type: "approval_decision", Payload: map[string]any{"id": id}})
+	v.dispatch(bus.Message{Type: "cancel_task", Payload: map[string]any{"id": id}})
+
+	res := mustResult(v.tasks, id)
+	if res.Status != "cancelled" {
+		t.Fatalf("expected cancelled, got %+v", res)
+	}
+	if comps, _ := res.Data.(map[string]any)["compensations"].([]compensationOutcome); len(comps) != 1 {
+		t.Fatalf("executed steps of a cancelled task must be compensated, got %+v", res.Data)
+	}
+	if atomic.LoadInt32(sent) != 0 {
+		t.Fatalf("dangerous tool must not run")
+	}
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/llm"
+)
+
+// pendingInput keeps what the Planner already knows about a task that is
+// parked in "needs_input" until the user supplies the missing params. It
+// lives on the task record, so it goes away with the task.
+type pendingInput struct {
+	Intent    string            `json:"intent"`
+	Message   string            `json:"message"`
+	Params    map[string]string `json:"params,omitempty"`
+	Missing   []string          `json:"missing"`
+	Questions []string          `json:"questions,omitempty"`
+	Caller    guard.Caller      `json:"caller"`
+	Since     time.Time         `json:"since"`
+}
+
+func savePendingInput(tasks TaskStore, id string, p pendingInput) {
+	err := tasks.Update(id, func(rec *TaskRecord) bool {
+		rec.Pending = &p
+		return true
+	})
+	if err != nil {
+		logStoreError(id, err)
+	}
+}
+
+// takePendingInput returns and removes the pending state of a task, so a
+// reply is consumed only once.
+func takePendingInput(tasks TaskStore, id string) (pendingInput, bool) {
+	var p pendingInput
+	ok := false
+	err := tasks.Update(id, func(rec *TaskRecord) bool {
+		if rec.Pending == nil {
+			return false
+		}
+		p, ok = *rec.Pending, true
+		rec.Pending = nil
+		return true
+	})
+	if err != nil {
+		logStoreError(id, err)
+	}
+	return p, ok
+}
+
+// hasPendingInput reports whether a task is waiting for a user reply.
+func hasPendingInput(tasks TaskStore, id string) bool {
+	rec, ok := tasks.Get(id)
+	return ok && rec.Pending != nil
+}
+
+// missingParams returns the required params that are absent or empty.
+func missingParams(required []string, params map[string]string) []string {
+	var out []string
+	for _, name := range required {
+		if strings.TrimSpace(params[name]) == "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// clarificationQuestion builds the follow-up question shown to the user
+// for a missing param.
+func clarificationQuestion(param string) string {
+	return fmt.Sprintf("Necesito el valor de '%s' para continuar. ¿Cuál es?", param)
+}
+
+// paramHints describes the given params of an intent for the
+// ExtractParams prompt, using their declared type and description, and
+// lets ExtractParams validate the extracted values against them.
+func paramHints(intent config.Intent, names []string) []llm.ParamHint {
+	hints := make([]llm.ParamHint, 0, len(names))
+	for _, name := range names {
+		h := llm.ParamHint{Name: name}
+		if spec, ok := intent.ParamSpec(name); ok {
+			h.Description = spec.Hint()
+			if spec.Type == config.ParamEnum {
+				h.Enum = spec.Values
+			}
+			h.Validate = func(v string) error {
+				_, err := spec.Coerce(v)
+				return err
+			}
+		}
+		hints = append(hints, h)
+	}
+	return hints
+}
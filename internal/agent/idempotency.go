@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+)
+
+// defaultIdempotencyTTL applies when IDEMPOTENCY_TTL is unset or invalid.
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLen bounds the Idempotency-Key header.
+const maxIdempotencyKeyLen = 255
+
+var (
+	errIdempotencyConflict = errors.New("Idempotency-Key ya usada con otra petición")
+	errIdempotencyKey      = errors.New("Idempotency-Key inválida")
+)
+
+// idempotencyEntry binds an Idempotency-Key to the task it launched.
+type idempotencyEntry struct {
+	TaskID      string
+	Fingerprint string // hash of the request body
+	CreatedAt   time.Time
+}
+
+// idempotencyStore remembers the Idempotency-Key of /ask and
+// /ask_structured requests for a retention window, so a retried request
+// returns the original task instead of launching the pipeline again. Keys
+// are kept in memory, like pending approvals.
+type idempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]idempotencyEntry
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{ttl: ttl, entries: make(map[string]idempotencyEntry)}
+}
+
+// idempotencyTTLFromEnv reads IDEMPOTENCY_TTL.
+func idempotencyTTLFromEnv() time.Duration {
+	if d, err := time.ParseDuration(strings.TrimSpace(os.Getenv("IDEMPOTENCY_TTL"))); err == nil && d > 0 {
+		return d
+	}
+	return defaultIdempotencyTTL
+}
+
+// claim binds key to taskID unless the key is already bound. For a key in
+// use it returns the original task id and replay=true, or
+// errIdempotencyConflict if it came with a different request.
+func (s *idempotencyStore) claim(key, fingerprint, taskID string, now time.Time) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, e := range s.entries {
+		if now.Sub(e.CreatedAt) > s.ttl {
+			delete(s.entries, k)
+		}
+	}
+	if e, ok := s.entries[key]; ok {
+		if e.Fingerprint != fingerprint {
+			return "", false, errIdempotencyConflict
+		}
+		return e.TaskID, true, nil
+	}
+	s.entries[key] = idempotencyEntry{TaskID: taskID, Fingerprint: fingerprint, CreatedAt: now}
+	return taskID, false, nil
+}
+
+// idempotencyScope builds the store key of a request: the client, the
+// endpoint and the Idempotency-Key header. The client is the caller's
+// principal id or, without one, a hash of its API key (or IP), so no
+// credential is kept in the store. It returns "" when the request has no key.
+func idempotencyScope(r *http.Request, caller guard.Caller) (string, error) {
+	key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if key == "" {
+		return "", nil
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		return "", errIdempotencyKey
+	}
+	client := "principal:" + caller.ID
+	if caller.IsZero() {
+		sum := sha256.Sum256([]byte(getClientKey(r)))
+		client = "client:" + hex.EncodeToString(sum[:])
+	}
+	return client + "|" + r.URL.Path + "|" + key, nil
+}
+
+// requestFingerprint hashes the decoded request, so the same request with
+// different formatting counts as the same.
+func requestFingerprint(req any) string {
+	b, _ := json.Marshal(req)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyStore_ClaimReplayConflictAndExpiry(t *testing.T) {
+	s := newIdempotencyStore(time.Hour)
+	now := time.Now()
+
+	id, replay, err := s.claim("k", "body-a", "task-1", now)
+	require.NoError(t, err)
+	require.False(t, replay)
+	require.Equal(t, "task-1", id)
+
+	id, replay, err = s.claim("k", "body-a", "task-2", now)
+	require.NoError(t, err)
+	require.True(t, replay)
+	require.Equal(t, "task-1", id)
+
+	_, _, err = s.claim("k", "body-b", "task-3", now)
+	require.ErrorIs(t, err, errIdempotencyConflict)
+
+	id, replay, err = s.claim("k", "body-b", "task-4", now.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.False(t, replay, "an expired key can be reused")
+	require.Equal(t, "task-4", id)
+}
+
+func TestIdempotencyScope_DoesNotKeepTheAPIKey(t *testing.T) {
+	req := func(header, value string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/ask", nil)
+		r.Header.Set(header, value)
+		r.Header.Set("Idempotency-Key", "bizum-1")
+		return r
+	}
+
+	scope, err := idempotencyScope(req("X-API-Key", "s3cret-key"), guard.Caller{})
+	require.NoError(t, err)
+	require.NotContains(t, scope, "s3cret-key")
+	other, _ := idempotencyScope(req("X-API-Key", "other-key"), guard.Caller{})
+	require.NotEqual(t, scope, other)
+
+	// un principal conserva sus claves aunque se autentique con otra credencial
+	ana := guard.Caller{ID: "ana"}
+	byKey, _ := idempotencyScope(req("X-API-Key", "ana-key"), ana)
+	byToken, _ := idempotencyScope(req("Authorization", "Bearer ana-token"), ana)
+	require.Equal(t, "principal:ana|/ask|bizum-1", byKey)
+	require.Equal(t, byKey, byToken)
+}
+
+func TestAPIAgent_Ask_IdempotencyKey(t *testing.T) {
+	b := bus.New()
+	inspectorCh := make(chan bus.Message, 4)
+	b.Subscribe("inspector", inspectorCh)
+	apiAgent := NewAPIAgent(b, ui.NewUIStore(), NewMemoryTaskStore(0), nil, nil)
+	mux := http.NewServeMux()
+	apiAgent.RegisterHTTP(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	ask := func(apiKey, idemKey, body string) (*http.Response, map[string]any) {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/ask", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", apiKey)
+		req.Header.Set("Idempotency-Key", idemKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var out map[string]any
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp, out
+	}
+
+	resp, first := ask("alice", "bizum-1", `{"message":"envía 25 euros a Ana"}`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// mismo cuerpo con otro formato: es la misma petición
+	resp, again := ask("alice", "bizum-1", `{ "message": "envía 25 euros a Ana" }`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.Equal(t, "true", resp.Header.Get("Idempotent-Replayed"))
+	require.Equal(t, first["id"], again["id"])
+
+	storeResult(apiAgent.tasks, first["id"].(string), Result{Status: "ok", Data: map[string]any{"sent": true}})
+	resp, done := ask("alice", "bizum-1", `{"message":"envía 25 euros a Ana"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "ok", done["status"])
+
+	resp, _ = ask("alice", "bizum-1", `{"message":"envía 250 euros a Ana"}`)
+	require.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	// la clave es por API key
+	resp, other := ask("bob", "bizum-1", `{"message":"envía 25 euros a Ana"}`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	require.NotEqual(t, first["id"], other["id"])
+
+	resp, _ = ask("alice", strings.Repeat("k", maxIdempotencyKeyLen+1), `{"message":"hola"}`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	require.Len(t, inspectorCh, 2, "only the first request of each key launches a task")
+}
+
+func TestAPIAgent_AskStructured_IdempotencyKeyReturnsOriginalResult(t *testing.T) {
+	b := bus.New()
+	inspectorCh := make(chan bus.Message, 4)
+	b.Subscribe("inspector", inspectorCh)
+	apiAgent := NewAPIAgent(b, ui.NewUIStore(), NewMemoryTaskStore(0), nil, nil)
+	mux := http.NewServeMux()
+	apiAgent.RegisterHTTP(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	go func() {
+		msg := <-inspectorCh
+		storeResult(apiAgent.tasks, msg.Payload["id"].(string), Result{Status: "ok", Data: "enviado"})
+	}()
+
+	post := func() askResponse {
+		body, _ := json.Marshal(askRequest{Operation: "banking.send_bizum", Params: map[string]any{"amount": 25}})
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/ask_structured", bytes.NewReader(body))
+		req.Header.Set("Idempotency-Key", "bizum-2")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var out askResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		return out
+	}
+
+	first := post()
+	second := post()
+	require.Equal(t, "ok", first.Status)
+	require.Equal(t, first, second)
+	require.Len(t, inspectorCh, 0, "the retry must not launch a new pipeline")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/logx"
 )
 
@@ -66,6 +67,12 @@ func (i *Inspector) dispatch(msg bus.Message) {
         if params, ok := msg.Payload["params"].(map[string]any); ok && params != nil {
             payload["params"] = params
         }
+        if dryRun, _ := msg.Payload["dry_run"].(bool); dryRun {
+            payload["dry_run"] = true
+        }
+        if caller, ok := msg.Payload["caller"].(guard.Caller); ok {
+            payload["caller"] = caller
+        }
         i.bus.Send("planner", bus.Message{
             Type: "detect_intent",
             Payload: payload,
@@ -6,6 +6,7 @@ import (
     "time"
 
     "github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+    "github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
 )
 
 func TestInspector_NewTask_ForwardsToPlanner(t *testing.T) {
@@ -46,3 +47,21 @@ func TestInspector_NewTask_ForwardsToPlanner(t *testing.T) {
         t.Fatal("timeout waiting forwarded message to planner")
     }
 }
+
+func TestInspector_NewTask_ForwardsCaller(t *testing.T) {
+    b := bus.New()
+    insp := NewInspector(b)
+    plannerCh := make(chan bus.Message, 1)
+    b.Subscribe("planner", plannerCh)
+
+    caller := guard.Caller{ID: "t1", Role: "teller"}
+    insp.dispatch(bus.Message{
+        Type:    "new_task",
+        Payload: map[string]any{"id": "task-caller", "message": "hola", "caller": caller},
+    })
+
+    msg := <-plannerCh
+    if got, _ := msg.Payload["caller"].(guard.Caller); got.ID != "t1" || got.Role != "teller" {
+        t.Fatalf("caller not forwarded: %#v", msg.Payload["caller"])
+    }
+}
@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/logx"
+)
+
+// Task lifecycle states. "executing" is followed by the step id, e.g.
+// "executing:banking.aml_risk_check".
+const (
+	stateAccepted        = "accepted"
+	stateDetectingIntent = "detecting_intent"
+	stateExtracting      = "extracting_params"
+	stateNeedsInput      = "needs_input"
+	stateValidating      = "validating"
+	stateExecuting       = "executing"
+	statePendingApproval = "pending_approval"
+	stateSummarizing     = "summarizing"
+	stateDone            = "done"
+	stateFailed          = "failed"
+	stateCancelled       = "cancelled"
+)
+
+// transitions lists the phases each phase can move to. Any non-terminal
+// phase can also move to failed or cancelled.
+var transitions = map[string][]string{
+	stateAccepted:        {stateDetectingIntent, stateValidating},
+	stateDetectingIntent: {stateExtracting, stateValidating},
+	stateExtracting:      {stateValidating, stateNeedsInput},
+	stateNeedsInput:      {stateExtracting, stateValidating},
+	// summarizing: every step skipped or simulated, or an analyst-only
+	// pipeline; done: dry-run
+	stateValidating:      {stateNeedsInput, stateExecuting, stateSummarizing, stateDone},
+	stateExecuting:       {stateExecuting, statePendingApproval, stateSummarizing},
+	statePendingApproval: {stateExecuting},
+	stateSummarizing:     {stateDone},
+}
+
+var terminalStates = map[string]bool{stateDone: true, stateFailed: true, stateCancelled: true}
+
+// StateTransition is one entry of a task's state history.
+type StateTransition struct {
+	State string    `json:"state"`
+	At    time.Time `json:"at"`
+}
+
+// TaskState is the current lifecycle state of a task and how it got there.
+type TaskState struct {
+	State    string            `json:"state"`
+	Since    time.Time         `json:"since"`
+	FailedIn string            `json:"failedIn,omitempty"` // phase where the task failed
+	History  []StateTransition `json:"history"`
+}
+
+func phaseOf(state string) string {
+	phase, _, _ := strings.Cut(state, ":")
+	return phase
+}
+
+func canTransition(from, to string) bool {
+	if from == "" {
+		return true // tareas creadas fuera de la API (tests, reanudaciones)
+	}
+	if terminalStates[from] {
+		return false
+	}
+	if to == stateFailed || to == stateCancelled {
+		return true
+	}
+	for _, next := range transitions[phaseOf(from)] {
+		if next == phaseOf(to) {
+			return true
+		}
+	}
+	return false
+}
+
+// setState moves a task to a new lifecycle state. Invalid transitions are
+// logged and ignored, so a late event can never reopen a finished task.
+func setState(tasks TaskStore, id, state string) {
+	var st TaskState
+	changed := false
+	err := tasks.Update(id, func(rec *TaskRecord) bool {
+		changed = transition(rec, state)
+		st = rec.State
+		return changed
+	})
+	if err != nil {
+		logStoreError(id, err)
+	}
+	if changed {
+		publishEvent(id, taskEvent{Kind: eventState, State: st})
+	}
+}
+
+// transition applies a state change to a record and reports whether it
+// was valid.
+func transition(rec *TaskRecord, state string) bool {
+	ts := &rec.State
+	if ts.State == state {
+		return false
+	}
+	if !canTransition(ts.State, state) {
+		logx.Warn("Lifecycle", "id=%s invalid transition %s -> %s", rec.ID, ts.State, state)
+		return false
+	}
+	now := time.Now()
+	if state == stateFailed {
+		ts.FailedIn = ts.State
+	}
+	ts.State = state
+	ts.Since = now
+	ts.History = append(ts.History, StateTransition{State: state, At: now})
+	return true
+}
+
+// getState returns the lifecycle state of a task.
+func getState(tasks TaskStore, id string) (TaskState, bool) {
+	rec, ok := tasks.Get(id)
+	if !ok || rec.State.State == "" {
+		return TaskState{}, false
+	}
+	return rec.State, true
+}
+
+// isCancelled reports whether a task has been cancelled.
+func isCancelled(tasks TaskStore, id string) bool {
+	st, _ := getState(tasks, id)
+	return st.State == stateCancelled
+}
+
+func logStoreError(id string, err error) {
+	logx.Error("TaskStore", "id=%s: %v", id, err)
+}
+
+// stateForResult maps a stored result to the lifecycle state it implies.
+func stateForResult(res Result) string {
+	switch {
+	case res.Status == stateCancelled:
+		return stateCancelled
+	case waitingStatuses[res.Status]:
+		return res.Status // needs_input, pending_approval
+	case res.Err != "" || res.Status == "error" || res.Status == "rejected":
+		return stateFailed
+	default:
+		return stateDone
+	}
+}
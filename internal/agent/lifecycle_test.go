@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+func stateNames(ts TaskState) []string {
+	out := make([]string, 0, len(ts.History))
+	for _, h := range ts.History {
+		out = append(out, h.State)
+	}
+	return out
+}
+
+func TestLifecycle_TerminalStatesAreFinal(t *testing.T) {
+	tasks := NewMemoryTaskStore(0)
+	id := "task-lifecycle-terminal"
+
+	setState(tasks, id, stateAccepted)
+	setState(tasks, id, stateDetectingIntent)
+	setState(tasks, id, stateFailed)
+	setState(tasks, id, stateValidating) // evento tardío: se ignora
+
+	ts, _ := getState(tasks, id)
+	if ts.State != stateFailed || ts.FailedIn != stateDetectingIntent {
+		t.Fatalf("expected failed in detecting_intent, got %+v", ts)
+	}
+	if len(ts.History) != 3 {
+		t.Fatalf("late transition must not be recorded: %v", stateNames(ts))
+	}
+}
+
+func TestLifecycle_RejectsSkippingPhases(t *testing.T) {
+	if canTransition(stateAccepted, stateSummarizing) {
+		t.Fatalf("accepted cannot jump to summarizing")
+	}
+	if !canTransition(statePendingApproval, stateExecuting+":send") {
+		t.Fatalf("an approved task resumes executing")
+	}
+	if !canTransition(stateExecuting+":a", stateExecuting+":b") {
+		t.Fatalf("executing moves from step to step")
+	}
+}
+
+func TestLifecycle_TracksPlannerAndVerifierPhases(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"balance": 10})
+	}))
+	defer ts.Close()
+
+	cfg := clarifyConfig()
+	cfg.Tools["core_balance"] = config.Tool{Name: "core_balance", Method: "GET", URL: ts.URL, Mode: "read", TimeoutMs: 500}
+
+	b := bus.New()
+	verifierCh := make(chan bus.Message, 1)
+	b.Subscribe("verifier", verifierCh)
+	analystCh := make(chan bus.Message, 1)
+	b.Subscribe("analyst", analystCh)
+
+	uiStore := ui.NewUIStore()
+	tasks := NewMemoryTaskStore(0)
+	p := NewPlanner(b, cfg, &scriptedLLM{outputs: []string{"banking.get_balance", `{"accountId":"1"}`, "saldo 10"}}, uiStore, tasks)
+	v := NewVerifier(b, cfg, uiStore, tasks, guard.NewMemoryCounterStore(), nil)
+	a := NewAnalyst(b, p.llmClient, uiStore, tasks)
+
+	id := "task-lifecycle-flow"
+	setState(tasks, id, stateAccepted)
+	p.dispatch(bus.Message{Type: "detect_intent", Payload: map[string]any{"id": id, "message": "saldo de la 1"}})
+	v.dispatch(<-verifierCh)
+	a.dispatch(<-analystCh)
+
+	state, _ := getState(tasks, id)
+	want := []string{stateAccepted, stateDetectingIntent, stateExtracting, stateValidating, "executing:core_balance", stateSummarizing, stateDone}
+	got := stateNames(state)
+	if len(got) != len(want) {
+		t.Fatalf("unexpected history: %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("unexpected history: %v", got)
+		}
+	}
+}
+
+func TestLifecycle_AnalystOnlyPipelineGoesFromValidatingToSummarizing(t *testing.T) {
+	b := bus.New()
+	analystCh := make(chan bus.Message, 1)
+	b.Subscribe("analyst", analystCh)
+	tasks := NewMemoryTaskStore(0)
+	v := NewVerifier(b, &config.Config{}, ui.NewUIStore(), tasks, guard.NewMemoryCounterStore(), nil)
+
+	id := "task-lifecycle-analyst-only"
+	setState(tasks, id, stateAccepted)
+	setState(tasks, id, stateValidating)
+	v.dispatch(bus.Message{Type: "run_pipeline", Payload: map[string]any{
+		"id":       id,
+		"intent":   "x",
+		"pipeline": config.Pipeline{Name: "p", Steps: []config.PipelineStep{{Analyst: true}}},
+		"params":   map[string]string{},
+	}})
+	<-analystCh
+
+	state, _ := getState(tasks, id)
+	if state.State != stateSummarizing {
+		t.Fatalf("expected summarizing after validating, got %v", stateNames(state))
+	}
+}
@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/audit"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/tools"
+)
+
+// statusPlanned is the result status of a dry-run (/plan).
+const statusPlanned = "planned"
+
+// Plan is what AOS would do for a request, as returned by /plan. Nothing in
+// it has been executed.
+type Plan struct {
+	Intent   string            `json:"intent"`
+	Pipeline string            `json:"pipeline"`
+	Params   map[string]string `json:"params"`            // secrets masked
+	Missing  []string          `json:"missing,omitempty"` // required params not found
+	Shadow   bool              `json:"shadow,omitempty"`
+	Steps    []PlanStep        `json:"steps"`
+	Guard    GuardVerdict      `json:"guard"`
+}
+
+// PlanStep is one tool step of a Plan.
+type PlanStep struct {
+	Step          string                 `json:"step"`
+	Tool          string                 `json:"tool"`
+	Mode          string                 `json:"mode,omitempty"`
+	DependsOn     []string               `json:"dependsOn,omitempty"`
+	When          string                 `json:"when,omitempty"`    // not evaluated
+	Params        map[string]string      `json:"params,omitempty"`  // secrets masked
+	Request       *tools.RenderedRequest `json:"request,omitempty"` // secrets masked
+	NeedsApproval bool                   `json:"needsApproval,omitempty"`
+	Compensate    string                 `json:"compensate,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+}
+
+// GuardVerdict is the outcome of guard.ValidateAll for a plan.
+type GuardVerdict struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// buildPlan merges params and renders the request of every tool step of
+// the intent's pipeline, without calling any tool. Outputs of previous
+// steps do not exist yet, so templates over .steps render empty.
+func buildPlan(cfg *config.Config, intentName string, params map[string]string, caller guard.Caller) Plan {
+	intentCfg := cfg.Intents[intentName]
+	pipe := cfg.Pipelines[intentCfg.Pipeline]
+	// un fallo al ligar los params lo vuelve a reportar ValidateAll
+	if bound, err := guard.BindParams(intentCfg, params, caller); err == nil {
+		params = bound
+	}
+	if coerced, err := guard.CoerceParams(intentCfg, params); err == nil {
+		params = coerced
+	}
+	masked := audit.RedactParams(params)
+	if masked == nil {
+		masked = map[string]string{}
+	}
+	plan := Plan{
+		Intent:   intentName,
+		Pipeline: intentCfg.Pipeline,
+		Params:   masked,
+		Missing:  missingParams(intentCfg.Required(), params),
+		Shadow:   intentCfg.ShadowMode,
+		Steps:    []PlanStep{},
+		Guard:    GuardVerdict{OK: true},
+	}
+	if err := guard.ValidateAll(intentCfg, pipe, params, cfg.Tools, caller); err != nil {
+		plan.Guard = GuardVerdict{Error: err.Error()}
+	}
+
+	// Cada step "devuelve" un objeto vacío de strings para que los templates
+	// sobre .steps se rendericen vacíos (no "<no value>") en vez de fallar.
+	run := &pipelineRun{Params: params, Results: map[string]any{}}
+	steps, deps := pipe.ToolSteps()
+	for _, step := range steps {
+		run.Results[step.StepID()] = map[string]string{}
+	}
+	for _, step := range steps {
+		plan.Steps = append(plan.Steps, planStep(cfg, run, step, deps[step.StepID()], intentCfg.ShadowMode))
+	}
+	return plan
+}
+
+// planStep describes one step of a dry-run. A step that cannot be rendered
+// carries the error instead of the request.
+func planStep(cfg *config.Config, run *pipelineRun, step config.PipelineStep, deps []string, shadow bool) PlanStep {
+	ps := PlanStep{
+		Step:      step.StepID(),
+		Tool:      step.Tool,
+		DependsOn: deps,
+		When:      step.When,
+	}
+	if step.Compensate != nil {
+		ps.Compensate = step.Compensate.Tool
+	}
+	t, ok := cfg.Tools[step.Tool]
+	if !ok {
+		ps.Error = "tool " + step.Tool + " no encontrada"
+		return ps
+	}
+	ps.Mode = t.Mode
+	ps.NeedsApproval = t.Mode == "dangerous" && !shadow
+
+	callParams, err := run.callParams(step.WithParams)
+	if err != nil {
+		ps.Error = err.Error()
+		return ps
+	}
+	ps.Params = audit.RedactParams(callParams)
+	rendered, err := tools.RenderRequestWithSteps(t, callParams, run.Results)
+	if err != nil {
+		ps.Error = err.Error()
+		return ps
+	}
+	rendered = maskRequest(rendered)
+	ps.Request = &rendered
+	return ps
+}
@@ -2,6 +2,10 @@ package agent
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
@@ -11,21 +15,45 @@ import (
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
 )
 
+// Intent detectors selectable with INTENT_DETECTOR.
+const (
+	// DetectorPrompt asks the model for the bare intent key, then extracts
+	// the params in a second call.
+	DetectorPrompt = "prompt"
+	// DetectorTools offers each intent as a function (native tool calling)
+	// so intent and params come back in one call. Models without tool
+	// support fall back to DetectorPrompt.
+	DetectorTools = "tools"
+)
+
 type Planner struct {
 	bus       *bus.Bus
 	cfg       *config.Config
 	inbox     chan bus.Message
 	llmClient llm.LLMClient
 	uiStore   *ui.UIStore
+	tasks     TaskStore
+	detector  string
 }
 
-func NewPlanner(b *bus.Bus, cfg *config.Config, llmClient llm.LLMClient, ui *ui.UIStore) *Planner {
+func NewPlanner(b *bus.Bus, cfg *config.Config, llmClient llm.LLMClient, ui *ui.UIStore, tasks TaskStore) *Planner {
+	detector := strings.ToLower(strings.TrimSpace(os.Getenv("INTENT_DETECTOR")))
+	switch detector {
+	case "":
+		detector = DetectorPrompt
+	case DetectorPrompt, DetectorTools:
+	default:
+		logx.Warn("Planner", "INTENT_DETECTOR desconocido %q, se usa %s", detector, DetectorPrompt)
+		detector = DetectorPrompt
+	}
 	return &Planner{
 		bus:       b,
 		cfg:       cfg,
 		inbox:     make(chan bus.Message, 16),
 		llmClient: llmClient,
 		uiStore:   ui,
+		tasks:     tasks,
+		detector:  detector,
 	}
 }
 
@@ -61,6 +89,9 @@ func (p *Planner) dispatch(msg bus.Message) {
 	case "detect_intent":
 		p.handleDetectIntent(msg)
 
+	case "resume_task":
+		p.handleResumeTask(msg)
+
 	case "new_task":
 		// Esto viene del Inspector
 		id := msg.Payload["id"].(string)
@@ -94,24 +125,20 @@ func (p *Planner) handleDetectIntent(msg bus.Message) {
     }
     // Fast path: if operation is provided, skip LLM detection
     var detectedType string
+    var toolParams map[string]string // params de la tool call (DetectorTools)
     if op, ok := msg.Payload["operation"].(string); ok && op != "" {
         detectedType = op
     } else {
-        intentKeys := make(map[string]any)
-        for k := range p.cfg.Intents {
-            intentKeys[k] = true
-        }
-
-        timer := logx.Start(id, "Planner", "DetectIntentLLM")
-        di, err := llm.DetectIntent(taskCtx, p.llmClient, userMsg, intentKeys)
-        timer.End()
+        setState(p.tasks, id, stateDetectingIntent)
+        di, err := p.detectIntent(taskCtx, id, userMsg)
         if err != nil {
             logx.Error("Planner", "[%s] ERROR detecting intent: %v", id, err)
-            storeResult(id, Result{Status: "error", Err: err.Error()})
+            storeResult(p.tasks, id, Result{Status: "error", Err: err.Error()})
             return
         }
         logx.Debug("Planner", "raw intent LLM='%s'", di.Type)
         detectedType = di.Type
+        toolParams = di.Params
     }
 
     intentCfg, ok := p.cfg.Intents[detectedType]
@@ -121,17 +148,15 @@ func (p *Planner) handleDetectIntent(msg bus.Message) {
     }
 
 	// 🔥 2. Seleccionar pipeline
- pipeName := intentCfg.Pipeline
- pipe, ok := p.cfg.Pipelines[pipeName]
- if !ok {
+ if _, ok := p.cfg.Pipelines[intentCfg.Pipeline]; !ok {
      p.storeError(id, "pipeline inexistente para intent")
      return
  }
 
  params := map[string]string{}
 
- // Traemos los required params del YAML
- required := intentCfg.RequiredParams
+ // Traemos los params del YAML (required_params + params tipados)
+ required := intentCfg.ParamNames()
 
  if op, ok := msg.Payload["operation"].(string); ok && op != "" {
      // Structured path: use provided params (map[string]any -> map[string]string)
@@ -143,9 +168,12 @@ func (p *Planner) handleDetectIntent(msg bus.Message) {
          }
      }
  } else {
-     if len(required) > 0 {
+     if toolParams != nil {
+         params = toolParams
+     } else if len(required) > 0 {
+         setState(p.tasks, id, stateExtracting)
          timer := logx.Start(id, "Planner", "ExtractParams")
-         extracted, err := llm.ExtractParams(taskCtx, p.llmClient, userMsg, required)
+         extracted, err := llm.ExtractParamsWithHints(taskCtx, p.llmClient, userMsg, paramHints(intentCfg, required))
          timer.End()
 
          if err != nil {
@@ -158,9 +186,134 @@ func (p *Planner) handleDetectIntent(msg bus.Message) {
      }
  }
 
- if err := guard.ValidateAll(intentCfg, pipe, params, p.cfg.Tools); err != nil {
+	caller, _ := msg.Payload["caller"].(guard.Caller)
+	if dryRun, _ := msg.Payload["dry_run"].(bool); dryRun {
+		p.planDryRun(id, detectedType, params, caller)
+		return
+	}
+	p.planPipeline(id, detectedType, userMsg, params, caller)
+}
+
+// detectIntent detects the intent of userMsg with the configured detector.
+// Params of the result are only set when they came with a tool call and
+// need no separate extraction.
+func (p *Planner) detectIntent(ctx context.Context, id, userMsg string) (*llm.DetectedIntent, error) {
+	if p.detector == DetectorTools {
+		timer := logx.Start(id, "Planner", "DetectIntentTools")
+		di, err := llm.DetectIntentWithTools(ctx, p.llmClient, userMsg, p.intentTools())
+		timer.End()
+		if err == nil {
+			return di, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		logx.Warn("Planner", "[%s] tool calling no disponible (%v), se usa el prompt", id, err)
+	}
+
+	intentKeys := make(map[string]any)
+	for k := range p.cfg.Intents {
+		intentKeys[k] = true
+	}
+	timer := logx.Start(id, "Planner", "DetectIntentLLM")
+	di, err := llm.DetectIntent(ctx, p.llmClient, userMsg, intentKeys)
+	timer.End()
+	if err != nil {
+		return nil, err
+	}
+	di.Params = nil
+	return di, nil
+}
+
+// intentTools describes every intent as a function for
+// llm.DetectIntentWithTools.
+func (p *Planner) intentTools() []llm.IntentTool {
+	out := make([]llm.IntentTool, 0, len(p.cfg.Intents))
+	for name, intent := range p.cfg.Intents {
+		out = append(out, llm.IntentTool{
+			Intent:      name,
+			Description: intent.Description,
+			Params:      paramHints(intent, intent.ParamNames()),
+		})
+	}
+	return out
+}
+
+// planDryRun stores what the pipeline of an intent would do (/plan)
+// instead of handing it to the Verifier. Missing params are reported in the
+// plan rather than asked for.
+func (p *Planner) planDryRun(id, intentName string, params map[string]string, caller guard.Caller) {
+	setState(p.tasks, id, stateValidating)
+	plan := buildPlan(p.cfg, intentName, params, caller)
+	logx.Info("Planner", "id=%s dry-run intent=%s pipeline=%s guard_ok=%v", id, intentName, plan.Pipeline, plan.Guard.OK)
+	p.uiStore.AddEvent(id, "Planner", "dry_run", intentName, "")
+	storeResult(p.tasks, id, Result{Status: statusPlanned, Data: plan})
+}
+
+// planPipeline validates the params of a detected intent and hands the
+// pipeline over to the Verifier. When required params are missing the task
+// is parked in "needs_input" until the user replies via /task/reply.
+func (p *Planner) planPipeline(id, intentName, userMsg string, params map[string]string, caller guard.Caller) {
+	if isCancelled(p.tasks, id) {
+		logx.Info("Planner", "id=%s cancelled, not planning", id)
+		return
+	}
+	setState(p.tasks, id, stateValidating)
+	intentCfg, ok := p.cfg.Intents[intentName]
+	if !ok {
+		p.storeError(id, "intent desconocido para AOS")
+		return
+	}
+	pipeName := intentCfg.Pipeline
+	pipe, ok := p.cfg.Pipelines[pipeName]
+	if !ok {
+		p.storeError(id, "pipeline inexistente para intent")
+		return
+	}
+
+	params, err := guard.BindParams(intentCfg, params, caller)
+	if err != nil {
+		logx.L(id, "Guard", "identity mismatch: %v", err)
+		p.storeError(id, err.Error())
+		return
+	}
+	params, err = guard.CoerceParams(intentCfg, params)
+	if err != nil {
+		logx.L(id, "Guard", "invalid params: %v", err)
+		p.storeError(id, err.Error())
+		return
+	}
+
+	if missing := missingParams(intentCfg.Required(), params); len(missing) > 0 {
+		questions := make([]string, 0, len(missing))
+		for _, name := range missing {
+			questions = append(questions, clarificationQuestion(name))
+		}
+		savePendingInput(p.tasks, id, pendingInput{
+			Intent:    intentName,
+			Message:   userMsg,
+			Params:    params,
+			Missing:   missing,
+			Questions: questions,
+			Caller:    caller,
+			Since:     time.Now(),
+		})
+		logx.Info("Planner", "id=%s intent=%s needs input: %v", id, intentName, missing)
+		p.uiStore.AddEvent(id, "Planner", "needs_input", strings.Join(missing, ", "), "")
+		storeResult(p.tasks, id, Result{
+			Status: "needs_input",
+			Data: map[string]any{
+				"intent":    intentName,
+				"missing":   missing,
+				"questions": questions,
+			},
+		})
+		return
+	}
+
+ if err := guard.ValidateAll(intentCfg, pipe, params, p.cfg.Tools, caller); err != nil {
      logx.L(id, "Guard", "validation failed: %v", err)
-     storeResult(id, Result{
+     storeResult(p.tasks, id, Result{
          Status: "error",
          Err:    err.Error(),
      })
@@ -168,8 +321,8 @@ func (p *Planner) handleDetectIntent(msg bus.Message) {
  }
 
  logx.Info("Planner", "id=%s intent=%s pipeline=%s params=%v",
-        id, detectedType, pipeName, params)
-    p.uiStore.AddEvent(id, "Planner", "intent", detectedType, "")
+        id, intentName, pipeName, params)
+    p.uiStore.AddEvent(id, "Planner", "intent", intentName, "")
 
 	timer2 := logx.Start(id, "Planner", "DispatchPipeline")
 
@@ -178,17 +331,69 @@ func (p *Planner) handleDetectIntent(msg bus.Message) {
 		Type: "run_pipeline",
         Payload: map[string]any{
             "id":       id,
-            "intent":   detectedType,
+            "intent":   intentName,
             "pipeline": pipe,
             "params":   params,
+            "caller":   caller,
         },
     })
 	timer2.End()
 
 }
 
+// handleResumeTask merges the user's reply into a task parked in
+// "needs_input" and resumes planning with the same task id.
+func (p *Planner) handleResumeTask(msg bus.Message) {
+	id := msg.Payload["id"].(string)
+
+	pend, ok := takePendingInput(p.tasks, id)
+	if !ok {
+		logx.Warn("Planner", "resume_task id=%s is not waiting for input", id)
+		return
+	}
+
+	params := make(map[string]string, len(pend.Params))
+	for k, v := range pend.Params {
+		params[k] = v
+	}
+	if mp, ok := msg.Payload["params"].(map[string]any); ok {
+		for k, v := range mp {
+			if s := strings.TrimSpace(fmt.Sprintf("%v", v)); v != nil && s != "" {
+				params[k] = s
+			}
+		}
+	}
+
+	// Free-text reply: extract only what is still missing.
+	if reply, _ := msg.Payload["message"].(string); reply != "" {
+		if still := missingParams(pend.Missing, params); len(still) > 0 {
+			taskCtx, _ := GetTaskContext(id)
+			if taskCtx == nil {
+				taskCtx = context.Background()
+			}
+			setState(p.tasks, id, stateExtracting)
+			timer := logx.Start(id, "Planner", "ExtractParams")
+			extracted, err := llm.ExtractParamsWithHints(taskCtx, p.llmClient, reply, paramHints(p.cfg.Intents[pend.Intent], still))
+			timer.End()
+			if err != nil {
+				logx.Error("Planner", "[%s] ERROR extracting params from reply: %v", id, err)
+			}
+			for k, v := range extracted {
+				if strings.TrimSpace(v) != "" {
+					params[k] = v
+				}
+			}
+		}
+	}
+
+	logx.Info("Planner", "id=%s resuming intent=%s", id, pend.Intent)
+	p.uiStore.AddEvent(id, "Planner", "reply", "parámetros recibidos del usuario", "")
+	deleteResult(p.tasks, id)
+	p.planPipeline(id, pend.Intent, pend.Message, params, pend.Caller)
+}
+
 func (p *Planner) storeError(id string, errMsg string) {
-	storeResult(id, Result{
+	storeResult(p.tasks, id, Result{
 		Status: "error",
 		Err:    errMsg,
 	})
@@ -21,7 +21,7 @@ func TestPlanner_NewTask_DispatchesDetectIntent(t *testing.T) {
     b := bus.New()
     // Minimal cfg and UI not used in this path
     cfg := &config.Config{Intents: map[string]config.Intent{}}
-    p := NewPlanner(b, cfg, llmNewTaskDummy{}, nil)
+    p := NewPlanner(b, cfg, llmNewTaskDummy{}, nil, NewMemoryTaskStore(0))
 
     // Capture messages sent to "planner"
     plannerCh := make(chan bus.Message, 1)
@@ -63,7 +63,7 @@ func TestPlanner_HandleDetectIntent_UnknownIntentStoresError(t *testing.T) {
     cfg := &config.Config{Intents: map[string]config.Intent{}}
 
     b := bus.New()
-    p := NewPlanner(b, cfg, llmNewTaskDummy{}, nil)
+    p := NewPlanner(b, cfg, llmNewTaskDummy{}, nil, NewMemoryTaskStore(0))
 
     // Call handleDetectIntent through the dispatch loop
     ctx, cancel := context.WithCancel(context.Background())
@@ -83,9 +83,7 @@ func TestPlanner_HandleDetectIntent_UnknownIntentStoresError(t *testing.T) {
     deadline := time.Now().Add(1 * time.Second)
     for time.Now().Before(deadline) {
         time.Sleep(20 * time.Millisecond)
-        resultsMu.Lock()
-        res, ok := results[id]
-        resultsMu.Unlock()
+        res, ok := getResult(p.tasks, id)
         if ok {
             if res.Status != "error" || res.Err == "" {
                 t.Fatalf("expected error result stored, got: %+v", res)
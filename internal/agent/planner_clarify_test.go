@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+// scriptedLLM returns its outputs in order, one per Chat call.
+type scriptedLLM struct {
+	mu      sync.Mutex
+	outputs []string
+}
+
+func (s *scriptedLLM) Ping(ctx context.Context) error { return nil }
+func (s *scriptedLLM) Chat(ctx context.Context, prompt string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.outputs) == 0 {
+		return "", nil
+	}
+	out := s.outputs[0]
+	s.outputs = s.outputs[1:]
+	return out, nil
+}
+
+func clarifyConfig() *config.Config {
+	return &config.Config{
+		Tools: map[string]config.Tool{
+			"core_balance": {Name: "core_balance", Mode: "read"},
+		},
+		Pipelines: map[string]config.Pipeline{
+			"pipeline_balance": {Name: "pipeline_balance", Steps: []config.PipelineStep{{Tool: "core_balance"}}},
+		},
+		Intents: map[string]config.Intent{
+			"banking.get_balance": {
+				Type:           "banking.get_balance",
+				Pipeline:       "pipeline_balance",
+				RequiredParams: []string{"accountId"},
+			},
+		},
+	}
+}
+
+func TestPlanner_MissingParam_StoresNeedsInputWithQuestions(t *testing.T) {
+	b := bus.New()
+	llmc := &scriptedLLM{outputs: []string{"banking.get_balance", `{"accountId":""}`}}
+	p := NewPlanner(b, clarifyConfig(), llmc, ui.NewUIStore(), NewMemoryTaskStore(0))
+
+	id := "task-clarify-1"
+	p.dispatch(bus.Message{
+		Type:    "detect_intent",
+		Payload: map[string]any{"id": id, "message": "dime mi saldo"},
+	})
+
+	res, ok := getResult(p.tasks, id)
+	if !ok {
+		t.Fatalf("expected result for id=%s", id)
+	}
+	if res.Status != "needs_input" {
+		t.Fatalf("expected needs_input, got %+v", res)
+	}
+	data := res.Data.(map[string]any)
+	missing := data["missing"].([]string)
+	if len(missing) != 1 || missing[0] != "accountId" {
+		t.Fatalf("unexpected missing params: %#v", missing)
+	}
+	if qs := data["questions"].([]string); len(qs) != 1 || qs[0] == "" {
+		t.Fatalf("expected one follow-up question, got %#v", qs)
+	}
+	if !hasPendingInput(p.tasks, id) {
+		t.Fatalf("expected task to be waiting for input")
+	}
+}
+
+func TestPlanner_ResumeTask_MergesParamsAndDispatchesPipeline(t *testing.T) {
+	b := bus.New()
+	llmc := &scriptedLLM{outputs: []string{"banking.get_balance", `{"accountId":null}`}}
+	p := NewPlanner(b, clarifyConfig(), llmc, ui.NewUIStore(), NewMemoryTaskStore(0))
+
+	verifierCh := make(chan bus.Message, 1)
+	b.Subscribe("verifier", verifierCh)
+
+	id := "task-clarify-2"
+	p.dispatch(bus.Message{
+		Type:    "detect_intent",
+		Payload: map[string]any{"id": id, "message": "dime mi saldo"},
+	})
+	if res, _ := getResult(p.tasks, id); res.Status != "needs_input" {
+		t.Fatalf("expected needs_input before reply, got %+v", res)
+	}
+
+	p.dispatch(bus.Message{
+		Type: "resume_task",
+		Payload: map[string]any{
+			"id":     id,
+			"params": map[string]any{"accountId": "1234"},
+		},
+	})
+
+	select {
+	case msg := <-verifierCh:
+		if msg.Type != "run_pipeline" || msg.Payload["id"].(string) != id {
+			t.Fatalf("unexpected message to verifier: %#v", msg)
+		}
+		params := msg.Payload["params"].(map[string]string)
+		if params["accountId"] != "1234" {
+			t.Fatalf("expected merged accountId, got %#v", params)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("timeout waiting run_pipeline after reply")
+	}
+	if _, ok := getResult(p.tasks, id); ok {
+		t.Fatalf("needs_input result should be cleared once the task resumes")
+	}
+	if hasPendingInput(p.tasks, id) {
+		t.Fatalf("pending input should be consumed by the reply")
+	}
+}
+
+func TestPlanner_ResumeTask_FreeTextReplyExtractsMissing(t *testing.T) {
+	b := bus.New()
+	llmc := &scriptedLLM{outputs: []string{"banking.get_balance", `{}`, `{"accountId":"999"}`}}
+	p := NewPlanner(b, clarifyConfig(), llmc, ui.NewUIStore(), NewMemoryTaskStore(0))
+
+	verifierCh := make(chan bus.Message, 1)
+	b.Subscribe("verifier", verifierCh)
+
+	id := "task-clarify-3"
+	p.dispatch(bus.Message{
+		Type:    "detect_intent",
+		Payload: map[string]any{"id": id, "message": "dime mi saldo"},
+	})
+	p.dispatch(bus.Message{
+		Type:    "resume_task",
+		Payload: map[string]any{"id": id, "message": "la cuenta es 999"},
+	})
+
+	select {
+	case msg := <-verifierCh:
+		if msg.Payload["params"].(map[string]string)["accountId"] != "999" {
+			t.Fatalf("expected accountId extracted from reply, got %#v", msg.Payload["params"])
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("timeout waiting run_pipeline after free-text reply")
+	}
+}
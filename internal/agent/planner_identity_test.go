@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/auth"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+	"github.com/stretchr/testify/require"
+)
+
+// identityConfig binds fromPhone to the caller and requires the debited
+// account to be one of theirs.
+func identityConfig() *config.Config {
+	cfg := planConfig()
+	intent := cfg.Intents["banking.send_bizum"]
+	intent.Params = []config.ParamSpec{
+		{Name: "fromPhone", Type: config.ParamPhone, From: "caller.phone"},
+		{Name: "accountId", OwnedBy: "caller.accountIds"},
+	}
+	cfg.Intents["banking.send_bizum"] = intent
+	return cfg
+}
+
+var identityCaller = guard.Caller{ID: "ana", Attrs: map[string]any{
+	"phone":      "+34600111222",
+	"accountIds": []any{"ES01"},
+}}
+
+func TestPlanner_BindsParamsToCaller(t *testing.T) {
+	b := bus.New()
+	verifierCh := make(chan bus.Message, 1)
+	b.Subscribe("verifier", verifierCh)
+	p := NewPlanner(b, identityConfig(), &scriptedLLM{}, ui.NewUIStore(), NewMemoryTaskStore(0))
+
+	send := func(id string, params map[string]any) {
+		p.dispatch(bus.Message{Type: "detect_intent", Payload: map[string]any{
+			"id":        id,
+			"operation": "banking.send_bizum",
+			"params":    params,
+			"caller":    identityCaller,
+		}})
+	}
+
+	send("task-identity-ok", map[string]any{"amount": "20", "toPhone": "600999888", "accountId": "ES01"})
+	msg := <-verifierCh
+	require.Equal(t, "+34600111222", msg.Payload["params"].(map[string]string)["fromPhone"])
+
+	send("task-identity-spoof", map[string]any{"amount": "20", "toPhone": "600999888", "accountId": "ES01", "fromPhone": "+34611000000"})
+	res, _ := getResult(p.tasks, "task-identity-spoof")
+	require.Equal(t, "error", res.Status)
+	require.Equal(t, "parámetro 'fromPhone' rechazado: no coincide con la identidad del llamante", res.Err)
+
+	send("task-identity-account", map[string]any{"amount": "20", "toPhone": "600999888", "accountId": "ES99"})
+	res, _ = getResult(p.tasks, "task-identity-account")
+	require.Equal(t, "error", res.Status)
+	require.Equal(t, `parámetro 'accountId' rechazado: "ES99" no pertenece al llamante`, res.Err)
+	require.Len(t, verifierCh, 0)
+}
+
+func TestAPIAgent_ForwardsAuthenticatedCaller(t *testing.T) {
+	sum := sha256.Sum256([]byte("ana-key"))
+	authn, err := auth.New("", []auth.Principal{{
+		ID: "ana", KeySHA256: hex.EncodeToString(sum[:]), Attrs: map[string]any{"phone": "+34600111222"},
+	}}, "")
+	require.NoError(t, err)
+
+	b := bus.New()
+	inspectorCh := make(chan bus.Message, 1)
+	b.Subscribe("inspector", inspectorCh)
+	a := NewAPIAgent(b, ui.NewUIStore(), NewMemoryTaskStore(0), nil, authn)
+
+	ask := func(key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/ask", bytes.NewBufferString(`{"message":"haz un bizum"}`))
+		r.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		a.handleAsk(rr, r)
+		return rr
+	}
+
+	require.Equal(t, http.StatusUnauthorized, ask("").Code)
+	require.Equal(t, http.StatusUnauthorized, ask("other-key").Code)
+
+	require.Equal(t, http.StatusAccepted, ask("ana-key").Code)
+	msg := <-inspectorCh
+	caller, ok := msg.Payload["caller"].(guard.Caller)
+	require.True(t, ok)
+	require.Equal(t, "ana", caller.ID)
+	require.Equal(t, "+34600111222", caller.Attrs["phone"])
+}
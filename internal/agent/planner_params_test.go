@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+	"github.com/stretchr/testify/require"
+)
+
+// promptRecorder is a scriptedLLM that also keeps the prompts it got.
+type promptRecorder struct {
+	scriptedLLM
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (r *promptRecorder) Chat(ctx context.Context, prompt string) (string, error) {
+	r.mu.Lock()
+	r.prompts = append(r.prompts, prompt)
+	r.mu.Unlock()
+	return r.scriptedLLM.Chat(ctx, prompt)
+}
+
+func typedBizumConfig() *config.Config {
+	cfg := planConfig()
+	min, max := 0.01, 100.0
+	intent := cfg.Intents["banking.send_bizum"]
+	intent.RequiredParams = nil
+	intent.Params = []config.ParamSpec{
+		{Name: "amount", Type: config.ParamDecimal, Min: &min, Max: &max, Description: "Importe en euros"},
+		{Name: "toPhone", Type: config.ParamPhone},
+		{Name: "concept", Optional: true},
+	}
+	cfg.Intents["banking.send_bizum"] = intent
+	return cfg
+}
+
+func TestPlanner_TypedParams_CoercedAndHintedInPrompt(t *testing.T) {
+	b := bus.New()
+	verifierCh := make(chan bus.Message, 1)
+	b.Subscribe("verifier", verifierCh)
+	llmc := &promptRecorder{scriptedLLM: scriptedLLM{outputs: []string{
+		"banking.send_bizum",
+		`{"amount":"25,50 €","toPhone":"600 111 222","concept":""}`,
+	}}}
+	p := NewPlanner(b, typedBizumConfig(), llmc, ui.NewUIStore(), NewMemoryTaskStore(0))
+
+	p.dispatch(bus.Message{
+		Type:    "detect_intent",
+		Payload: map[string]any{"id": "task-typed-ok", "message": "envía 25,50 € al 600 111 222"},
+	})
+
+	msg := <-verifierCh
+	params := msg.Payload["params"].(map[string]string)
+	require.Equal(t, "25.5", params["amount"])
+	require.Equal(t, "600111222", params["toPhone"])
+
+	require.Len(t, llmc.prompts, 2)
+	extract := llmc.prompts[1]
+	require.Contains(t, extract, `["amount","toPhone","concept"]`)
+	require.Contains(t, extract, "- amount: decimal, min 0.01, max 100. Importe en euros")
+}
+
+func TestPlanner_TypedParams_InvalidValueIsAnError(t *testing.T) {
+	b := bus.New()
+	llmc := &scriptedLLM{outputs: []string{
+		"banking.send_bizum",
+		`{"amount":"muchos","toPhone":"600111222","concept":""}`,
+	}}
+	p := NewPlanner(b, typedBizumConfig(), llmc, ui.NewUIStore(), NewMemoryTaskStore(0))
+
+	id := "task-typed-bad"
+	p.dispatch(bus.Message{
+		Type:    "detect_intent",
+		Payload: map[string]any{"id": id, "message": "envía muchos euros"},
+	})
+
+	res, ok := getResult(p.tasks, id)
+	require.True(t, ok)
+	require.Equal(t, "error", res.Status)
+	require.True(t, strings.Contains(res.Err, "amount"), res.Err)
+}
+
+func TestPlanner_TypedParams_InvalidValueIsRepaired(t *testing.T) {
+	b := bus.New()
+	verifierCh := make(chan bus.Message, 1)
+	b.Subscribe("verifier", verifierCh)
+	llmc := &promptRecorder{scriptedLLM: scriptedLLM{outputs: []string{
+		"banking.send_bizum",
+		`{"amount":"muchos","toPhone":"600111222","concept":""}`,
+		`{"amount":"30","toPhone":"600111222","concept":""}`,
+	}}}
+	p := NewPlanner(b, typedBizumConfig(), llmc, ui.NewUIStore(), NewMemoryTaskStore(0))
+
+	p.dispatch(bus.Message{
+		Type:    "detect_intent",
+		Payload: map[string]any{"id": "task-typed-repair", "message": "envía treinta euros al 600111222"},
+	})
+
+	msg := <-verifierCh
+	params := msg.Payload["params"].(map[string]string)
+	require.Equal(t, "30", params["amount"])
+	require.Len(t, llmc.prompts, 3)
+	require.Contains(t, llmc.prompts[2], `amount: "muchos" no es un número`)
+}
+
+func TestBuildPlan_TypedParamsAreCoerced(t *testing.T) {
+	plan := buildPlan(typedBizumConfig(), "banking.send_bizum", map[string]string{"amount": "10,5"}, guard.Caller{})
+
+	require.Equal(t, "10.5", plan.Params["amount"])
+	require.Equal(t, []string{"toPhone"}, plan.Missing)
+}
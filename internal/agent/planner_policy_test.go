@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+	"github.com/stretchr/testify/require"
+)
+
+func policyConfig() *config.Config {
+	cfg := planConfig()
+	intent := cfg.Intents["banking.send_bizum"]
+	intent.Policies = []config.Policy{{
+		Name:    "customer_limit",
+		Rule:    `amount <= 50 || caller.role == "teller"`,
+		Message: "máximo 50 € por Bizum",
+	}}
+	cfg.Intents["banking.send_bizum"] = intent
+	return cfg
+}
+
+func TestPlanner_PolicyViolation_IsAnErrorNamingTheRule(t *testing.T) {
+	b := bus.New()
+	verifierCh := make(chan bus.Message, 1)
+	b.Subscribe("verifier", verifierCh)
+	p := NewPlanner(b, policyConfig(), &scriptedLLM{}, ui.NewUIStore(), NewMemoryTaskStore(0))
+
+	send := func(id string, caller guard.Caller) {
+		p.dispatch(bus.Message{Type: "detect_intent", Payload: map[string]any{
+			"id":        id,
+			"operation": "banking.send_bizum",
+			"params":    map[string]any{"amount": "80", "toPhone": "600111222"},
+			"caller":    caller,
+		}})
+	}
+
+	send("task-policy-customer", guard.Caller{ID: "c1", Role: "customer"})
+	res, ok := getResult(p.tasks, "task-policy-customer")
+	require.True(t, ok)
+	require.Equal(t, "error", res.Status)
+	require.Equal(t, "política 'customer_limit' violada: máximo 50 € por Bizum", res.Err)
+	require.Len(t, verifierCh, 0)
+
+	send("task-policy-teller", guard.Caller{ID: "t1", Role: "teller"})
+	msg := <-verifierCh
+	require.Equal(t, "task-policy-teller", msg.Payload["id"])
+}
+
+func TestPlanner_ResumedTaskKeepsCaller(t *testing.T) {
+	b := bus.New()
+	verifierCh := make(chan bus.Message, 1)
+	b.Subscribe("verifier", verifierCh)
+	p := NewPlanner(b, policyConfig(), &scriptedLLM{}, ui.NewUIStore(), NewMemoryTaskStore(0))
+
+	id := "task-policy-resume"
+	p.dispatch(bus.Message{Type: "detect_intent", Payload: map[string]any{
+		"id":        id,
+		"operation": "banking.send_bizum",
+		"params":    map[string]any{"amount": "80"},
+		"caller":    guard.Caller{ID: "t1", Role: "teller"},
+	}})
+	require.True(t, hasPendingInput(p.tasks, id))
+
+	p.dispatch(bus.Message{Type: "resume_task", Payload: map[string]any{
+		"id":     id,
+		"params": map[string]any{"toPhone": "600111222"},
+	}})
+	msg := <-verifierCh
+	require.Equal(t, id, msg.Payload["id"])
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/llm"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+	"github.com/stretchr/testify/require"
+)
+
+// toolCallingLLM answers ChatTools with a fixed call; Chat falls back to
+// the scripted outputs.
+type toolCallingLLM struct {
+	scriptedLLM
+	call      *llm.ToolCall
+	toolCalls int
+}
+
+func (t *toolCallingLLM) ChatTools(ctx context.Context, prompt string, tools []llm.ToolSpec) (*llm.ToolCall, error) {
+	t.toolCalls++
+	return t.call, nil
+}
+
+func TestPlanner_ToolsDetector_IntentAndParamsInOneCall(t *testing.T) {
+	b := bus.New()
+	verifierCh := make(chan bus.Message, 1)
+	b.Subscribe("verifier", verifierCh)
+	llmc := &toolCallingLLM{call: &llm.ToolCall{
+		Name:      "banking__send_bizum",
+		Arguments: map[string]any{"amount": "25,50", "toPhone": "600 111 222"},
+	}}
+	p := NewPlanner(b, typedBizumConfig(), llmc, ui.NewUIStore(), NewMemoryTaskStore(0))
+	p.detector = DetectorTools
+
+	p.dispatch(bus.Message{
+		Type:    "detect_intent",
+		Payload: map[string]any{"id": "task-tools", "message": "envía 25,50 al 600 111 222"},
+	})
+
+	msg := <-verifierCh
+	require.Equal(t, "banking.send_bizum", msg.Payload["intent"])
+	params := msg.Payload["params"].(map[string]string)
+	require.Equal(t, "25.5", params["amount"])
+	require.Equal(t, "600111222", params["toPhone"])
+	require.Equal(t, 1, llmc.toolCalls)
+	require.Len(t, llmc.outputs, 0, "no prompt-based call expected")
+}
+
+func TestPlanner_ToolsDetector_InvalidArgumentsAreExtracted(t *testing.T) {
+	b := bus.New()
+	verifierCh := make(chan bus.Message, 1)
+	b.Subscribe("verifier", verifierCh)
+	llmc := &toolCallingLLM{
+		call:        &llm.ToolCall{Name: "banking__send_bizum", Arguments: map[string]any{"amount": "muchos"}},
+		scriptedLLM: scriptedLLM{outputs: []string{`{"amount":"30","toPhone":"600111222","concept":""}`}},
+	}
+	p := NewPlanner(b, typedBizumConfig(), llmc, ui.NewUIStore(), NewMemoryTaskStore(0))
+	p.detector = DetectorTools
+
+	p.dispatch(bus.Message{
+		Type:    "detect_intent",
+		Payload: map[string]any{"id": "task-tools-extract", "message": "envía treinta euros al 600111222"},
+	})
+
+	msg := <-verifierCh
+	require.Equal(t, "30", msg.Payload["params"].(map[string]string)["amount"])
+}
+
+func TestPlanner_ToolsDetector_FallsBackToPrompt(t *testing.T) {
+	b := bus.New()
+	verifierCh := make(chan bus.Message, 1)
+	b.Subscribe("verifier", verifierCh)
+	// scriptedLLM has no ChatTools: the prompt detector is used instead
+	llmc := &scriptedLLM{outputs: []string{
+		"banking.send_bizum",
+		`{"amount":"10","toPhone":"600111222","concept":""}`,
+	}}
+	p := NewPlanner(b, typedBizumConfig(), llmc, ui.NewUIStore(), NewMemoryTaskStore(0))
+	p.detector = DetectorTools
+
+	p.dispatch(bus.Message{
+		Type:    "detect_intent",
+		Payload: map[string]any{"id": "task-tools-fallback", "message": "envía 10 al 600111222"},
+	})
+
+	msg := <-verifierCh
+	require.Equal(t, "banking.send_bizum", msg.Payload["intent"])
+	require.Equal(t, "10", msg.Payload["params"].(map[string]string)["amount"])
+}
+
+func TestNewPlanner_DetectorFromEnv(t *testing.T) {
+	t.Setenv("INTENT_DETECTOR", "Tools")
+	require.Equal(t, DetectorTools, NewPlanner(bus.New(), planConfig(), nil, ui.NewUIStore(), NewMemoryTaskStore(0)).detector)
+	t.Setenv("INTENT_DETECTOR", "magic")
+	require.Equal(t, DetectorPrompt, NewPlanner(bus.New(), planConfig(), nil, ui.NewUIStore(), NewMemoryTaskStore(0)).detector)
+}
@@ -1,7 +1,6 @@
 package agent
 
 import (
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,47 +12,89 @@ type Result struct {
 	Err    string      `json:"error,omitempty"`
 }
 
-var (
-    resultsMu sync.Mutex
-    results   = make(map[string]Result)
-)
+// waitingStatuses are results of tasks that are parked, not finished: the
+// task will resume and replace them.
+var waitingStatuses = map[string]bool{
+	"needs_input":      true,
+	"pending_approval": true,
+}
 
-func storeResult(id string, res Result) {
-    resultsMu.Lock()
-    defer resultsMu.Unlock()
-    results[id] = res
+// storeResult records the result of a task, moves its lifecycle to the
+// state the result implies and releases its context: a finished task no
+// longer needs it and a parked one gets a new context when it resumes.
+// Once a task is cancelled only a "cancelled" result can replace that
+// status, so late results of in-flight work are dropped.
+func storeResult(tasks TaskStore, id string, res Result) {
+	var st TaskState
+	stored, moved := false, false
+	err := tasks.Update(id, func(rec *TaskRecord) bool {
+		if rec.State.State == stateCancelled && res.Status != stateCancelled {
+			return false
+		}
+		r := res
+		rec.Result = &r
+		moved = transition(rec, stateForResult(res))
+		st = rec.State
+		stored = true
+		return true
+	})
+	if err != nil {
+		logStoreError(id, err)
+	}
+	if moved {
+		publishEvent(id, taskEvent{Kind: eventState, State: st})
+	}
+	if stored {
+		publishResult(id, res)
+	}
+	CancelTask(id)
 }
 
 // getResult retrieves a stored result by id.
 // The second return value indicates whether a result was found.
-func getResult(id string) (Result, bool) {
-    resultsMu.Lock()
-    defer resultsMu.Unlock()
-    r, ok := results[id]
-    return r, ok
+func getResult(tasks TaskStore, id string) (Result, bool) {
+	rec, ok := tasks.Get(id)
+	if !ok || rec.Result == nil {
+		return Result{}, false
+	}
+	return *rec.Result, true
 }
 
-// deleteResult removes a result from the store to avoid unbounded growth.
-func deleteResult(id string) {
-    resultsMu.Lock()
-    defer resultsMu.Unlock()
-    delete(results, id)
+// deleteResult clears the result of a task that resumes after being parked.
+// The task record and its lifecycle are kept.
+func deleteResult(tasks TaskStore, id string) {
+	err := tasks.Update(id, func(rec *TaskRecord) bool {
+		if rec.Result == nil {
+			return false
+		}
+		rec.Result = nil
+		return true
+	})
+	if err != nil {
+		logStoreError(id, err)
+	}
 }
 
-func waitForResult(id string, timeout time.Duration) Result {
-    deadline := time.Now().Add(timeout)
-    for time.Now().Before(deadline) {
-        time.Sleep(100 * time.Millisecond)
-        resultsMu.Lock()
-		r, ok := results[id]
-		resultsMu.Unlock()
-		if ok {
-			return r
-		}
+// waitForResult blocks until the task stores a result or the timeout
+// expires. It is woken up by storeResult instead of polling the store.
+func waitForResult(tasks TaskStore, id string, timeout time.Duration) Result {
+	w, stop := watchTask(id, false)
+	defer stop()
+
+	// El resultado pudo guardarse antes de registrar el watcher.
+	if r, ok := getResult(tasks, id); ok {
+		return r
 	}
-	return Result{
-		Status: "timeout",
-		Err:    "timeout esperando resultado",
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-w.result:
+		return r
+	case <-timer.C:
+		return Result{
+			Status: "timeout",
+			Err:    "timeout esperando resultado",
+		}
 	}
 }
 
@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+)
+
+// Roles used when APPROVER_ROLES or AUDITOR_ROLES are unset.
+const (
+	defaultApproverRoles = "approver"
+	defaultAuditorRoles  = "auditor"
+)
+
+// rolesFromEnv parses a comma-separated list of roles from name, or from
+// def when the variable is unset.
+func rolesFromEnv(name, def string) map[string]bool {
+	raw, ok := os.LookupEnv(name)
+	if !ok {
+		raw = def
+	}
+	roles := map[string]bool{}
+	for _, r := range strings.Split(raw, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			roles[r] = true
+		}
+	}
+	return roles
+}
+
+// hasRole reports whether an identified caller holds one of roles.
+func hasRole(caller guard.Caller, roles map[string]bool) bool {
+	return !caller.IsZero() && caller.Role != "" && roles[caller.Role]
+}
+
+// authorizeApprover authenticates the request and checks that the caller
+// holds an APPROVER_ROLES role; otherwise it writes 401 or 403.
+func (a *APIAgent) authorizeApprover(w http.ResponseWriter, r *http.Request) (guard.Caller, bool) {
+	caller, ok := a.authenticate(w, r)
+	if !ok {
+		return caller, false
+	}
+	if caller.IsZero() {
+		http.Error(w, "la aprobación requiere un llamante identificado", http.StatusForbidden)
+		return caller, false
+	}
+	if !hasRole(caller, a.approverRoles) {
+		http.Error(w, "el llamante no tiene un rol de aprobador", http.StatusForbidden)
+		return caller, false
+	}
+	return caller, true
+}
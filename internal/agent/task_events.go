@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"sync"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/logx"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+// Kinds of taskEvent.
+const (
+	eventUI    = "ui"
+	eventState = "state"
+)
+
+// taskEvent is a change of a task pushed to its watchers as it happens.
+type taskEvent struct {
+	Kind  string
+	UI    ui.Event
+	State TaskState
+}
+
+// watcherBuffer is how many events a watcher can fall behind before new
+// ones are dropped.
+const watcherBuffer = 64
+
+// taskWatcher receives the changes of one task. Events are dropped if the
+// watcher falls behind, but the latest result is always kept.
+type taskWatcher struct {
+	events chan taskEvent // nil when the watcher only wants the result
+	result chan Result    // holds only the latest result
+}
+
+// Per-task notification registry, like the task context registry.
+var (
+	watchersMu sync.Mutex
+	watchers   = make(map[string]map[*taskWatcher]struct{})
+)
+
+// watchTask registers a watcher for a task. The returned function removes
+// it and must always be called.
+func watchTask(id string, withEvents bool) (*taskWatcher, func()) {
+	w := &taskWatcher{result: make(chan Result, 1)}
+	if withEvents {
+		w.events = make(chan taskEvent, watcherBuffer)
+	}
+	watchersMu.Lock()
+	if watchers[id] == nil {
+		watchers[id] = make(map[*taskWatcher]struct{})
+	}
+	watchers[id][w] = struct{}{}
+	watchersMu.Unlock()
+
+	return w, func() {
+		watchersMu.Lock()
+		delete(watchers[id], w)
+		if len(watchers[id]) == 0 {
+			delete(watchers, id)
+		}
+		watchersMu.Unlock()
+	}
+}
+
+// publishEvent pushes a change of a task to the watchers that want events.
+func publishEvent(id string, ev taskEvent) {
+	watchersMu.Lock()
+	defer watchersMu.Unlock()
+	for w := range watchers[id] {
+		if w.events == nil {
+			continue
+		}
+		select {
+		case w.events <- ev:
+		default:
+			logx.Warn("Stream", "id=%s watcher is behind, dropping %s event", id, ev.Kind)
+		}
+	}
+}
+
+// publishUIEvent pushes a timeline event of the UIStore to the watchers.
+func publishUIEvent(id string, ev ui.Event) {
+	publishEvent(id, taskEvent{Kind: eventUI, UI: ev})
+}
+
+// publishResult hands the result of a task to its watchers, replacing any
+// result they have not read yet.
+func publishResult(id string, res Result) {
+	watchersMu.Lock()
+	defer watchersMu.Unlock()
+	for w := range watchers[id] {
+		select {
+		case <-w.result:
+		default:
+		}
+		w.result <- res
+	}
+}
+
+// watcherCount reports how many watchers are registered for a task.
+func watcherCount(id string) int {
+	watchersMu.Lock()
+	defer watchersMu.Unlock()
+	return len(watchers[id])
+}
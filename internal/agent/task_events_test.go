@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitForResult_WakesUpOnStore(t *testing.T) {
+	tasks := NewMemoryTaskStore(0)
+	id := "task-wait-wakeup"
+	go func() {
+		for watcherCount(id) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		storeResult(tasks, id, Result{Status: "ok"})
+	}()
+
+	start := time.Now()
+	res := waitForResult(tasks, id, 5*time.Second)
+	if res.Status != "ok" {
+		t.Fatalf("expected stored result, got %+v", res)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("waitForResult should return as soon as the result is stored, took %s", d)
+	}
+	if n := watcherCount(id); n != 0 {
+		t.Fatalf("watcher must be removed, got %d", n)
+	}
+}
+
+func TestWaitForResult_StoredBeforeAndTimeout(t *testing.T) {
+	tasks := NewMemoryTaskStore(0)
+	storeResult(tasks, "task-wait-stored", Result{Status: "ok"})
+	if res := waitForResult(tasks, "task-wait-stored", time.Second); res.Status != "ok" {
+		t.Fatalf("expected result stored earlier, got %+v", res)
+	}
+	if res := waitForResult(tasks, "task-wait-none", 20*time.Millisecond); res.Status != "timeout" {
+		t.Fatalf("expected timeout, got %+v", res)
+	}
+}
+
+func TestPublishResult_KeepsOnlyLatest(t *testing.T) {
+	w, stop := watchTask("task-watch-latest", true)
+	defer stop()
+	publishResult("task-watch-latest", Result{Status: "needs_input"})
+	publishResult("task-watch-latest", Result{Status: "ok"})
+	for i := 0; i < watcherBuffer+1; i++ {
+		publishEvent("task-watch-latest", taskEvent{Kind: eventState}) // el sobrante se descarta
+	}
+	if res := <-w.result; res.Status != "ok" {
+		t.Fatalf("expected latest result, got %+v", res)
+	}
+	if len(w.events) != watcherBuffer {
+		t.Fatalf("expected a full buffer, got %d", len(w.events))
+	}
+}
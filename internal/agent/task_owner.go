@@ -0,0 +1,27 @@
+package agent
+
+import (
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+)
+
+// setOwner records the caller that launched a task. Tasks of callers
+// without identity have no owner.
+func setOwner(tasks TaskStore, id string, caller guard.Caller) {
+	if caller.IsZero() {
+		return
+	}
+	err := tasks.Update(id, func(rec *TaskRecord) bool {
+		rec.Owner = caller.ID
+		return true
+	})
+	if err != nil {
+		logStoreError(id, err)
+	}
+}
+
+// ownsTask reports whether caller may read, answer or cancel a task: one
+// with an owner is only visible to it, one without owner to any caller.
+func ownsTask(tasks TaskStore, id string, caller guard.Caller) bool {
+	rec, ok := tasks.Get(id)
+	return !ok || rec.Owner == "" || rec.Owner == caller.ID
+}
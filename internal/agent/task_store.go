@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"sync"
+	"time"
+)
+
+// TaskRecord is everything AOS keeps about a task once it has been
+// accepted: its lifecycle state and, when there is one, its result.
+type TaskRecord struct {
+	ID     string    `json:"id"`
+	Result *Result   `json:"result,omitempty"`
+	State  TaskState `json:"state"`
+	// Owner is the caller id of whoever launched the task; empty when the
+	// caller had no identity (shared API key or auth disabled).
+	Owner string `json:"owner,omitempty"`
+	// Pending is what the Planner knows of a task parked in needs_input.
+	Pending *pendingInput `json:"pending,omitempty"`
+	// Callback is the webhook of an async task, when it asked for one.
+	Callback  *CallbackDelivery `json:"callback,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// TaskStore persists task records. Reads are idempotent: a record stays
+// until it is deleted or evicted after its TTL.
+type TaskStore interface {
+	// Get returns a copy of the record of a task.
+	Get(id string) (TaskRecord, bool)
+	// Update applies fn to the record of a task (a new one if missing) and
+	// saves it when fn returns true.
+	Update(id string, fn func(rec *TaskRecord) bool) error
+	Delete(id string) error
+	// EvictExpired removes the records not updated within the TTL and
+	// returns how many were removed.
+	EvictExpired(now time.Time) int
+	Close() error
+}
+
+// DefaultTaskTTL is how long a finished or idle task is kept.
+const DefaultTaskTTL = 24 * time.Hour
+
+// memoryTaskStore keeps the records in a map. It is the default store and
+// the in-memory index of the file-backed one.
+type memoryTaskStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	records map[string]*TaskRecord
+}
+
+// NewMemoryTaskStore returns an in-memory TaskStore. A ttl <= 0 disables
+// eviction.
+func NewMemoryTaskStore(ttl time.Duration) TaskStore {
+	return newMemoryTaskStore(ttl)
+}
+
+func newMemoryTaskStore(ttl time.Duration) *memoryTaskStore {
+	return &memoryTaskStore{ttl: ttl, records: make(map[string]*TaskRecord)}
+}
+
+func (s *memoryTaskStore) Get(id string) (TaskRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.records[id]
+	if !ok {
+		return TaskRecord{}, false
+	}
+	return copyRecord(rec), true
+}
+
+func (s *memoryTaskStore) Update(id string, fn func(rec *TaskRecord) bool) error {
+	_, _ = s.update(id, fn)
+	return nil
+}
+
+// update applies fn and returns the saved record and whether it changed.
+func (s *memoryTaskStore) update(id string, fn func(rec *TaskRecord) bool) (TaskRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	rec := TaskRecord{ID: id, CreatedAt: now}
+	if cur, ok := s.records[id]; ok {
+		rec = copyRecord(cur)
+	}
+	if !fn(&rec) {
+		return TaskRecord{}, false
+	}
+	rec.UpdatedAt = now
+	s.records[id] = &rec
+	return copyRecord(&rec), true
+}
+
+func (s *memoryTaskStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, id)
+	return nil
+}
+
+func (s *memoryTaskStore) EvictExpired(now time.Time) int {
+	if s.ttl <= 0 {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, rec := range s.records {
+		if now.Sub(rec.UpdatedAt) > s.ttl {
+			delete(s.records, id)
+			n++
+		}
+	}
+	return n
+}
+
+func (s *memoryTaskStore) Close() error { return nil }
+
+// put stores a record as is (used when replaying the file-backed store).
+func (s *memoryTaskStore) put(rec TaskRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.ID] = &rec
+}
+
+// snapshot returns a copy of every record.
+func (s *memoryTaskStore) snapshot() []TaskRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]TaskRecord, 0, len(s.records))
+	for _, rec := range s.records {
+		out = append(out, copyRecord(rec))
+	}
+	return out
+}
+
+func copyRecord(rec *TaskRecord) TaskRecord {
+	cp := *rec
+	if rec.Result != nil {
+		res := *rec.Result
+		cp.Result = &res
+	}
+	cp.State.History = append([]StateTransition(nil), rec.State.History...)
+	if rec.Pending != nil {
+		pend := *rec.Pending
+		pend.Params = make(map[string]string, len(rec.Pending.Params))
+		for k, v := range rec.Pending.Params {
+			pend.Params[k] = v
+		}
+		pend.Missing = append([]string(nil), rec.Pending.Missing...)
+		pend.Questions = append([]string(nil), rec.Pending.Questions...)
+		cp.Pending = &pend
+	}
+	if rec.Callback != nil {
+		cb := *rec.Callback
+		cb.Attempts = append([]CallbackAttempt(nil), cb.Attempts...)
+		cp.Callback = &cb
+	}
+	return cp
+}
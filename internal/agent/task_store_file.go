@@ -0,0 +1,256 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/logx"
+)
+
+// defaultSegmentBytes is the size at which the active segment is compacted.
+const defaultSegmentBytes int64 = 4 << 20
+
+// errRestarted is the error of the tasks interrupted by a restart.
+const errRestarted = "restarted"
+
+// fileTaskStore is an embedded durable TaskStore. Every change appends the
+// full record as a JSON line to the active segment (tasks-<n>.jsonl); a
+// delete appends a tombstone. On open the segments are replayed in order.
+// When the active segment grows past maxSegment, or after an eviction, the
+// live records are rewritten to a new segment and the old ones removed.
+type fileTaskStore struct {
+	mem *memoryTaskStore
+
+	mu         sync.Mutex // serializes writes so the log keeps their order
+	dir        string
+	f          *os.File
+	seq        int
+	size       int64
+	maxSegment int64
+}
+
+// fileEntry is one line of a segment.
+type fileEntry struct {
+	Deleted bool        `json:"deleted,omitempty"`
+	ID      string      `json:"id,omitempty"`
+	Record  *TaskRecord `json:"record,omitempty"`
+}
+
+// NewFileTaskStore opens (or creates) a file-backed TaskStore in dir and
+// replays the records it already holds.
+func NewFileTaskStore(dir string, ttl time.Duration) (TaskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("task store: %w", err)
+	}
+	s := &fileTaskStore{
+		mem:        newMemoryTaskStore(ttl),
+		dir:        dir,
+		maxSegment: defaultSegmentBytes,
+	}
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, seq := range segments {
+		if err := s.replay(seq); err != nil {
+			return nil, err
+		}
+		s.seq = seq
+	}
+	s.mem.EvictExpired(time.Now())
+	s.failInterrupted()
+	// Arrancamos siempre con un segmento compactado
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileTaskStore) segmentPath(seq int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("tasks-%06d.jsonl", seq))
+}
+
+// segments returns the sequence numbers of the existing segments, in order.
+func (s *fileTaskStore) segments() ([]int, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "tasks-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var out []int
+	for _, m := range matches {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), "tasks-"), ".jsonl"))
+		if err == nil {
+			out = append(out, n)
+		}
+	}
+	sort.Ints(out)
+	return out, nil
+}
+
+func (s *fileTaskStore) replay(seq int) error {
+	f, err := os.Open(s.segmentPath(seq))
+	if err != nil {
+		return fmt.Errorf("task store: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		var e fileEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// línea truncada por una caída: la ignoramos
+			logx.Warn("TaskStore", "skipping corrupt line in segment %d: %v", seq, err)
+			continue
+		}
+		switch {
+		case e.Deleted:
+			_ = s.mem.Delete(e.ID)
+		case e.Record != nil:
+			s.mem.put(*e.Record)
+		}
+	}
+	return sc.Err()
+}
+
+// failInterrupted fails the replayed tasks that had not finished. Their
+// contexts, parked runs, pending input and approvals lived in memory, so a
+// task left in needs_input, pending_approval or mid-pipeline can never
+// resume after a restart.
+func (s *fileTaskStore) failInterrupted() {
+	for _, rec := range s.mem.snapshot() {
+		if terminalStates[rec.State.State] || (rec.Result != nil && !waitingStatuses[rec.Result.Status]) {
+			continue
+		}
+		s.mem.update(rec.ID, func(rec *TaskRecord) bool {
+			rec.Result = &Result{Status: "error", Err: errRestarted}
+			rec.Pending = nil
+			transition(rec, stateFailed)
+			return true
+		})
+		logx.Warn("TaskStore", "id=%s interrupted by a restart, marked as failed", rec.ID)
+	}
+}
+
+func (s *fileTaskStore) Get(id string) (TaskRecord, bool) {
+	return s.mem.Get(id)
+}
+
+func (s *fileTaskStore) Update(id string, fn func(rec *TaskRecord) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, changed := s.mem.update(id, fn)
+	if !changed {
+		return nil
+	}
+	return s.append(fileEntry{Record: &rec})
+}
+
+func (s *fileTaskStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.mem.Delete(id)
+	return s.append(fileEntry{Deleted: true, ID: id})
+}
+
+func (s *fileTaskStore) EvictExpired(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.mem.EvictExpired(now)
+	if n > 0 {
+		if err := s.compact(); err != nil {
+			logx.Error("TaskStore", "compaction after eviction failed: %v", err)
+		}
+	}
+	return n
+}
+
+func (s *fileTaskStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+// append writes one entry to the active segment. Results whose data cannot
+// be encoded are kept without it rather than lost.
+func (s *fileTaskStore) append(e fileEntry) error {
+	if s.f == nil {
+		return fmt.Errorf("task store cerrado")
+	}
+	line, err := json.Marshal(e)
+	if err != nil && e.Record != nil && e.Record.Result != nil {
+		logx.Warn("TaskStore", "id=%s result data not serializable: %v", e.Record.ID, err)
+		rec := *e.Record
+		res := *rec.Result
+		res.Data = nil
+		rec.Result = &res
+		line, err = json.Marshal(fileEntry{Record: &rec})
+	}
+	if err != nil {
+		return fmt.Errorf("task store: %w", err)
+	}
+	n, err := s.f.Write(append(line, '\n'))
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("task store: %w", err)
+	}
+	if s.size > s.maxSegment {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact writes the live records to a new segment and removes the old
+// ones. The new segment is synced before anything is deleted.
+func (s *fileTaskStore) compact() error {
+	next := s.seq + 1
+	f, err := os.OpenFile(s.segmentPath(next), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("task store: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	var size int64
+	for _, rec := range s.mem.snapshot() {
+		rec := rec
+		line, err := json.Marshal(fileEntry{Record: &rec})
+		if err != nil {
+			logx.Warn("TaskStore", "id=%s dropped from compaction: %v", rec.ID, err)
+			continue
+		}
+		n, _ := w.Write(append(line, '\n'))
+		size += int64(n)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("task store: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("task store: %w", err)
+	}
+
+	if s.f != nil {
+		s.f.Close()
+	}
+	old, _ := s.segments()
+	for _, seq := range old {
+		if seq < next {
+			_ = os.Remove(s.segmentPath(seq))
+		}
+	}
+	s.f, s.seq, s.size = f, next, size
+	return nil
+}
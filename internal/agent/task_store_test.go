@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMemoryTaskStore_GetIsIdempotent(t *testing.T) {
+	tasks := NewMemoryTaskStore(0)
+	storeResult(tasks, "t1", Result{Status: "ok"})
+
+	for i := 0; i < 2; i++ {
+		if res, ok := getResult(tasks, "t1"); !ok || res.Status != "ok" {
+			t.Fatalf("read %d: expected stored result, got %+v ok=%v", i, res, ok)
+		}
+	}
+}
+
+func TestMemoryTaskStore_EvictsAfterTTL(t *testing.T) {
+	tasks := NewMemoryTaskStore(time.Minute)
+	storeResult(tasks, "old", Result{Status: "ok"})
+
+	if n := tasks.EvictExpired(time.Now()); n != 0 {
+		t.Fatalf("fresh records must be kept, evicted %d", n)
+	}
+	if n := tasks.EvictExpired(time.Now().Add(2 * time.Minute)); n != 1 {
+		t.Fatalf("expected 1 evicted record, got %d", n)
+	}
+	if _, ok := tasks.Get("old"); ok {
+		t.Fatalf("evicted record must be gone")
+	}
+}
+
+func TestMemoryTaskStore_PendingInputGoesAwayWithTheTask(t *testing.T) {
+	tasks := NewMemoryTaskStore(time.Minute)
+	savePendingInput(tasks, "parked", pendingInput{Intent: "banking.get_balance", Missing: []string{"accountId"}})
+
+	if !hasPendingInput(tasks, "parked") || hasPendingInput(NewMemoryTaskStore(0), "parked") {
+		t.Fatalf("pending input must belong to its store")
+	}
+	tasks.EvictExpired(time.Now().Add(2 * time.Minute))
+	if hasPendingInput(tasks, "parked") {
+		t.Fatalf("pending input must be evicted with the task")
+	}
+	if _, ok := takePendingInput(tasks, "parked"); ok {
+		t.Fatalf("evicted pending input cannot be resumed")
+	}
+}
+
+func TestFileTaskStore_ReplaysAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	tasks, err := NewFileTaskStore(dir, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	setState(tasks, "kept", stateSummarizing)
+	storeResult(tasks, "kept", Result{Status: "ok", Data: map[string]any{"balance": 10.0}})
+	storeResult(tasks, "gone", Result{Status: "ok"})
+	if err := tasks.Delete("gone"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	// canal: no se puede serializar, el registro se guarda sin datos
+	storeResult(tasks, "odd", Result{Status: "ok", Data: make(chan int)})
+	if err := tasks.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	tasks, err = NewFileTaskStore(dir, 0)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer tasks.Close()
+
+	res, ok := getResult(tasks, "kept")
+	if !ok || res.Data.(map[string]any)["balance"] != 10.0 {
+		t.Fatalf("expected result to survive a restart, got %+v ok=%v", res, ok)
+	}
+	if st, _ := getState(tasks, "kept"); st.State != stateDone || len(st.History) != 2 {
+		t.Fatalf("expected lifecycle to survive a restart, got %+v", st)
+	}
+	if _, ok := tasks.Get("gone"); ok {
+		t.Fatalf("deleted record must not come back")
+	}
+	if res, ok := getResult(tasks, "odd"); !ok || res.Data != nil {
+		t.Fatalf("unserializable data must be dropped, got %+v ok=%v", res, ok)
+	}
+}
+
+func TestFileTaskStore_CompactsAndSkipsCorruptLines(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileTaskStore(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	fs := s.(*fileTaskStore)
+	fs.maxSegment = 512
+	for i := 0; i < 20; i++ {
+		storeResult(s, "same", Result{Status: "ok", Data: i})
+	}
+	// una línea truncada al final, como tras una caída
+	if _, err := fs.f.WriteString(`{"record":{"id":"tr`); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	s.Close()
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "tasks-*.jsonl"))
+	if len(segments) != 1 {
+		t.Fatalf("old segments must be removed after compaction, got %v", segments)
+	}
+	if fi, _ := os.Stat(segments[0]); fi.Size() > 1024 {
+		t.Fatalf("segment should have been compacted, size=%d", fi.Size())
+	}
+
+	s, err = NewFileTaskStore(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	if res, _ := getResult(s, "same"); res.Data != 19.0 {
+		t.Fatalf("expected last write to win, got %+v", res)
+	}
+	if n := s.EvictExpired(time.Now().Add(2 * time.Minute)); n != 1 {
+		t.Fatalf("expected 1 evicted record, got %d", n)
+	}
+}
+
+func TestFileTaskStore_ReopenFailsInterruptedTasksAndDropsExpired(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileTaskStore(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	setState(s, "approval", stateExecuting)
+	storeResult(s, "approval", Result{Status: statePendingApproval})
+	setState(s, "input", stateExtracting)
+	storeResult(s, "input", Result{Status: stateNeedsInput})
+	savePendingInput(s, "input", pendingInput{Intent: "banking.get_balance", Missing: []string{"accountId"}})
+	setState(s, "running", stateExecuting)
+	storeResult(s, "finished", Result{Status: "ok"})
+	old := TaskRecord{ID: "expired", State: TaskState{State: stateExecuting}, UpdatedAt: time.Now().Add(-2 * time.Hour)}
+	if err := s.(*fileTaskStore).append(fileEntry{Record: &old}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	s.Close()
+
+	s, err = NewFileTaskStore(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+
+	for _, id := range []string{"approval", "input", "running"} {
+		rec, _ := s.Get(id)
+		if rec.State.State != stateFailed || rec.Result == nil || rec.Result.Err != "restarted" {
+			t.Fatalf("%s: interrupted task must fail on restart, got %+v", id, rec)
+		}
+	}
+	if hasPendingInput(s, "input") {
+		t.Fatalf("a failed task cannot be resumed")
+	}
+	if res, _ := getResult(s, "finished"); res.Status != "ok" {
+		t.Fatalf("finished task must be kept as is, got %+v", res)
+	}
+	if _, ok := s.Get("expired"); ok {
+		t.Fatalf("expired record must be dropped on open")
+	}
+
+	// el fallo se persiste: un nuevo arranque lo encuentra ya terminado
+	s.Close()
+	s, err = NewFileTaskStore(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	if rec, _ := s.Get("approval"); rec.State.State != stateFailed || rec.State.FailedIn != statePendingApproval {
+		t.Fatalf("expected persisted failure, got %+v", rec.State)
+	}
+}
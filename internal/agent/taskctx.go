@@ -20,6 +20,9 @@ func NewTaskContext(parent context.Context, id string, timeout time.Duration) co
     }
     ctx, cancel := context.WithTimeout(parent, timeout)
     taskCtxMu.Lock()
+    if prev, ok := taskCancel[id]; ok {
+        prev() // a resumed task replaces its previous context
+    }
     taskCtx[id] = ctx
     taskCancel[id] = cancel
     taskCtxMu.Unlock()
@@ -34,7 +37,8 @@ func GetTaskContext(id string) (context.Context, bool) {
     return ctx, ok
 }
 
-// CancelTask cancels and removes a task context.
+// CancelTask cancels and removes a task context. It is also how a task's
+// context is released once the task has a result (see storeResult).
 func CancelTask(id string) {
     taskCtxMu.Lock()
     if c, ok := taskCancel[id]; ok {
@@ -44,3 +48,10 @@ func CancelTask(id string) {
     delete(taskCtx, id)
     taskCtxMu.Unlock()
 }
+
+// taskContextCount reports how many task contexts are registered.
+func taskContextCount() int {
+    taskCtxMu.RLock()
+    defer taskCtxMu.RUnlock()
+    return len(taskCtx)
+}
@@ -2,12 +2,21 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/audit"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/expr"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/logx"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/retry"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/tools"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
 )
@@ -17,14 +26,58 @@ type Verifier struct {
 	cfg     *config.Config
 	inbox   chan bus.Message
 	uiStore *ui.UIStore
+	tasks   TaskStore
+	// velocity enforces the limits: of intents before dangerous steps.
+	velocity *guard.Velocity
+	// audit records every write/dangerous tool call; nil disables it.
+	audit *audit.Log
+
+	// approvalTimeout is how long a dangerous step waits for a human decision.
+	approvalTimeout time.Duration
+
+	parkedMu sync.Mutex
+	parked   map[string]*pipelineRun // tasks waiting for approval
+}
+
+// pipelineRun is the execution state of a pipeline for one task. It lets the
+// Verifier park a task before a dangerous step and resume it later.
+type pipelineRun struct {
+	ID       string
+	Intent   string
+	Pipeline config.Pipeline
+	Params   map[string]string
+	Caller   guard.Caller
+	Results  map[string]any
+	Done     map[string]bool // steps finished, skipped or simulated
+	Approved map[string]bool // dangerous steps already approved
+	Approval []Approval      // decisions taken during this run
+	Shadow   bool            // intent in shadow_mode: writes are simulated
+	Skipped  []string        // steps whose `when` evaluated to false
+	Started  time.Time
+	Timings  []stepTiming
+	// Completed lists the steps actually executed, in completion order,
+	// so they can be compensated in reverse if the run fails.
+	Completed []string
 }
 
-func NewVerifier(b *bus.Bus, cfg *config.Config, ui *ui.UIStore) *Verifier {
+// defaultApprovalTimeout applies when APPROVAL_TIMEOUT is unset or invalid.
+const defaultApprovalTimeout = 15 * time.Minute
+
+func NewVerifier(b *bus.Bus, cfg *config.Config, ui *ui.UIStore, tasks TaskStore, counters guard.CounterStore, auditLog *audit.Log) *Verifier {
+	timeout := defaultApprovalTimeout
+	if d, err := time.ParseDuration(strings.TrimSpace(os.Getenv("APPROVAL_TIMEOUT"))); err == nil && d > 0 {
+		timeout = d
+	}
 	return &Verifier{
-		bus:     b,
-		cfg:     cfg,
-		inbox:   make(chan bus.Message, 16),
-		uiStore: ui,
+		bus:             b,
+		cfg:             cfg,
+		inbox:           make(chan bus.Message, 16),
+		uiStore:         ui,
+		tasks:           tasks,
+		velocity:        guard.NewVelocity(counters),
+		audit:           auditLog,
+		approvalTimeout: timeout,
+		parked:          make(map[string]*pipelineRun),
 	}
 }
 
@@ -60,6 +113,10 @@ func (v *Verifier) dispatch(msg bus.Message) {
 	switch msg.Type {
 	case "run_pipeline":
 		v.handleRunPipeline(msg)
+	case "approval_decision":
+		v.handleApprovalDecision(msg)
+	case "cancel_task":
+		v.handleCancelTask(msg)
 	default:
 		logx.Warn("Verifier", "unknown message: %#v", msg)
 	}
@@ -76,7 +133,7 @@ func (v *Verifier) handleRunPipeline(msg bus.Message) {
 
 	pipe, ok := pipeAny.(config.Pipeline)
 	if !ok {
-		storeResult(id, Result{
+		storeResult(v.tasks, id, Result{
 			Status: "error",
 			Err:    "pipeline inválido",
 		})
@@ -100,97 +157,645 @@ func (v *Verifier) handleRunPipeline(msg bus.Message) {
 		}
 	}
 
+	caller, _ := msg.Payload["caller"].(guard.Caller)
+
 	logx.Info("Verifier", "executing pipeline=%s id=%s intent=%s params=%#v",
 		pipe.Name, id, intentType, baseParams)
 
-	stepResults := make(map[string]any)
-
-	// ---------------------------------------------------------
-	// EJECUTAMOS CADA PASO
-	// ---------------------------------------------------------
-	for _, step := range pipe.Steps {
-
-		// Step ANALYST → directo al Analyst
-		if step.Analyst {
-			logx.Debug("Verifier", "analyst=true id=%s -> calling Analyst", id)
-			v.bus.Send("analyst", bus.Message{
-				Type: "summarize",
-				Payload: map[string]any{
-					"id":        id,
-					"intent":    intentType,
-					"rawResult": stepResults,
-				},
-			})
-			return
+	v.runSteps(&pipelineRun{
+		ID:       id,
+		Intent:   intentType,
+		Pipeline: pipe,
+		Params:   baseParams,
+		Caller:   caller,
+		Results:  make(map[string]any),
+		Done:     make(map[string]bool),
+		Approved: make(map[string]bool),
+		Shadow:   v.cfg.Intents[intentType].ShadowMode,
+		Started:  time.Now(),
+	})
+}
+
+// stepOutcome is what a step goroutine reports back to the scheduler.
+type stepOutcome struct {
+	step        config.PipelineStep
+	reservation *guard.Reservation
+	tool        config.Tool
+	rendered    tools.RenderedRequest
+	params      map[string]string
+	out         any
+	err         error
+	attempts    int
+	status      int // HTTP status of the last attempt
+	started     time.Time
+	duration    time.Duration
+}
+
+// runSteps schedules the pending steps of the pipeline. A step starts as
+// soon as all its dependencies are done, so independent steps run
+// concurrently under the task context. It returns early when the task is
+// parked waiting for an approval.
+func (v *Verifier) runSteps(run *pipelineRun) {
+	id := run.ID
+	steps, deps := run.Pipeline.ToolSteps()
+
+	// obtain task context if present
+	taskCtx, _ := GetTaskContext(id)
+	if taskCtx == nil {
+		taskCtx = context.Background()
+	}
+
+	outcomes := make(chan stepOutcome)
+	started := make(map[string]bool)
+	running := 0
+	var failure string
+	var park *parkRequest
+
+	for {
+		// ---------------------------------------------------------
+		// LANZAMOS LOS STEPS CUYAS DEPENDENCIAS YA TERMINARON
+		// ---------------------------------------------------------
+		for launched := true; launched && failure == "" && park == nil; {
+			launched = false
+			// Una tarea cancelada no lanza más steps
+			if isCancelled(v.tasks, id) {
+				failure = "tarea cancelada"
+				break
+			}
+			for _, step := range steps {
+				sid := step.StepID()
+				if run.Done[sid] || started[sid] || !run.depsDone(deps[sid]) {
+					continue
+				}
+				ready, errMsg := v.prepareStep(run, step)
+				switch {
+				case errMsg != "":
+					failure = errMsg
+				case ready.park != nil:
+					park = ready.park
+				case ready.done:
+					// skipped o simulado: puede desbloquear otros steps
+					launched = true
+				default:
+					started[sid] = true
+					running++
+					setState(v.tasks, id, stateExecuting+":"+sid)
+					go func(step config.PipelineStep, ready readyStep) {
+						begin := time.Now()
+						out, attempts, status, err := executeStep(taskCtx, id, step, ready.tool, ready.rendered)
+						outcomes <- stepOutcome{
+							step: step, reservation: ready.reservation, tool: ready.tool, rendered: ready.rendered, params: ready.params,
+							out: out, err: err, attempts: attempts, status: status, started: begin, duration: time.Since(begin),
+						}
+					}(step, ready)
+				}
+				if failure != "" || park != nil {
+					break
+				}
+			}
+		}
+
+		if running == 0 {
+			break
 		}
 
-		// Step TOOL
-		toolName := step.Tool
-		t, ok := v.cfg.Tools[toolName]
+		// Esperamos a que termine algún step en curso
+		o := <-outcomes
+		running--
+		v.finishStep(run, deps, o)
+		if o.err != nil && failure == "" {
+			failure = o.err.Error()
+		}
+	}
+
+	switch {
+	case failure != "" && isCancelled(v.tasks, id):
+		v.failRun(run, "cancelled", "tarea cancelada", map[string]any{})
+	case failure != "":
+		v.failRun(run, "error", failure, map[string]any{})
+	case park != nil:
+		v.parkForApproval(run, park.step, park.tool, park.rendered)
+	case len(run.Done) < len(steps):
+		storeResult(v.tasks, id, Result{
+			Status: "error",
+			Err:    "pipeline bloqueado: dependencias sin resolver",
+		})
+	default:
+		logx.Debug("Verifier", "all steps done id=%s -> calling Analyst", id)
+		v.sendToAnalyst(run)
+	}
+}
+
+// parkRequest describes the dangerous step a run must be parked on.
+type parkRequest struct {
+	step     config.PipelineStep
+	tool     config.Tool
+	rendered tools.RenderedRequest
+}
+
+// readyStep is the result of preparing a step: either it is already done
+// (skipped or simulated), it needs an approval, or it must be executed.
+type readyStep struct {
+	done     bool
+	park     *parkRequest
+	tool     config.Tool
+	rendered tools.RenderedRequest
+	params   map[string]string
+	// reservation is the quota of the velocity limits taken by the step.
+	reservation *guard.Reservation
+}
+
+// prepareStep evaluates `when`, merges params and renders the request of a
+// step. It runs in the scheduler goroutine, so it may touch run freely.
+func (v *Verifier) prepareStep(run *pipelineRun, step config.PipelineStep) (readyStep, string) {
+	id := run.ID
+	sid := step.StepID()
+
+	// Step TOOL
+	toolName := step.Tool
+	t, ok := v.cfg.Tools[toolName]
+	if !ok {
+		return readyStep{}, fmt.Sprintf("tool %s no encontrada", toolName)
+	}
+
+	// Step condicional: se evalúa contra params y salidas previas
+	if step.When != "" {
+		ok, err := expr.EvalBool(step.When, run.exprEnv())
+		if err != nil {
+			logx.Error("Verifier", "error evaluating when for step=%s: %v", sid, err)
+			return readyStep{}, fmt.Sprintf("condición inválida en step %s: %v", sid, err)
+		}
 		if !ok {
-			storeResult(id, Result{
-				Status: "error",
-				Err:    fmt.Sprintf("tool %s no encontrada", toolName),
-			})
-			return
+			logx.Info("Verifier", "skipping step=%s id=%s (when: %s)", sid, id, step.When)
+			v.uiStore.AddEvent(id, "Verifier", "skipped "+t.Name, "when: "+step.When, "")
+			run.Results[sid] = map[string]any{"skipped": true, "when": step.When}
+			run.Skipped = append(run.Skipped, sid)
+			run.Done[sid] = true
+			return readyStep{done: true}, ""
 		}
+	}
 
-		logx.Info("Verifier", "executing tool=%s id=%s", toolName, id)
-		// Combinar parámetros → baseParams + WithParams (sin pisar los del Planner)
-		callParams := make(map[string]string)
+	callParams, err := run.callParams(step.WithParams)
+	if err != nil {
+		logx.Error("Verifier", "error rendering with_params for step=%s: %v", sid, err)
+		return readyStep{}, fmt.Sprintf("step %s: %v", sid, err)
+	}
+
+	rendered, err := tools.RenderRequestWithSteps(t, callParams, run.Results)
+	if err != nil {
+		logx.Error("Verifier", "error rendering tool=%s: %v", toolName, err)
+		return readyStep{}, err.Error()
+	}
 
-		// 1. Copiar params del Planner
-		for k, v := range baseParams {
-			callParams[k] = v
+	// Shadow mode: las lecturas son reales, las escrituras se simulan
+	if run.Shadow && (t.Mode == "write" || t.Mode == "dangerous") {
+		logx.Info("Verifier", "shadow mode: simulating tool=%s id=%s", toolName, id)
+		v.uiStore.AddEvent(id, "Verifier", "shadow "+t.Name, "simulado, no ejecutado", "")
+		run.Results[sid] = shadowResponse(rendered)
+		run.Done[sid] = true
+		return readyStep{done: true}, ""
+	}
+
+	// Las tools peligrosas nunca se ejecutan sin aprobación humana, ni
+	// pedimos aprobación para algo que ya excede los límites de velocidad
+	if t.Mode == "dangerous" && !run.Approved[sid] {
+		if err := v.velocity.Check(v.cfg.Intents[run.Intent], run.Params, run.Caller); err != nil {
+			return readyStep{}, v.velocityFailure(id, t, err)
 		}
+		return readyStep{park: &parkRequest{step: step, tool: t, rendered: rendered}}, ""
+	}
 
-		// 2. Rellenar defaults del pipeline SIN sobreescribir valores existentes
-		for k, v := range step.WithParams {
-			if _, exists := callParams[k]; !exists || callParams[k] == "" {
-				if v != "" { // evitamos meter valores vacíos
-					callParams[k] = v
+	ready := readyStep{tool: t, rendered: rendered, params: callParams}
+	if t.Mode == "dangerous" {
+		r, err := v.velocity.Reserve(v.cfg.Intents[run.Intent], run.Params, run.Caller)
+		if err != nil {
+			return readyStep{}, v.velocityFailure(id, t, err)
+		}
+		ready.reservation = r
+	}
+
+	logx.Info("Verifier", "executing step=%s tool=%s id=%s", sid, toolName, id)
+	logx.Debug("Verifier", "params for the tool=%s id=%s params=%#v",
+		toolName, id, callParams)
+	return ready, ""
+}
+
+// velocityFailure logs a velocity limit hit before a dangerous tool and
+// returns the error of the run.
+func (v *Verifier) velocityFailure(id string, t config.Tool, err error) string {
+	logx.Warn("Verifier", "id=%s tool=%s blocked: %v", id, t.Name, err)
+	v.uiStore.AddEvent(id, "Verifier", "limit "+t.Name, err.Error(), "")
+	return err.Error()
+}
+
+// callParams combina los params del Planner con los with_params de un step
+// o de una compensación, sin pisar los del Planner.
+func (run *pipelineRun) callParams(withParams map[string]string) (map[string]string, error) {
+	callParams := make(map[string]string)
+
+	// 1. Copiar params del Planner
+	for k, v := range run.Params {
+		callParams[k] = v
+	}
+
+	// 2. Rellenar defaults del pipeline SIN sobreescribir valores existentes.
+	//    Los valores pueden ser templates sobre salidas previas:
+	//    ticketId: "{{ .steps.create_ticket.ticketId }}"
+	data := tools.TemplateData(run.Params, run.Results)
+	for k, v := range withParams {
+		if _, exists := callParams[k]; !exists || callParams[k] == "" {
+			if strings.Contains(v, "{{") {
+				rv, err := tools.RenderTemplateData(v, data)
+				if err != nil {
+					return nil, fmt.Errorf("with_params %s inválido: %w", k, err)
 				}
+				v = rv
+			}
+			if v != "" { // evitamos meter valores vacíos
+				callParams[k] = v
 			}
 		}
+	}
+	return callParams, nil
+}
 
-		// Ejecutar tool con cuerpo renderizado
-		timer := logx.Start(id, "Verifier", "tool_"+toolName)
-		start := time.Now()
+// finishStep records the output and timing of an executed step.
+func (v *Verifier) finishStep(run *pipelineRun, deps map[string][]string, o stepOutcome) {
+	id := run.ID
+	sid := o.step.StepID()
 
-		logx.Debug("Verifier", "params for the tool=%s id=%s params=%#v",
-			toolName, id, callParams)
+	timing := stepTiming{
+		Step:       sid,
+		Tool:       o.step.Tool,
+		DependsOn:  deps[sid],
+		StartMs:    o.started.Sub(run.Started).Milliseconds(),
+		DurationMs: o.duration.Milliseconds(),
+		Attempts:   o.attempts,
+	}
+	run.Timings = append(run.Timings, timing)
 
-		// obtain task context if present
-		taskCtx, _ := GetTaskContext(id)
-		if taskCtx == nil {
-			taskCtx = context.Background()
+	msg := fmt.Sprintf("ok · inicio +%dms", timing.StartMs)
+	if o.attempts > 1 {
+		msg += fmt.Sprintf(" · %d intentos", o.attempts)
+	}
+	if len(timing.DependsOn) > 0 {
+		msg += " · tras " + strings.Join(timing.DependsOn, ", ")
+	}
+	if o.err != nil {
+		logx.Error("Verifier", "error executing tool=%s: %v", o.step.Tool, o.err)
+		msg = "error: " + o.err.Error()
+	}
+	v.uiStore.AddEvent(id, "Verifier", "tool "+o.step.Tool, msg, o.duration.String())
+	v.recordAudit(run, sid, o.tool, o.rendered, o.params, o.status, o.err, false)
+
+	if o.err != nil {
+		// El servicio rechazó la petición: la operación no ocurrió y su
+		// cuota se devuelve. Ante un timeout no sabemos, así que se mantiene.
+		var httpErr *tools.HTTPError
+		if errors.As(o.err, &httpErr) {
+			o.reservation.Release()
 		}
+		return
+	}
+	run.Results[sid] = o.out
+	run.Done[sid] = true
+	run.Completed = append(run.Completed, sid)
+}
 
-		out, err := tools.ExecuteToolCtx(taskCtx, t, callParams)
-		timer.End()
-		duration := time.Since(start).String()
+// stepTiming is the per-step timing exposed in the result, relative to the
+// start of the pipeline, so the critical path of a DAG can be followed.
+type stepTiming struct {
+	Step       string   `json:"step"`
+	Tool       string   `json:"tool"`
+	DependsOn  []string `json:"dependsOn,omitempty"`
+	StartMs    int64    `json:"startMs"`
+	DurationMs int64    `json:"durationMs"`
+	Attempts   int      `json:"attempts,omitempty"`
+}
 
-		v.uiStore.AddEvent(id, "Verifier", "tool "+t.Name, "ok", duration)
+// executeStep sends the request of a step applying its timeout and retry
+// policy, and returns the output, the attempts made and the HTTP status of
+// the last one. It runs in its own goroutine and must not touch the
+// pipelineRun.
+func executeStep(ctx context.Context, id string, step config.PipelineStep, t config.Tool, rendered tools.RenderedRequest) (map[string]any, int, int, error) {
+	if step.TimeoutMs > 0 {
+		t.TimeoutMs = step.TimeoutMs
+	}
+	attempts := step.Retry.MaxAttempts()
+	if t.Mode == "dangerous" && !t.Idempotent {
+		// también se valida al cargar la config; aquí por si el pipeline llega de otra fuente
+		attempts = 1
+	}
+	var backoff time.Duration
+	if step.Retry != nil {
+		backoff = time.Duration(step.Retry.BackoffMs) * time.Millisecond
+	}
 
-		if err != nil {
-			logx.Error("Verifier", "error executing tool=%s: %v", toolName, err)
-			storeResult(id, Result{
-				Status: "error",
-				Err:    err.Error(),
-			})
-			return
+	timer := logx.Start(id, "Verifier", "tool_"+step.Tool)
+	defer timer.End()
+
+	for attempt := 1; ; attempt++ {
+		out, status, err := tools.SendRequestStatus(ctx, t, rendered)
+		if err == nil || attempt >= attempts || !retriable(err, step.Retry.RetryOn()) || ctx.Err() != nil {
+			return out, attempt, status, err
+		}
+		delay := retry.Delay(backoff, attempt, 10*time.Second)
+		logx.Warn("Verifier", "tool=%s id=%s attempt %d/%d failed: %v (retry in %s)", step.Tool, id, attempt, attempts, err, delay)
+		if werr := retry.Wait(ctx, delay); werr != nil {
+			return nil, attempt, status, err
+		}
+	}
+}
+
+// retriable reports whether err matches one of the retry.on conditions.
+func retriable(err error, on []string) bool {
+	var httpErr *tools.HTTPError
+	isHTTP := errors.As(err, &httpErr)
+	for _, cond := range on {
+		switch {
+		case cond == "timeout":
+			if tools.IsTimeout(err) {
+				return true
+			}
+		case isHTTP && cond == strconv.Itoa(httpErr.StatusCode):
+			return true
 		}
+	}
+	return false
+}
 
-		stepResults[toolName] = out
+func (run *pipelineRun) depsDone(deps []string) bool {
+	for _, d := range deps {
+		if !run.Done[d] {
+			return false
+		}
 	}
+	return true
+}
 
-	// Si terminó sin paso analyst explícito, lo enviamos ahora
+func (v *Verifier) sendToAnalyst(run *pipelineRun) {
+	setState(v.tasks, run.ID, stateSummarizing)
+	payload := map[string]any{
+		"id":        run.ID,
+		"intent":    run.Intent,
+		"rawResult": run.Results,
+	}
+	extra := map[string]any{}
+	if len(run.Approval) > 0 {
+		extra["approvals"] = run.Approval
+	}
+	if run.Shadow {
+		extra["shadow"] = true
+	}
+	if len(run.Skipped) > 0 {
+		extra["skipped"] = run.Skipped
+	}
+	if len(run.Timings) > 0 {
+		extra["timings"] = run.Timings
+	}
+	if len(extra) > 0 {
+		payload["extra"] = extra
+	}
 	v.bus.Send("analyst", bus.Message{
-		Type: "summarize",
-		Payload: map[string]any{
-			"id":        id,
-			"intent":    intentType,
-			"rawResult": stepResults,
-		},
+		Type:    "summarize",
+		Payload: payload,
+	})
+}
+
+// failRun stores the final error of a run after compensating the steps it
+// already executed.
+func (v *Verifier) failRun(run *pipelineRun, status, errMsg string, data map[string]any) {
+	if comps := v.compensate(run); len(comps) > 0 {
+		data["compensations"] = comps
+		if _, ok := data["raw"]; !ok {
+			data["raw"] = run.Results
+		}
+	}
+	res := Result{Status: status, Err: errMsg}
+	if len(data) > 0 {
+		res.Data = data
+	}
+	storeResult(v.tasks, run.ID, res)
+}
+
+// compensationOutcome is the result of undoing one step.
+type compensationOutcome struct {
+	Step   string `json:"step"`
+	Tool   string `json:"tool"`
+	Status string `json:"status"` // ok, error
+	Error  string `json:"error,omitempty"`
+	Output any    `json:"output,omitempty"`
+}
+
+// compensate runs the `compensate:` invocation of every executed step, in
+// reverse completion order. A failed compensation is reported and the
+// remaining ones still run.
+func (v *Verifier) compensate(run *pipelineRun) []compensationOutcome {
+	toolSteps, _ := run.Pipeline.ToolSteps()
+	steps := make(map[string]config.PipelineStep, len(toolSteps))
+	for _, s := range toolSteps {
+		steps[s.StepID()] = s
+	}
+
+	// la compensación debe ejecutarse aunque la tarea se haya cancelado
+	ctx := context.Background()
+	if taskCtx, ok := GetTaskContext(run.ID); ok {
+		ctx = context.WithoutCancel(taskCtx)
+	}
+
+	var out []compensationOutcome
+	for i := len(run.Completed) - 1; i >= 0; i-- {
+		sid := run.Completed[i]
+		comp := steps[sid].Compensate
+		if comp == nil {
+			continue
+		}
+		outcome := compensationOutcome{Step: sid, Tool: comp.Tool, Status: "ok"}
+		res, err := v.runCompensation(ctx, run, sid, comp)
+		if err != nil {
+			outcome.Status = "error"
+			outcome.Error = err.Error()
+			logx.Error("Verifier", "id=%s compensation of step=%s failed: %v", run.ID, sid, err)
+		} else {
+			outcome.Output = res
+			logx.Info("Verifier", "id=%s step=%s compensated with tool=%s", run.ID, sid, comp.Tool)
+		}
+		v.uiStore.AddEvent(run.ID, "Verifier", "compensate "+comp.Tool, "step "+sid+": "+outcome.Status+" "+outcome.Error, "")
+		out = append(out, outcome)
+	}
+	return out
+}
+
+func (v *Verifier) runCompensation(ctx context.Context, run *pipelineRun, sid string, comp *config.Compensation) (map[string]any, error) {
+	t, ok := v.cfg.Tools[comp.Tool]
+	if !ok {
+		return nil, fmt.Errorf("tool %s no encontrada", comp.Tool)
+	}
+	params, err := run.callParams(comp.WithParams)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := tools.RenderRequestWithSteps(t, params, run.Results)
+	if err != nil {
+		return nil, err
+	}
+	out, _, status, err := executeStep(ctx, run.ID, config.PipelineStep{Tool: comp.Tool, Retry: comp.Retry}, t, rendered)
+	v.recordAudit(run, sid, t, rendered, params, status, err, true)
+	return out, err
+}
+
+// maxAuditError bounds the error (and response body) kept in an audit entry.
+const maxAuditError = 512
+
+// recordAudit writes a write/dangerous tool call to the audit log. Reads
+// are not recorded.
+func (v *Verifier) recordAudit(run *pipelineRun, sid string, t config.Tool, rendered tools.RenderedRequest, params map[string]string, status int, callErr error, compensation bool) {
+	if v.audit == nil || (t.Mode != "write" && t.Mode != "dangerous") {
+		return
+	}
+	e := audit.Entry{
+		TaskID:       run.ID,
+		Caller:       run.Caller.ID,
+		Intent:       run.Intent,
+		Step:         sid,
+		Tool:         t.Name,
+		Mode:         t.Mode,
+		Compensation: compensation,
+		Method:       rendered.Method,
+		URL:          audit.RedactURL(rendered.URL),
+		Params:       audit.RedactParams(params),
+		Status:       status,
+	}
+	if callErr != nil {
+		e.Error = callErr.Error()
+		if len(e.Error) > maxAuditError {
+			e.Error = e.Error[:maxAuditError] + "…"
+		}
+	}
+	if _, err := v.audit.Append(e); err != nil {
+		logx.Error("Verifier", "id=%s tool=%s audit log write failed: %v", run.ID, t.Name, err)
+	}
+}
+
+// exprEnv exposes params and previous step outputs to `when` expressions.
+func (run *pipelineRun) exprEnv() map[string]any {
+	return map[string]any{
+		"params": run.Params,
+		"steps":  run.Results,
+	}
+}
+
+// shadowResponse is the simulated output of a write/dangerous tool in
+// shadow mode: it echoes the request that would have been sent.
+func shadowResponse(rendered tools.RenderedRequest) map[string]any {
+	return map[string]any{
+		"shadow":  true,
+		"method":  rendered.Method,
+		"url":     rendered.URL,
+		"headers": tools.MaskHeaders(rendered.Headers),
+		"body":    rendered.Body,
+	}
+}
+
+// parkForApproval stops the run before a dangerous tool and waits for a
+// human decision (see /approvals). The approval expires after approvalTimeout.
+func (v *Verifier) parkForApproval(run *pipelineRun, step config.PipelineStep, t config.Tool, rendered tools.RenderedRequest) {
+	id := run.ID
+	now := time.Now()
+	ap := Approval{
+		TaskID:      id,
+		Intent:      run.Intent,
+		Step:        step.StepID(),
+		Tool:        t.Name,
+		Request:     maskRequest(rendered),
+		RequestedBy: run.Caller.ID,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(v.approvalTimeout),
+	}
+	requestApproval(ap)
+	ap.Status = "pending"
+
+	v.parkedMu.Lock()
+	v.parked[id] = run
+	v.parkedMu.Unlock()
+
+	logx.Info("Verifier", "id=%s tool=%s waiting for approval until %s", id, t.Name, ap.ExpiresAt.Format(time.RFC3339))
+	v.uiStore.AddEvent(id, "Verifier", "pending_approval", "tool "+t.Name+" requiere aprobación", "")
+	storeResult(v.tasks, id, Result{
+		Status: "pending_approval",
+		Data:   map[string]any{"approval": ap},
+	})
+
+	time.AfterFunc(v.approvalTimeout, func() {
+		if _, ok := expireApproval(id); ok {
+			v.bus.Send("verifier", bus.Message{
+				Type:    "approval_decision",
+				Payload: map[string]any{"id": id},
+			})
+		}
 	})
 }
+
+// handleCancelTask releases a run parked waiting for approval after its
+// task has been cancelled, compensating the steps it already executed.
+func (v *Verifier) handleCancelTask(msg bus.Message) {
+	id := msg.Payload["id"].(string)
+
+	v.parkedMu.Lock()
+	run, ok := v.parked[id]
+	delete(v.parked, id)
+	v.parkedMu.Unlock()
+	if !ok {
+		return
+	}
+	v.releaseCancelled(run)
+}
+
+// releaseCancelled fails a parked run whose task has been cancelled,
+// compensating the steps it already executed.
+func (v *Verifier) releaseCancelled(run *pipelineRun) {
+	logx.Info("Verifier", "id=%s cancelled while waiting for approval", run.ID)
+	v.uiStore.AddEvent(run.ID, "Verifier", "cancelled", "tarea cancelada", "")
+	v.failRun(run, "cancelled", "tarea cancelada", map[string]any{"raw": run.Results})
+}
+
+// handleApprovalDecision resumes or aborts a parked run once its approval
+// has been approved, rejected or has expired.
+func (v *Verifier) handleApprovalDecision(msg bus.Message) {
+	id := msg.Payload["id"].(string)
+
+	v.parkedMu.Lock()
+	run, ok := v.parked[id]
+	delete(v.parked, id)
+	v.parkedMu.Unlock()
+	if !ok {
+		logx.Warn("Verifier", "approval_decision id=%s has no parked pipeline", id)
+		return
+	}
+
+	ap, ok := takeApproval(id)
+	if !ok {
+		// solo cancelTask retira la aprobación de una ejecución aparcada: la
+		// cancelación ha ganado la carrera y su cancel_task ya no la encontrará
+		v.releaseCancelled(run)
+		return
+	}
+	run.Approval = append(run.Approval, ap)
+
+	switch ap.Status {
+	case "approved":
+		logx.Info("Verifier", "id=%s tool=%s approved by %s", id, ap.Tool, ap.Approver)
+		v.uiStore.AddEvent(id, "Verifier", "approved", "aprobado por "+ap.Approver, "")
+		run.Approved[ap.Step] = true
+		deleteResult(v.tasks, id)
+		v.runSteps(run)
+	default:
+		errMsg := fmt.Sprintf("tool %s rechazada por %s", ap.Tool, ap.Approver)
+		if ap.Status == "expired" {
+			errMsg = fmt.Sprintf("tool %s: aprobación caducada", ap.Tool)
+		}
+		logx.Info("Verifier", "id=%s %s", id, errMsg)
+		v.uiStore.AddEvent(id, "Verifier", ap.Status, errMsg, "")
+		v.failRun(run, "rejected", errMsg, map[string]any{"approval": ap, "raw": run.Results})
+	}
+}
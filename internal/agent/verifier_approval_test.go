@@ -0,0 +1,274 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/auth"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+// approvalFixture builds a verifier whose pipeline runs a read tool and then
+// a dangerous one, counting the calls that reach the dangerous endpoint.
+func approvalFixture(t *testing.T) (*Verifier, *bus.Bus, config.Pipeline, *int32) {
+	t.Helper()
+	var sent int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/send" {
+			atomic.AddInt32(&sent, 1)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := &config.Config{
+		Tools: map[string]config.Tool{
+			"check": {Name: "check", Method: "GET", URL: ts.URL + "/check", Mode: "read", TimeoutMs: 500},
+			"send": {
+				Name: "send", Method: "POST", URL: ts.URL + "/send?api_key=k3y", Mode: "dangerous", TimeoutMs: 500,
+				Headers: map[string]string{"Authorization": "Bearer top-secret"},
+				Body:    map[string]string{"amount": "{{ .amount }}", "pin": "1234"},
+			},
+		},
+	}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{{Tool: "check"}, {Tool: "send"}, {Analyst: true}}}
+	b := bus.New()
+	return NewVerifier(b, cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil), b, pipe, &sent
+}
+
+func runPipelineMsg(id string, pipe config.Pipeline) bus.Message {
+	return bus.Message{
+		Type: "run_pipeline",
+		Payload: map[string]any{
+			"id":       id,
+			"intent":   "banking.send_bizum",
+			"pipeline": pipe,
+			"params":   map[string]string{"amount": "25"},
+		},
+	}
+}
+
+func TestVerifier_DangerousTool_ParksPendingApproval(t *testing.T) {
+	v, _, pipe, sent := approvalFixture(t)
+
+	id := "task-approval-park"
+	v.dispatch(runPipelineMsg(id, pipe))
+	defer takeApproval(id)
+
+	res, ok := getResult(v.tasks, id)
+	if !ok || res.Status != "pending_approval" {
+		t.Fatalf("expected pending_approval, got %+v", res)
+	}
+	if atomic.LoadInt32(sent) != 0 {
+		t.Fatalf("dangerous tool must not run before approval")
+	}
+	ap := res.Data.(map[string]any)["approval"].(Approval)
+	if ap.Tool != "send" || ap.Request.Body["amount"] != "25" {
+		t.Fatalf("approval should expose the rendered request, got %+v", ap)
+	}
+	if ap.Request.Headers["Authorization"] != "****" {
+		t.Fatalf("secrets must be masked in the approval, got %q", ap.Request.Headers["Authorization"])
+	}
+	if ap.Request.Body["pin"] != "****" || strings.Contains(ap.Request.URL, "k3y") {
+		t.Fatalf("secrets in the body and URL must be masked, got %+v", ap.Request)
+	}
+	for _, listed := range listPendingApprovals() {
+		if listed.TaskID == id && (listed.Request.Body["pin"] != "****" || strings.Contains(listed.Request.URL, "k3y")) {
+			t.Fatalf("GET /approvals must not expose secrets, got %+v", listed.Request)
+		}
+	}
+	if len(listPendingApprovals()) == 0 {
+		t.Fatalf("expected approval to be listed as pending")
+	}
+}
+
+func TestVerifier_ApprovedTool_ResumesPipeline(t *testing.T) {
+	v, b, pipe, sent := approvalFixture(t)
+	analystCh := make(chan bus.Message, 1)
+	b.Subscribe("analyst", analystCh)
+
+	id := "task-approval-ok"
+	v.dispatch(runPipelineMsg(id, pipe))
+
+	if _, err := decideApproval(id, true, "alice", "ok"); err != nil {
+		t.Fatalf("decideApproval: %v", err)
+	}
+	v.dispatch(bus.Message{Type: "approval_decision", Payload: map[string]any{"id": id}})
+
+	select {
+	case msg := <-analystCh:
+		extra := msg.Payload["extra"].(map[string]any)
+		aps := extra["approvals"].([]Approval)
+		if len(aps) != 1 || aps[0].Approver != "alice" || aps[0].DecidedAt == nil {
+			t.Fatalf("expected approver recorded in result, got %+v", aps)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting analyst after approval")
+	}
+	if atomic.LoadInt32(sent) != 1 {
+		t.Fatalf("expected dangerous tool to run once after approval, got %d", *sent)
+	}
+}
+
+func TestVerifier_RejectedTool_AbortsPipeline(t *testing.T) {
+	v, _, pipe, sent := approvalFixture(t)
+
+	id := "task-approval-reject"
+	v.dispatch(runPipelineMsg(id, pipe))
+	if _, err := decideApproval(id, false, "bob", "no"); err != nil {
+		t.Fatalf("decideApproval: %v", err)
+	}
+	v.dispatch(bus.Message{Type: "approval_decision", Payload: map[string]any{"id": id}})
+
+	res, _ := getResult(v.tasks, id)
+	if res.Status != "rejected" || res.Err == "" {
+		t.Fatalf("expected rejected result, got %+v", res)
+	}
+	if atomic.LoadInt32(sent) != 0 {
+		t.Fatalf("rejected dangerous tool must not run")
+	}
+}
+
+func TestVerifier_ApprovalTimeout_Expires(t *testing.T) {
+	v, b, pipe, sent := approvalFixture(t)
+	v.approvalTimeout = 30 * time.Millisecond
+	verifierCh := make(chan bus.Message, 1)
+	b.Subscribe("verifier", verifierCh)
+
+	id := "task-approval-expire"
+	v.dispatch(runPipelineMsg(id, pipe))
+
+	select {
+	case msg := <-verifierCh:
+		v.dispatch(msg)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting approval expiry")
+	}
+
+	res, _ := getResult(v.tasks, id)
+	if res.Status != "rejected" {
+		t.Fatalf("expected expired approval to abort the task, got %+v", res)
+	}
+	if ap := res.Data.(map[string]any)["approval"].(Approval); ap.Status != "expired" {
+		t.Fatalf("expected approval status expired, got %s", ap.Status)
+	}
+	if _, err := decideApproval(id, true, "late", ""); err == nil {
+		t.Fatalf("approving after expiry must fail")
+	}
+	if atomic.LoadInt32(sent) != 0 {
+		t.Fatalf("expired dangerous tool must not run")
+	}
+}
+
+// approvalsMux serves the API with ana and eva as customers and luis as an
+// approver.
+func approvalsMux(t *testing.T, tasks TaskStore) *http.ServeMux {
+	t.Helper()
+	principal := func(id, role, key string) auth.Principal {
+		sum := sha256.Sum256([]byte(key))
+		return auth.Principal{ID: id, Role: role, KeySHA256: hex.EncodeToString(sum[:])}
+	}
+	authn, err := auth.New("shared-key", []auth.Principal{
+		principal("ana", "customer", "ana-key"),
+		principal("eva", "customer", "eva-key"),
+		principal("luis", "approver", "luis-key"),
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	a := NewAPIAgent(bus.New(), ui.NewUIStore(), tasks, nil, authn)
+	a.RegisterHTTP(mux)
+	mux.HandleFunc("/ui/approvals", a.HandleApprovalsUI)
+	return mux
+}
+
+func TestAPIAgent_Approvals_OnlyApproversSeePendingRequests(t *testing.T) {
+	v, _, pipe, _ := approvalFixture(t)
+	id := "task-approval-list"
+	msg := runPipelineMsg(id, pipe)
+	msg.Payload["caller"] = guard.Caller{ID: "ana"}
+	v.dispatch(msg)
+	defer takeApproval(id)
+
+	mux := approvalsMux(t, v.tasks)
+	get := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, path := range []string{"/approvals", "/ui/approvals"} {
+		if rec := get(path, ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("%s anonymous: expected 401, got %d", path, rec.Code)
+		}
+		for _, key := range []string{"shared-key", "ana-key", "eva-key"} {
+			if rec := get(path, key); rec.Code != http.StatusForbidden {
+				t.Fatalf("%s with %s: expected 403, got %d %s", path, key, rec.Code, rec.Body)
+			}
+		}
+	}
+	rec := get("/approvals", "luis-key")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), id) {
+		t.Fatalf("approver: expected the pending approval, got %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAPIAgent_ApprovalDecision_ApproverIsTheAuthenticatedCaller(t *testing.T) {
+	v, _, pipe, _ := approvalFixture(t)
+	id := "task-approval-http"
+	msg := runPipelineMsg(id, pipe)
+	msg.Payload["caller"] = guard.Caller{ID: "ana"}
+	v.dispatch(msg)
+	defer takeApproval(id)
+
+	mux := approvalsMux(t, v.tasks)
+
+	approve := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/approvals/approve",
+			strings.NewReader(`{"id":"`+id+`","approver":"luis","reason":"ok"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := approve("ana-key"); rec.Code != http.StatusForbidden {
+		t.Fatalf("requester approving their own task: expected 403, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := approve("eva-key"); rec.Code != http.StatusForbidden {
+		t.Fatalf("caller without approver role: expected 403, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := approve("shared-key"); rec.Code != http.StatusForbidden {
+		t.Fatalf("caller without identity: expected 403, got %d %s", rec.Code, rec.Body)
+	}
+	if rec := approve("nope"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+	rec := approve("luis-key")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rec.Code, rec.Body)
+	}
+	var ap Approval
+	if err := json.Unmarshal(rec.Body.Bytes(), &ap); err != nil {
+		t.Fatal(err)
+	}
+	if ap.Approver != "luis" || ap.RequestedBy != "ana" || ap.Status != "approved" {
+		t.Fatalf("unexpected approval %+v", ap)
+	}
+}
@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/audit"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/auth"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+func TestVerifier_AuditLog_RecordsWriteCalls(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}))
+	defer ts.Close()
+
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	cfg := &config.Config{
+		Tools: map[string]config.Tool{
+			"check": {Name: "check", Method: "GET", URL: ts.URL + "/check", Mode: "read", TimeoutMs: 500},
+			"save": {
+				Name: "save", Method: "POST", URL: ts.URL + "/save?token={{ .token }}", Mode: "write", TimeoutMs: 500,
+				Body: map[string]string{"note": "{{ .note }}"},
+			},
+		},
+	}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{{Tool: "check"}, {Tool: "save"}}}
+	v := NewVerifier(bus.New(), cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), log)
+
+	id := "task-audit"
+	v.dispatch(bus.Message{Type: "run_pipeline", Payload: map[string]any{
+		"id":       id,
+		"intent":   "notes.save",
+		"pipeline": pipe,
+		"params":   map[string]string{"note": "hola", "token": "s3cret"},
+		"caller":   guard.Caller{ID: "ana"},
+	}})
+
+	entries, err := log.Query(audit.Query{TaskID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the write call to be audited, got %+v", entries)
+	}
+	e := entries[0]
+	if e.Tool != "save" || e.Caller != "ana" || e.Intent != "notes.save" || e.Status != http.StatusOK {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	if e.Params["note"] != "hola" || e.Params["token"] != "****" {
+		t.Fatalf("params must be recorded with secrets masked, got %+v", e.Params)
+	}
+	if e.URL != ts.URL+"/save?token=%2A%2A%2A%2A" {
+		t.Fatalf("secret query values must be masked, got %s", e.URL)
+	}
+	if _, err := audit.VerifyFile(log.Path()); err != nil {
+		t.Fatalf("VerifyFile: %v", err)
+	}
+
+	api := NewAPIAgent(bus.New(), ui.NewUIStore(), NewMemoryTaskStore(0), log, auditAuthn(t))
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/audit?caller=ana", nil)
+	req.Header.Set("X-API-Key", "auditor-key")
+	api.handleAudit(rr, req)
+	var body struct {
+		Count int        `json:"count"`
+		Head  audit.Head `json:"head"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("GET /audit: %d %v", rr.Code, err)
+	}
+	if body.Count != 1 || body.Head != log.Head() {
+		t.Fatalf("unexpected /audit response %+v", body)
+	}
+}
+
+func TestAPIAgent_Audit_DisabledWithoutLog(t *testing.T) {
+	api := NewAPIAgent(bus.New(), ui.NewUIStore(), NewMemoryTaskStore(0), nil, nil)
+	rr := httptest.NewRecorder()
+	api.handleAudit(rr, httptest.NewRequest(http.MethodGet, "/audit", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without audit log, got %d", rr.Code)
+	}
+}
+
+// auditAuthn accepts ana and luis as customers and an auditor.
+func auditAuthn(t *testing.T) *auth.Authenticator {
+	t.Helper()
+	principal := func(id, role, key string) auth.Principal {
+		sum := sha256.Sum256([]byte(key))
+		return auth.Principal{ID: id, Role: role, KeySHA256: hex.EncodeToString(sum[:])}
+	}
+	authn, err := auth.New("shared-key", []auth.Principal{
+		principal("ana", "customer", "ana-key"),
+		principal("luis", "customer", "luis-key"),
+		principal("carla", "auditor", "auditor-key"),
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authn
+}
+
+func TestAPIAgent_Audit_ScopedToTheCaller(t *testing.T) {
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	for _, e := range []audit.Entry{
+		{TaskID: "task-ana", Caller: "ana", Tool: "save"},
+		{TaskID: "task-luis", Caller: "luis", Tool: "save"},
+	} {
+		if _, err := log.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tasks := NewMemoryTaskStore(0)
+	setOwner(tasks, "task-ana", guard.Caller{ID: "ana"})
+	setOwner(tasks, "task-luis", guard.Caller{ID: "luis"})
+	api := NewAPIAgent(bus.New(), ui.NewUIStore(), tasks, log, auditAuthn(t))
+
+	get := func(path, key string) (int, []audit.Entry) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", key)
+		api.handleAudit(rr, req)
+		var body struct {
+			Entries []audit.Entry `json:"entries"`
+		}
+		_ = json.NewDecoder(rr.Body).Decode(&body)
+		return rr.Code, body.Entries
+	}
+
+	for _, path := range []string{"/audit", "/audit?caller=luis"} {
+		code, entries := get(path, "ana-key")
+		if code != http.StatusOK || len(entries) != 1 || entries[0].Caller != "ana" {
+			t.Fatalf("%s as ana: expected only ana's entry, got %d %+v", path, code, entries)
+		}
+	}
+	if code, _ := get("/audit?task=task-luis", "ana-key"); code != http.StatusNotFound {
+		t.Fatalf("task of another caller: expected 404, got %d", code)
+	}
+	if code, _ := get("/audit", "shared-key"); code != http.StatusForbidden {
+		t.Fatalf("caller without identity: expected 403, got %d", code)
+	}
+	if code, entries := get("/audit?task=task-luis", "auditor-key"); code != http.StatusOK || len(entries) != 1 {
+		t.Fatalf("auditor: expected luis's entry, got %d %+v", code, entries)
+	}
+	if code, entries := get("/audit", "auditor-key"); code != http.StatusOK || len(entries) != 2 {
+		t.Fatalf("auditor: expected every entry, got %d %+v", code, entries)
+	}
+}
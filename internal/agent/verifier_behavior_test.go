@@ -9,18 +9,17 @@ import (
 
     "github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
     "github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+    "github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
     "github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
 )
 
 // helper to wait for a stored result with timeout
-func waitStoredResult(t *testing.T, id string, d time.Duration) Result {
+func waitStoredResult(t *testing.T, tasks TaskStore, id string, d time.Duration) Result {
     t.Helper()
     deadline := time.Now().Add(d)
     for time.Now().Before(deadline) {
         time.Sleep(20 * time.Millisecond)
-        resultsMu.Lock()
-        r, ok := results[id]
-        resultsMu.Unlock()
+        r, ok := getResult(tasks, id)
         if ok {
             return r
         }
@@ -51,7 +50,7 @@ func TestVerifier_RunPipeline_SendsToAnalyst(t *testing.T) {
     }
 
     b := bus.New()
-    v := NewVerifier(b, cfg, ui.NewUIStore())
+    v := NewVerifier(b, cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
 
     // Capture message sent to analyst
     analystCh := make(chan bus.Message, 1)
@@ -89,7 +88,7 @@ func TestVerifier_MissingTool_StoresError(t *testing.T) {
     pipe := config.Pipeline{Name: "p2", Steps: []config.PipelineStep{{Tool: "nonexistent"}}}
 
     b := bus.New()
-    v := NewVerifier(b, cfg, ui.NewUIStore())
+    v := NewVerifier(b, cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
 
     id := "id-err"
     v.dispatch(bus.Message{
@@ -101,7 +100,7 @@ func TestVerifier_MissingTool_StoresError(t *testing.T) {
         },
     })
 
-    res := waitStoredResult(t, id, 1*time.Second)
+    res := waitStoredResult(t, v.tasks, id, 1*time.Second)
     if res.Status != "error" || res.Err == "" {
         t.Fatalf("expected error result stored, got: %+v", res)
     }
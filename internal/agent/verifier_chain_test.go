@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+func TestVerifier_StepOutputsFeedLaterTemplates(t *testing.T) {
+	noteCh := make(chan map[string]string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tickets":
+			_ = json.NewEncoder(w).Encode(map[string]any{"ticketId": "T-42"})
+		default:
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			body["path"] = r.URL.Path
+			noteCh <- body
+			_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+		}
+	}))
+	defer ts.Close()
+
+	cfg := &config.Config{
+		Tools: map[string]config.Tool{
+			"create_ticket": {Name: "create_ticket", Method: "POST", URL: ts.URL + "/tickets", Mode: "write", TimeoutMs: 500},
+			"add_note": {
+				Name: "add_note", Method: "POST", Mode: "write", TimeoutMs: 500,
+				URL:  ts.URL + "/tickets/{{ .steps.create_ticket.ticketId }}/notes",
+				Body: map[string]string{"ticket": "{{ .ticketRef }}"},
+			},
+		},
+	}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{
+		{Tool: "create_ticket"},
+		{Tool: "add_note", WithParams: map[string]string{"ticketRef": "{{ .steps.create_ticket.ticketId }}"}},
+		{Analyst: true},
+	}}
+
+	b := bus.New()
+	v := NewVerifier(b, cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
+	v.dispatch(runPipelineMsg("task-chain", pipe))
+
+	select {
+	case body := <-noteCh:
+		if body["path"] != "/tickets/T-42/notes" {
+			t.Fatalf("expected URL rendered from previous output, got %s", body["path"])
+		}
+		if body["ticket"] != "T-42" {
+			t.Fatalf("expected with_params mapped from previous output, got %#v", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting add_note call")
+	}
+}
+
+func TestVerifier_SequentialPipeline_RunsRepeatedToolEachTime(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"call": n})
+	}))
+	defer ts.Close()
+
+	cfg := &config.Config{
+		Tools: map[string]config.Tool{
+			"refresh": {Name: "refresh", Method: "POST", URL: ts.URL + "/refresh", Mode: "write", TimeoutMs: 500},
+			"read":    {Name: "read", Method: "GET", URL: ts.URL + "/read", Mode: "read", TimeoutMs: 500},
+		},
+	}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{
+		{Tool: "refresh"},
+		{Tool: "read"},
+		{Tool: "refresh"},
+		{Analyst: true},
+	}}
+
+	b := bus.New()
+	analystCh := make(chan bus.Message, 1)
+	b.Subscribe("analyst", analystCh)
+	v := NewVerifier(b, cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
+	v.dispatch(runPipelineMsg("task-repeated-tool", pipe))
+
+	select {
+	case <-analystCh:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting the analyst")
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("a repeated tool must run each time, got %d calls", got)
+	}
+}
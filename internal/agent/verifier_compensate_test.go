@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+// sagaFixture serves /pay, /hold and their /undo-* reversals; /notify and
+// /undo-hold fail with 500. It records every call.
+func sagaFixture(t *testing.T) (*config.Config, func() []string, func(string) map[string]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var calls []string
+	bodies := map[string]map[string]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		calls = append(calls, r.URL.Path)
+		bodies[r.URL.Path] = body
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/notify", "/undo-hold":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]any{"txId": "TX" + r.URL.Path})
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	tool := func(name, mode string) config.Tool {
+		return config.Tool{
+			Name: name, Method: "POST", URL: ts.URL + "/" + name, Mode: mode, TimeoutMs: 500,
+			Body: map[string]string{"txId": "{{ .txId }}"},
+		}
+	}
+	cfg := &config.Config{Tools: map[string]config.Tool{
+		"hold":      tool("hold", "write"),
+		"pay":       tool("pay", "write"),
+		"notify":    tool("notify", "write"),
+		"undo-hold": tool("undo-hold", "write"),
+		"undo-pay":  tool("undo-pay", "write"),
+	}}
+	get := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+	body := func(path string) map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return bodies[path]
+	}
+	return cfg, get, body
+}
+
+func TestVerifier_FailedStep_CompensatesExecutedStepsInReverse(t *testing.T) {
+	cfg, calls, body := sagaFixture(t)
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{
+		{Tool: "hold", Compensate: &config.Compensation{Tool: "undo-hold"}},
+		{Tool: "pay", Compensate: &config.Compensation{
+			Tool:       "undo-pay",
+			WithParams: map[string]string{"txId": "{{ .steps.pay.txId }}"},
+		}},
+		{Tool: "notify"},
+		{Analyst: true},
+	}}
+
+	id := "task-saga"
+	v := NewVerifier(bus.New(), cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
+	v.dispatch(runPipelineMsg(id, pipe))
+
+	res := mustResult(v.tasks, id)
+	if res.Status != "error" || res.Err == "" {
+		t.Fatalf("expected error result, got %+v", res)
+	}
+	got := calls()
+	want := []string{"/hold", "/pay", "/notify", "/undo-pay", "/undo-hold"}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected calls: %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("compensations must run in reverse order, got %v", got)
+		}
+	}
+	if body("/undo-pay")["txId"] != "TX/pay" {
+		t.Fatalf("compensation should read the step output, got %#v", body("/undo-pay"))
+	}
+
+	comps := res.Data.(map[string]any)["compensations"].([]compensationOutcome)
+	if len(comps) != 2 || comps[0].Step != "pay" || comps[0].Status != "ok" {
+		t.Fatalf("unexpected compensation report: %+v", comps)
+	}
+	if comps[1].Step != "hold" || comps[1].Status != "error" || comps[1].Error == "" {
+		t.Fatalf("failed compensation must be reported, got %+v", comps[1])
+	}
+}
+
+func TestVerifier_RejectedApproval_CompensatesExecutedSteps(t *testing.T) {
+	cfg, calls, _ := sagaFixture(t)
+	cfg.Tools["send"] = config.Tool{Name: "send", Method: "POST", URL: cfg.Tools["pay"].URL, Mode: "dangerous", TimeoutMs: 500}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{
+		{Tool: "pay", Compensate: &config.Compensation{Tool: "undo-pay"}},
+		{Tool: "send"},
+	}}
+
+	v := NewVerifier(bus.New(), cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
+	id := "task-saga-reject"
+	v.dispatch(runPipelineMsg(id, pipe))
+	if _, err := decideApproval(id, false, "bob", "no"); err != nil {
+		t.Fatalf("decideApproval: %v", err)
+	}
+	v.dispatch(bus.Message{Type: "approval_decision", Payload: map[string]any{"id": id}})
+
+	res := mustResult(v.tasks, id)
+	if res.Status != "rejected" {
+		t.Fatalf("expected rejected, got %+v", res)
+	}
+	if comps, _ := res.Data.(map[string]any)["compensations"].([]compensationOutcome); len(comps) != 1 {
+		t.Fatalf("expected the executed step to be compensated, got %+v", res.Data)
+	}
+	if got := calls(); len(got) != 2 || got[1] != "/undo-pay" {
+		t.Fatalf("unexpected calls: %v", got)
+	}
+}
@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+func TestVerifier_DAG_RunsIndependentStepsConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/merge" {
+			time.Sleep(150 * time.Millisecond)
+		}
+		mu.Lock()
+		order = append(order, r.URL.Path)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]any{"path": r.URL.Path})
+	}))
+	defer ts.Close()
+
+	cfg := &config.Config{
+		Tools: map[string]config.Tool{
+			"profile":      {Name: "profile", Method: "GET", URL: ts.URL + "/profile", Mode: "read", TimeoutMs: 1000},
+			"interactions": {Name: "interactions", Method: "GET", URL: ts.URL + "/interactions", Mode: "read", TimeoutMs: 1000},
+			"merge":        {Name: "merge", Method: "GET", URL: ts.URL + "/merge", Mode: "read", TimeoutMs: 1000},
+		},
+	}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{
+		{ID: "p", Tool: "profile", DependsOn: []string{}},
+		{ID: "i", Tool: "interactions", DependsOn: []string{}},
+		{Tool: "merge", DependsOn: []string{"p", "i"}},
+		{Analyst: true},
+	}}
+
+	b := bus.New()
+	analystCh := make(chan bus.Message, 1)
+	b.Subscribe("analyst", analystCh)
+	v := NewVerifier(b, cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
+
+	start := time.Now()
+	v.dispatch(runPipelineMsg("task-dag-parallel", pipe))
+	elapsed := time.Since(start)
+
+	if elapsed >= 290*time.Millisecond {
+		t.Fatalf("independent steps should run concurrently, took %s", elapsed)
+	}
+	mu.Lock()
+	if len(order) != 3 || order[2] != "/merge" {
+		t.Fatalf("merge must run after both dependencies, got %v", order)
+	}
+	mu.Unlock()
+
+	select {
+	case msg := <-analystCh:
+		raw := msg.Payload["rawResult"].(map[string]any)
+		for _, sid := range []string{"p", "i", "merge"} {
+			if _, ok := raw[sid]; !ok {
+				t.Fatalf("expected result keyed by step id %q, got %#v", sid, raw)
+			}
+		}
+		timings := msg.Payload["extra"].(map[string]any)["timings"].([]stepTiming)
+		if len(timings) != 3 {
+			t.Fatalf("expected one timing per step, got %#v", timings)
+		}
+		last := timings[2]
+		if last.Step != "merge" || len(last.DependsOn) != 2 || last.StartMs < 100 {
+			t.Fatalf("merge timing should start after its dependencies, got %+v", last)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting analyst")
+	}
+}
+
+func TestVerifier_DAG_ParksDangerousStepAfterInFlightSteps(t *testing.T) {
+	v, b, _, _ := approvalFixture(t)
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{
+		{ID: "check", Tool: "check", DependsOn: []string{}},
+		{ID: "send", Tool: "send", DependsOn: []string{}},
+		{Analyst: true},
+	}}
+	analystCh := make(chan bus.Message, 1)
+	b.Subscribe("analyst", analystCh)
+
+	id := "task-dag-approval"
+	v.dispatch(runPipelineMsg(id, pipe))
+
+	res, _ := getResult(v.tasks, id)
+	if res.Status != "pending_approval" {
+		t.Fatalf("expected pending_approval, got %+v", res)
+	}
+	if ap := res.Data.(map[string]any)["approval"].(Approval); ap.Step != "send" {
+		t.Fatalf("approval should reference the step id, got %+v", ap)
+	}
+
+	if _, err := decideApproval(id, true, "alice", ""); err != nil {
+		t.Fatalf("decideApproval: %v", err)
+	}
+	v.dispatch(bus.Message{Type: "approval_decision", Payload: map[string]any{"id": id}})
+
+	select {
+	case msg := <-analystCh:
+		raw := msg.Payload["rawResult"].(map[string]any)
+		if _, ok := raw["check"]; !ok {
+			t.Fatalf("step finished before parking must be kept, got %#v", raw)
+		}
+		if _, ok := raw["send"]; !ok {
+			t.Fatalf("approved step must run, got %#v", raw)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting analyst after approval")
+	}
+}
@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/tools"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+// flakyServer fails with the given status the first `failures` calls.
+func flakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &calls
+}
+
+func TestVerifier_Retry_RecoversFromTransientStatus(t *testing.T) {
+	ts, calls := flakyServer(t, 2, http.StatusServiceUnavailable)
+	cfg := &config.Config{Tools: map[string]config.Tool{
+		"status": {Name: "status", Method: "GET", URL: ts.URL, Mode: "read", TimeoutMs: 500},
+	}}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{
+		{Tool: "status", Retry: &config.RetryPolicy{Attempts: 3, BackoffMs: 5}},
+	}}
+
+	b := bus.New()
+	analystCh := make(chan bus.Message, 1)
+	b.Subscribe("analyst", analystCh)
+	v := NewVerifier(b, cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
+	v.dispatch(runPipelineMsg("task-retry-ok", pipe))
+
+	select {
+	case msg := <-analystCh:
+		timings := msg.Payload["extra"].(map[string]any)["timings"].([]stepTiming)
+		if timings[0].Attempts != 3 {
+			t.Fatalf("expected 3 attempts recorded, got %+v", timings[0])
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting analyst, result=%+v", mustResult(v.tasks, "task-retry-ok"))
+	}
+	if atomic.LoadInt32(calls) != 3 {
+		t.Fatalf("expected 3 calls, got %d", *calls)
+	}
+}
+
+func TestVerifier_Retry_OnlyOnListedConditions(t *testing.T) {
+	ts, calls := flakyServer(t, 1, http.StatusInternalServerError)
+	cfg := &config.Config{Tools: map[string]config.Tool{
+		"status": {Name: "status", Method: "GET", URL: ts.URL, Mode: "read", TimeoutMs: 500},
+	}}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{
+		{Tool: "status", Retry: &config.RetryPolicy{Attempts: 3, BackoffMs: 5, On: []string{"503"}}},
+	}}
+
+	id := "task-retry-500"
+	v := NewVerifier(bus.New(), cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
+	v.dispatch(runPipelineMsg(id, pipe))
+
+	if res := mustResult(v.tasks, id); res.Status != "error" {
+		t.Fatalf("expected error without retry on 500, got %+v", res)
+	}
+	if atomic.LoadInt32(calls) != 1 {
+		t.Fatalf("500 is not in retry.on: expected 1 call, got %d", *calls)
+	}
+}
+
+func TestVerifier_Retry_StepTimeoutOverridesTool(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer ts.Close()
+	cfg := &config.Config{Tools: map[string]config.Tool{
+		"slow": {Name: "slow", Method: "GET", URL: ts.URL, Mode: "read", TimeoutMs: 5000},
+	}}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{{Tool: "slow", TimeoutMs: 20}}}
+
+	id := "task-step-timeout"
+	start := time.Now()
+	v := NewVerifier(bus.New(), cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
+	v.dispatch(runPipelineMsg(id, pipe))
+
+	if res := mustResult(v.tasks, id); res.Status != "error" {
+		t.Fatalf("expected timeout error, got %+v", res)
+	}
+	if time.Since(start) > 150*time.Millisecond {
+		t.Fatalf("step timeout should apply instead of the tool timeout")
+	}
+}
+
+func TestVerifier_Retry_NeverRetriesNonIdempotentDangerous(t *testing.T) {
+	ts, calls := flakyServer(t, 1, http.StatusServiceUnavailable)
+	cfg := &config.Config{Tools: map[string]config.Tool{
+		"pay": {Name: "pay", Method: "POST", URL: ts.URL, Mode: "dangerous", TimeoutMs: 500},
+	}}
+	step := config.PipelineStep{Tool: "pay", Retry: &config.RetryPolicy{Attempts: 3, BackoffMs: 5}}
+
+	_, attempts, _, err := executeStep(t.Context(), "task-no-retry", step, cfg.Tools["pay"], renderOrFail(t, cfg.Tools["pay"]))
+	if err == nil || attempts != 1 || atomic.LoadInt32(calls) != 1 {
+		t.Fatalf("dangerous non-idempotent tool must run once, attempts=%d calls=%d err=%v", attempts, *calls, err)
+	}
+}
+
+func mustResult(tasks TaskStore, id string) Result {
+	res, _ := getResult(tasks, id)
+	return res
+}
+
+func renderOrFail(t *testing.T, tool config.Tool) tools.RenderedRequest {
+	t.Helper()
+	rr, err := tools.RenderRequest(tool, map[string]string{})
+	if err != nil {
+		t.Fatalf("RenderRequest: %v", err)
+	}
+	return rr
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/llm"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+func TestVerifier_ShadowMode_SimulatesWritesAndRunsReads(t *testing.T) {
+	var reads, writes int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/check" {
+			atomic.AddInt32(&reads, 1)
+		} else {
+			atomic.AddInt32(&writes, 1)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"risk": "low"})
+	}))
+	defer ts.Close()
+
+	cfg := &config.Config{
+		Tools: map[string]config.Tool{
+			"check":  {Name: "check", Method: "GET", URL: ts.URL + "/check", Mode: "read", TimeoutMs: 500},
+			"send":   {Name: "send", Method: "POST", URL: ts.URL + "/send", Mode: "dangerous", TimeoutMs: 500, Body: map[string]string{"amount": "{{ .amount }}"}},
+			"notify": {Name: "notify", Method: "POST", URL: ts.URL + "/notify", Mode: "write", TimeoutMs: 500},
+		},
+		Intents: map[string]config.Intent{
+			"banking.send_bizum": {Type: "banking.send_bizum", ShadowMode: true},
+		},
+	}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{{Tool: "check"}, {Tool: "send"}, {Tool: "notify"}}}
+
+	b := bus.New()
+	analystCh := make(chan bus.Message, 1)
+	b.Subscribe("analyst", analystCh)
+	v := NewVerifier(b, cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
+
+	v.dispatch(runPipelineMsg("task-shadow", pipe))
+
+	select {
+	case msg := <-analystCh:
+		raw := msg.Payload["rawResult"].(map[string]any)
+		sent := raw["send"].(map[string]any)
+		if sent["shadow"] != true || !strings.HasSuffix(sent["url"].(string), "/send") {
+			t.Fatalf("expected simulated send response, got %#v", sent)
+		}
+		if sent["body"].(map[string]string)["amount"] != "25" {
+			t.Fatalf("expected rendered body echoed back, got %#v", sent["body"])
+		}
+		if raw["check"].(map[string]any)["risk"] != "low" {
+			t.Fatalf("read tool should run for real, got %#v", raw["check"])
+		}
+		if msg.Payload["extra"].(map[string]any)["shadow"] != true {
+			t.Fatalf("expected shadow flag in result extra")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting analyst")
+	}
+	if atomic.LoadInt32(&reads) != 1 || atomic.LoadInt32(&writes) != 0 {
+		t.Fatalf("expected 1 real read and 0 real writes, got reads=%d writes=%d", reads, writes)
+	}
+	for _, ap := range listPendingApprovals() {
+		if ap.TaskID == "task-shadow" {
+			t.Fatalf("shadow mode must not ask for approval")
+		}
+	}
+}
+
+func TestAnalyst_ShadowSummary_SaysNothingExecuted(t *testing.T) {
+	a := NewAnalyst(bus.New(), &fakeLLM{out: "Bizum enviado"}, ui.NewUIStore(), NewMemoryTaskStore(0))
+
+	id := "task-analyst-shadow"
+	a.dispatch(bus.Message{
+		Type: "summarize",
+		Payload: map[string]any{
+			"id":        id,
+			"intent":    "banking.send_bizum",
+			"rawResult": map[string]any{"send": map[string]any{"shadow": true}},
+			"extra":     map[string]any{"shadow": true},
+		},
+	})
+
+	res, _ := getResult(a.tasks, id)
+	data := res.Data.(map[string]any)
+	if data["shadow"] != true {
+		t.Fatalf("expected shadow flag in result data, got %#v", data)
+	}
+	if s, _ := data["summary"].(string); !strings.HasPrefix(s, llm.ShadowNotice) {
+		t.Fatalf("summary must state nothing was executed, got %q", s)
+	}
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+// velocityFixture builds a verifier for a single dangerous step limited to
+// one Bizum per caller and hour. status is what the tool answers.
+func velocityFixture(t *testing.T, status *int32) (*Verifier, config.Pipeline) {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(status)))
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	cfg := &config.Config{
+		Tools: map[string]config.Tool{
+			"send": {Name: "send", Method: "POST", URL: ts.URL + "/send", Mode: "dangerous", TimeoutMs: 500},
+		},
+		Intents: map[string]config.Intent{
+			"banking.send_bizum": {
+				Type:           "banking.send_bizum",
+				AllowDangerous: true,
+				Limits:         []config.VelocityLimit{{Name: "hourly", By: "caller.id", Window: "1h", MaxCount: 1}},
+			},
+		},
+	}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{{Tool: "send"}}}
+	return NewVerifier(bus.New(), cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil), pipe
+}
+
+// approveAndRun runs a pipeline for ana up to its approval and approves it.
+func approveAndRun(t *testing.T, v *Verifier, id string, pipe config.Pipeline) Result {
+	t.Helper()
+	msg := runPipelineMsg(id, pipe)
+	msg.Payload["caller"] = guard.Caller{ID: "ana"}
+	v.dispatch(msg)
+	if res, _ := getResult(v.tasks, id); res.Status != "pending_approval" {
+		return res
+	}
+	if _, err := decideApproval(id, true, "alice", "ok"); err != nil {
+		t.Fatalf("decideApproval: %v", err)
+	}
+	v.dispatch(bus.Message{Type: "approval_decision", Payload: map[string]any{"id": id}})
+	res, _ := getResult(v.tasks, id)
+	return res
+}
+
+func TestVerifier_VelocityLimit_BlocksBeforeApproval(t *testing.T) {
+	status := int32(http.StatusOK)
+	v, pipe := velocityFixture(t, &status)
+
+	if res := approveAndRun(t, v, "task-velocity-1", pipe); res.Status == "error" {
+		t.Fatalf("first Bizum must run, got %+v", res)
+	}
+
+	res := approveAndRun(t, v, "task-velocity-2", pipe)
+	if res.Status != "error" || !strings.Contains(res.Err, "límite 'hourly' superado") || !strings.Contains(res.Err, "quedan 0") {
+		t.Fatalf("expected velocity error with remaining quota, got %+v", res)
+	}
+	for _, ap := range listPendingApprovals() {
+		if ap.TaskID == "task-velocity-2" {
+			t.Fatalf("no approval may be requested over the limit")
+		}
+	}
+}
+
+func TestVerifier_VelocityLimit_RejectedCallReturnsQuota(t *testing.T) {
+	status := int32(http.StatusBadRequest)
+	v, pipe := velocityFixture(t, &status)
+
+	if res := approveAndRun(t, v, "task-velocity-rejected", pipe); res.Status != "error" {
+		t.Fatalf("expected the tool error, got %+v", res)
+	}
+
+	atomic.StoreInt32(&status, http.StatusOK)
+	if res := approveAndRun(t, v, "task-velocity-retry", pipe); res.Status == "error" {
+		t.Fatalf("quota of a rejected call must be returned, got %+v", res)
+	}
+}
@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+func TestVerifier_When_SkipsStepAndRecordsIt(t *testing.T) {
+	var sent int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/send" {
+			atomic.AddInt32(&sent, 1)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"riskLevel": "HIGH"})
+	}))
+	defer ts.Close()
+
+	cfg := &config.Config{
+		Tools: map[string]config.Tool{
+			"aml":  {Name: "aml", Method: "POST", URL: ts.URL + "/aml", Mode: "read", TimeoutMs: 500},
+			"send": {Name: "send", Method: "POST", URL: ts.URL + "/send", Mode: "dangerous", TimeoutMs: 500},
+		},
+	}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{
+		{Tool: "aml"},
+		{Tool: "send", When: `steps["aml"].riskLevel == "LOW"`},
+		{Analyst: true},
+	}}
+
+	b := bus.New()
+	analystCh := make(chan bus.Message, 1)
+	b.Subscribe("analyst", analystCh)
+	v := NewVerifier(b, cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
+
+	id := "task-when-skip"
+	v.dispatch(runPipelineMsg(id, pipe))
+
+	select {
+	case msg := <-analystCh:
+		raw := msg.Payload["rawResult"].(map[string]any)
+		if raw["send"].(map[string]any)["skipped"] != true {
+			t.Fatalf("expected skipped step recorded in raw result, got %#v", raw["send"])
+		}
+		skipped := msg.Payload["extra"].(map[string]any)["skipped"].([]string)
+		if len(skipped) != 1 || skipped[0] != "send" {
+			t.Fatalf("expected skipped list in result, got %#v", skipped)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting analyst")
+	}
+	if atomic.LoadInt32(&sent) != 0 {
+		t.Fatalf("skipped step must not run")
+	}
+	if _, ok := takeApproval(id); ok {
+		t.Fatalf("skipped dangerous step must not ask for approval")
+	}
+}
+
+func TestVerifier_When_InvalidExpressionStoresError(t *testing.T) {
+	cfg := &config.Config{Tools: map[string]config.Tool{"t": {Name: "t", Mode: "read"}}}
+	pipe := config.Pipeline{Name: "p", Steps: []config.PipelineStep{{Tool: "t", When: `params.x ==`}}}
+	v := NewVerifier(bus.New(), cfg, ui.NewUIStore(), NewMemoryTaskStore(0), guard.NewMemoryCounterStore(), nil)
+
+	id := "task-when-invalid"
+	v.dispatch(runPipelineMsg(id, pipe))
+
+	res, _ := getResult(v.tasks, id)
+	if res.Status != "error" || res.Err == "" {
+		t.Fatalf("expected error for invalid when, got %+v", res)
+	}
+}
@@ -0,0 +1,269 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/logx"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/retry"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+)
+
+// Headers of a webhook delivery. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" with CALLBACK_SECRET.
+const (
+	headerSignature = "X-AOS-Signature"
+	headerTimestamp = "X-AOS-Timestamp"
+	headerTaskID    = "X-AOS-Task-Id"
+	headerAttempt   = "X-AOS-Delivery-Attempt"
+)
+
+// Delivery statuses of a callback.
+const (
+	callbackPending   = "pending"
+	callbackDelivered = "delivered"
+	callbackFailed    = "failed"
+)
+
+var (
+	errCallbacksDisabled  = errors.New("callbacks no configurados (CALLBACK_SECRET y CALLBACK_ALLOWED_HOSTS)")
+	errCallbackNotAllowed = errors.New("callback_url no permitida")
+)
+
+// CallbackDelivery records the webhook of a task and each attempt made to
+// deliver it.
+type CallbackDelivery struct {
+	URL      string            `json:"url"`
+	Status   string            `json:"status"`
+	Attempts []CallbackAttempt `json:"attempts,omitempty"`
+}
+
+// CallbackAttempt is one POST of the final result to the callback URL.
+type CallbackAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+// webhookNotifier delivers the final result of async tasks to their
+// callback_url. It is configured from the environment:
+//
+//	CALLBACK_SECRET        HMAC key; callbacks are disabled without it
+//	CALLBACK_ALLOWED_HOSTS comma-separated hosts, "*.example.com" allowed
+//	CALLBACK_MAX_ATTEMPTS  deliveries before giving up (default 5)
+//	CALLBACK_BACKOFF       base backoff between attempts (default 1s)
+type webhookNotifier struct {
+	secret      []byte
+	allowed     []string
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	maxWait     time.Duration // how long to wait for a parked task to finish
+	client      *http.Client
+	tasks       TaskStore
+	uiStore     *ui.UIStore
+}
+
+func newWebhookNotifier(tasks TaskStore, uiStore *ui.UIStore) *webhookNotifier {
+	n := &webhookNotifier{
+		secret:      []byte(os.Getenv("CALLBACK_SECRET")),
+		maxAttempts: 5,
+		backoff:     time.Second,
+		maxBackoff:  30 * time.Second,
+		maxWait:     DefaultTaskTTL,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		tasks:       tasks,
+		uiStore:     uiStore,
+	}
+	for _, h := range strings.Split(os.Getenv("CALLBACK_ALLOWED_HOSTS"), ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			n.allowed = append(n.allowed, h)
+		}
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(os.Getenv("CALLBACK_MAX_ATTEMPTS"))); err == nil && v > 0 {
+		n.maxAttempts = v
+	}
+	if d, err := time.ParseDuration(strings.TrimSpace(os.Getenv("CALLBACK_BACKOFF"))); err == nil && d > 0 {
+		n.backoff = d
+	}
+	return n
+}
+
+// validate checks a callback_url against the allowlist.
+func (n *webhookNotifier) validate(raw string) error {
+	if len(n.secret) == 0 || len(n.allowed) == 0 {
+		return errCallbacksDisabled
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return errCallbackNotAllowed
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range n.allowed {
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return nil
+			}
+		} else if host == allowed {
+			return nil
+		}
+	}
+	return errCallbackNotAllowed
+}
+
+// register records the callback of a task and starts waiting for its final
+// result. It must be called before the task starts running.
+func (n *webhookNotifier) register(id, callbackURL string) {
+	n.update(id, func(cb *CallbackDelivery) {
+		*cb = CallbackDelivery{URL: callbackURL, Status: callbackPending}
+	})
+	w, stop := watchTask(id, false)
+	go func() {
+		defer stop()
+		res, ok := n.waitFinal(id, w)
+		if !ok {
+			logx.Warn("Webhook", "id=%s no final result within %s, callback not sent", id, n.maxWait)
+			return
+		}
+		n.deliver(context.Background(), id, callbackURL, res)
+	}()
+}
+
+// waitFinal waits for a result that is not a parked status.
+func (n *webhookNotifier) waitFinal(id string, w *taskWatcher) (Result, bool) {
+	if res, ok := getResult(n.tasks, id); ok && !waitingStatuses[res.Status] {
+		return res, true
+	}
+	timer := time.NewTimer(n.maxWait)
+	defer timer.Stop()
+	for {
+		select {
+		case res := <-w.result:
+			if !waitingStatuses[res.Status] {
+				return res, true
+			}
+		case <-timer.C:
+			return Result{}, false
+		}
+	}
+}
+
+// deliver POSTs the result, retrying network errors, 429 and 5xx with
+// backoff. Every attempt is recorded in the task and its timeline.
+func (n *webhookNotifier) deliver(ctx context.Context, id, callbackURL string, res Result) {
+	body, err := json.Marshal(taskResultBody(id, res))
+	if err != nil {
+		n.record(id, CallbackAttempt{At: time.Now(), Error: err.Error()}, callbackFailed)
+		return
+	}
+	for attempt := 1; attempt <= n.maxAttempts; attempt++ {
+		a, retriable := n.post(ctx, id, callbackURL, body, attempt)
+		status := callbackPending
+		switch {
+		case a.Error == "":
+			status = callbackDelivered
+		case !retriable || attempt == n.maxAttempts:
+			status = callbackFailed
+		}
+		n.record(id, a, status)
+		if status != callbackPending {
+			return
+		}
+		if err := retry.Wait(ctx, retry.Delay(n.backoff, attempt, n.maxBackoff)); err != nil {
+			n.record(id, CallbackAttempt{At: time.Now(), Error: err.Error()}, callbackFailed)
+			return
+		}
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure can be
+// retried.
+func (n *webhookNotifier) post(ctx context.Context, id, callbackURL string, body []byte, attempt int) (CallbackAttempt, bool) {
+	start := time.Now()
+	a := CallbackAttempt{At: start}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		a.Error = err.Error()
+		return a, false
+	}
+	ts := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerTimestamp, ts)
+	req.Header.Set(headerSignature, "sha256="+signPayload(n.secret, ts, body))
+	req.Header.Set(headerTaskID, id)
+	req.Header.Set(headerAttempt, strconv.Itoa(attempt))
+
+	resp, err := n.client.Do(req)
+	a.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		a.Error = err.Error()
+		return a, true
+	}
+	resp.Body.Close()
+	a.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return a, false
+	}
+	a.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	return a, resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// record appends an attempt to the task and to its timeline.
+func (n *webhookNotifier) record(id string, a CallbackAttempt, status string) {
+	cb := n.update(id, func(cb *CallbackDelivery) {
+		cb.Attempts = append(cb.Attempts, a)
+		cb.Status = status
+	})
+	msg := fmt.Sprintf("intento %d: %s", len(cb.Attempts), status)
+	if a.Error != "" {
+		msg += " (" + a.Error + ")"
+		logx.Warn("Webhook", "id=%s %s", id, msg)
+	} else {
+		logx.Info("Webhook", "id=%s %s", id, msg)
+	}
+	if n.uiStore != nil {
+		n.uiStore.AddEvent(id, "Webhook", "callback", msg, fmt.Sprintf("%dms", a.DurationMs))
+	}
+}
+
+// update changes the callback of a task and returns it as saved.
+func (n *webhookNotifier) update(id string, fn func(cb *CallbackDelivery)) CallbackDelivery {
+	var saved CallbackDelivery
+	err := n.tasks.Update(id, func(rec *TaskRecord) bool {
+		cb := CallbackDelivery{}
+		if rec.Callback != nil {
+			cb = *rec.Callback
+			cb.Attempts = append([]CallbackAttempt(nil), cb.Attempts...)
+		}
+		fn(&cb)
+		rec.Callback = &cb
+		saved = cb
+		return true
+	})
+	if err != nil {
+		logStoreError(id, err)
+	}
+	return saved
+}
+
+// signPayload returns the hex HMAC-SHA256 of "<timestamp>.<body>".
+func signPayload(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
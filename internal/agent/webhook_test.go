@@ -0,0 +1,175 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
+	"github.com/stretchr/testify/require"
+)
+
+func testNotifier(t *testing.T, tasks TaskStore, uiStore *ui.UIStore) *webhookNotifier {
+	t.Helper()
+	t.Setenv("CALLBACK_SECRET", "s3cret")
+	t.Setenv("CALLBACK_ALLOWED_HOSTS", "127.0.0.1, *.example.com")
+	n := newWebhookNotifier(tasks, uiStore)
+	n.backoff = time.Millisecond
+	return n
+}
+
+func waitCallback(t *testing.T, tasks TaskStore, id string) CallbackDelivery {
+	t.Helper()
+	var cb CallbackDelivery
+	require.Eventually(t, func() bool {
+		rec, _ := tasks.Get(id)
+		if rec.Callback == nil || rec.Callback.Status == callbackPending {
+			return false
+		}
+		cb = *rec.Callback
+		return true
+	}, 2*time.Second, 5*time.Millisecond)
+	return cb
+}
+
+func TestWebhook_ValidateAllowlist(t *testing.T) {
+	disabled := newWebhookNotifier(NewMemoryTaskStore(0), nil)
+	require.ErrorIs(t, disabled.validate("https://hooks.example.com/x"), errCallbacksDisabled)
+
+	n := testNotifier(t, NewMemoryTaskStore(0), nil)
+	require.NoError(t, n.validate("https://hooks.example.com/aos"))
+	require.NoError(t, n.validate("http://127.0.0.1:8080/cb"))
+	for _, bad := range []string{
+		"https://example.com/x", // el comodín exige un subdominio
+		"https://evil.com/x",
+		"ftp://hooks.example.com/x",
+		"https://user:pw@hooks.example.com/x",
+		"not a url",
+	} {
+		require.ErrorIs(t, n.validate(bad), errCallbackNotAllowed, bad)
+	}
+}
+
+func TestWebhook_DeliversSignedResultWithRetries(t *testing.T) {
+	var calls int32
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	tasks := NewMemoryTaskStore(0)
+	uiStore := ui.NewUIStore()
+	n := testNotifier(t, tasks, uiStore)
+	id := "task-webhook-ok"
+	n.register(id, srv.URL+"/cb")
+
+	// una tarea aparcada no dispara el webhook
+	storeResult(tasks, id, Result{Status: "needs_input"})
+	storeResult(tasks, id, Result{Status: "ok", Data: map[string]any{"reply": "hecho"}})
+
+	cb := waitCallback(t, tasks, id)
+	require.Equal(t, callbackDelivered, cb.Status)
+	require.Len(t, cb.Attempts, 2)
+	require.Equal(t, http.StatusServiceUnavailable, cb.Attempts[0].StatusCode)
+	require.Equal(t, http.StatusOK, cb.Attempts[1].StatusCode)
+
+	require.Equal(t, "2", header.Get(headerAttempt))
+	require.Equal(t, id, header.Get(headerTaskID))
+	want := "sha256=" + signPayload([]byte("s3cret"), header.Get(headerTimestamp), body)
+	require.Equal(t, want, header.Get(headerSignature))
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(body, &got))
+	require.Equal(t, "ok", got["status"])
+	require.Len(t, uiStore.Events(id), 2)
+	require.Eventually(t, func() bool { return watcherCount(id) == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestWebhook_ClientErrorIsNotRetried(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	tasks := NewMemoryTaskStore(0)
+	n := testNotifier(t, tasks, nil)
+	id := "task-webhook-400"
+	storeResult(tasks, id, Result{Status: "error", Err: "boom"})
+	n.register(id, srv.URL)
+
+	cb := waitCallback(t, tasks, id)
+	require.Equal(t, callbackFailed, cb.Status)
+	require.Len(t, cb.Attempts, 1)
+	require.Equal(t, "HTTP 400", cb.Attempts[0].Error)
+	require.EqualValues(t, 1, atomic.LoadInt32(&calls))
+}
+
+func TestAPIAgent_Ask_CallbackURL(t *testing.T) {
+	t.Setenv("CALLBACK_SECRET", "s3cret")
+	t.Setenv("CALLBACK_ALLOWED_HOSTS", "hooks.example.com")
+	apiAgent := NewAPIAgent(bus.New(), ui.NewUIStore(), NewMemoryTaskStore(0), nil, nil)
+	delivered := make(chan string, 1)
+	apiAgent.webhooks.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		delivered <- r.URL.String()
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})}
+	mux := http.NewServeMux()
+	apiAgent.RegisterHTTP(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	ask := func(callback string) *http.Response {
+		body, _ := json.Marshal(map[string]any{"message": "saldo", "callback_url": callback})
+		resp, err := http.Post(ts.URL+"/ask", "application/json", bytes.NewBuffer(body))
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := ask("https://evil.com/cb")
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = ask("https://hooks.example.com/cb")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var accepted map[string]any
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&accepted))
+	id := accepted["id"].(string)
+
+	r, err := http.Get(ts.URL + "/task?id=" + id)
+	require.NoError(t, err)
+	defer r.Body.Close()
+	var out struct {
+		Callback CallbackDelivery `json:"callback"`
+	}
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&out))
+	require.Equal(t, "https://hooks.example.com/cb", out.Callback.URL)
+	require.Equal(t, callbackPending, out.Callback.Status)
+
+	storeResult(apiAgent.tasks, id, Result{Status: "ok"})
+	select {
+	case u := <-delivered:
+		require.Equal(t, "https://hooks.example.com/cb", u)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting callback delivery")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
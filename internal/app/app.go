@@ -2,7 +2,12 @@ package app
 
 import (
     "context"
+    "fmt"
     "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
 
     "github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
     "github.com/ccastromar/aos-agent-orchestration-system/internal/logx"
@@ -10,7 +15,10 @@ import (
     "golang.org/x/sync/errgroup"
 
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/agent"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/audit"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/auth"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/llm"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/ui"
 )
@@ -23,6 +31,7 @@ type App struct {
 	agents []agent.Agent
 	llm    llm.LLMClient
 	http   *HTTPServer
+	tasks  agent.TaskStore
 }
 
 // New loads environment variables if available and delegates to NewWithEnv.
@@ -46,18 +55,25 @@ func NewWithEnv(env *config.EnvVars) (*App, error) {
 	uiStore := ui.NewUIStore()
 	messageBus := bus.New()
 
-	// Select LLM client parameters from env when available (Ollama)
-	ollamaURL := "http://localhost:11434"
-	ollamaModel := "qwen3:0.6b"
-	if env != nil {
-		if env.OllamaBaseURL != "" {
-			ollamaURL = env.OllamaBaseURL
-		}
-		if env.OllamaModel != "" {
-			ollamaModel = env.OllamaModel
-		}
+	tasks, err := newTaskStore()
+	if err != nil {
+		return nil, err
+	}
+
+	auditLog, err := newAuditLog()
+	if err != nil {
+		return nil, err
+	}
+
+	authn, err := newAuthenticator()
+	if err != nil {
+		return nil, err
+	}
+
+	llmClient, err := newLLMClient(env)
+	if err != nil {
+		return nil, err
 	}
-	llmClient := llm.NewOllamaClient(ollamaURL, ollamaModel)
 
  // Mark specs as loaded only if we actually loaded non-empty specs
     specsLoaded := cfg != nil && len(cfg.Tools) > 0 && len(cfg.Pipelines) > 0 && len(cfg.Intents) > 0
@@ -68,11 +84,11 @@ func NewWithEnv(env *config.EnvVars) (*App, error) {
     }
 
 	// Crear todos los agentes
-	apiAgent := agent.NewAPIAgent(messageBus, uiStore)
+	apiAgent := agent.NewAPIAgent(messageBus, uiStore, tasks, auditLog, authn)
 	inspector := agent.NewInspector(messageBus)
-	planner := agent.NewPlanner(messageBus, cfg, llmClient, uiStore)
-	verifier := agent.NewVerifier(messageBus, cfg, uiStore)
-	analyst := agent.NewAnalyst(messageBus, llmClient, uiStore)
+	planner := agent.NewPlanner(messageBus, cfg, llmClient, uiStore, tasks)
+	verifier := agent.NewVerifier(messageBus, cfg, uiStore, tasks, guard.NewMemoryCounterStore(), auditLog)
+	analyst := agent.NewAnalyst(messageBus, llmClient, uiStore, tasks)
 
 	// Registrar subscripciones
 	//messageBus.Subscribe("api", apiAgent.Inbox())
@@ -91,9 +107,157 @@ func NewWithEnv(env *config.EnvVars) (*App, error) {
 		agents: []agent.Agent{inspector, planner, verifier, analyst},
 		llm:    llmClient,
 		http:   httpServer,
+		tasks:  tasks,
 	}, nil
 }
 
+// newAuditLog opens the audit log at AUDIT_LOG. It is disabled (nil) when
+// the variable is unset.
+func newAuditLog() (*audit.Log, error) {
+	path := strings.TrimSpace(os.Getenv("AUDIT_LOG"))
+	if path == "" {
+		return nil, nil
+	}
+	l, err := audit.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	logx.Info("App", "audit log %s (seq=%d)", path, l.Head().Seq)
+	return l, nil
+}
+
+// newLLMClient builds the providers listed in LLM_PROVIDER. With more than
+// one, they are wrapped in a FallbackClient that tries them in order. Roles
+// with their own LLM_<ROLE>_PROVIDER or LLM_<ROLE>_MODEL get their own
+// client behind a Router. Without env it falls back to a local Ollama.
+func newLLMClient(env *config.EnvVars) (llm.LLMClient, error) {
+	if env == nil {
+		return llm.NewOllamaClient("http://localhost:11434", "qwen3:0.6b"), nil
+	}
+	def, err := newLLMChain(env, env.LLMProvider, "")
+	if err != nil {
+		return nil, err
+	}
+	roles := []struct {
+		role            llm.Role
+		provider, model string
+	}{
+		{llm.RoleIntent, env.LLMIntentProvider, env.LLMIntentModel},
+		{llm.RoleParams, env.LLMParamsProvider, env.LLMParamsModel},
+		{llm.RoleSummary, env.LLMSummaryProvider, env.LLMSummaryModel},
+	}
+	routes := map[llm.Role]llm.LLMClient{}
+	for _, r := range roles {
+		if strings.TrimSpace(r.provider) == "" && strings.TrimSpace(r.model) == "" {
+			continue
+		}
+		provider := r.provider
+		if strings.TrimSpace(provider) == "" {
+			provider = env.LLMProvider
+		}
+		c, err := newLLMChain(env, provider, strings.TrimSpace(r.model))
+		if err != nil {
+			return nil, fmt.Errorf("LLM role %s: %w", r.role, err)
+		}
+		logx.Info("App", "LLM role %s: %s %s", r.role, provider, r.model)
+		routes[r.role] = c
+	}
+	if len(routes) == 0 {
+		return def, nil
+	}
+	return llm.NewRouter(def, routes), nil
+}
+
+// newLLMChain builds the providers of one LLM_PROVIDER-like list. A non
+// empty model replaces the configured one of every provider.
+func newLLMChain(env *config.EnvVars, providers, model string) (llm.LLMClient, error) {
+	names, err := llm.ParseProviders(providers)
+	if err != nil {
+		return nil, err
+	}
+	clients := make([]llm.NamedClient, 0, len(names))
+	for _, name := range names {
+		pc := llm.ProviderConfig{Name: name}
+		switch name {
+		case llm.ProviderOllama:
+			pc.BaseURL, pc.Model = env.OllamaBaseURL, env.OllamaModel
+		case llm.ProviderAnthropic:
+			pc.BaseURL, pc.APIKey, pc.Model, pc.Timeout = env.AnthropicBaseURL, env.AnthropicApiKey, env.AnthropicModel, env.LLMTimeout
+		default:
+			pc.BaseURL, pc.APIKey, pc.Model, pc.Timeout = env.LLMBaseURL, env.LLMApiKey, env.LLMModel, env.LLMTimeout
+		}
+		if model != "" {
+			pc.Model = model
+		}
+		c, err := llm.NewProvider(pc)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, llm.NamedClient{Name: name, Client: c})
+	}
+	logx.Info("App", "LLM providers: %s", strings.Join(names, " → "))
+	if len(clients) == 1 {
+		return clients[0].Client, nil
+	}
+	return llm.NewFallbackClient(env.LLMRetryAfter, clients...), nil
+}
+
+// newAuthenticator loads the credentials accepted by the API from API_KEY,
+// PRINCIPALS_FILE and AUTH_JWT_SECRET.
+func newAuthenticator() (*auth.Authenticator, error) {
+	a, err := auth.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !a.Enabled():
+		logx.Warn("App", "auth disabled: requests carry no identity (set API_KEY, PRINCIPALS_FILE or AUTH_JWT_SECRET)")
+	case a.Principals() > 0:
+		logx.Info("App", "auth: %d principals", a.Principals())
+	}
+	return a, nil
+}
+
+// newTaskStore selects the task store from the environment:
+// TASK_STORE=memory (default) or file, TASK_STORE_DIR (default data/tasks)
+// and TASK_TTL (default 24h).
+func newTaskStore() (agent.TaskStore, error) {
+	ttl := agent.DefaultTaskTTL
+	if d, err := time.ParseDuration(strings.TrimSpace(os.Getenv("TASK_TTL"))); err == nil && d > 0 {
+		ttl = d
+	}
+	switch kind := strings.TrimSpace(os.Getenv("TASK_STORE")); kind {
+	case "", "memory":
+		return agent.NewMemoryTaskStore(ttl), nil
+	case "file":
+		dir := strings.TrimSpace(os.Getenv("TASK_STORE_DIR"))
+		if dir == "" {
+			dir = filepath.Join("data", "tasks")
+		}
+		return agent.NewFileTaskStore(dir, ttl)
+	default:
+		return nil, fmt.Errorf("TASK_STORE desconocido: %s", kind)
+	}
+}
+
+// taskEvictionInterval is how often expired tasks are removed from the store.
+const taskEvictionInterval = time.Minute
+
+func (a *App) evictTasks(ctx context.Context) error {
+	ticker := time.NewTicker(taskEvictionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return a.tasks.Close()
+		case now := <-ticker.C:
+			if n := a.tasks.EvictExpired(now); n > 0 {
+				logx.Info("App", "evicted %d expired tasks", n)
+			}
+		}
+	}
+}
+
 func (a *App) Run(ctx context.Context) error {
 	g, gctx := errgroup.WithContext(ctx)
 
@@ -110,6 +274,13 @@ func (a *App) Run(ctx context.Context) error {
 		return a.http.Start(gctx)
 	})
 
+	// Caducidad de tareas en el TaskStore
+	if a.tasks != nil {
+		g.Go(func() error {
+			return a.evictTasks(gctx)
+		})
+	}
+
 	if a.env != nil {
 		logx.Info("App", "AOS v0.2.0 started (env=%s)", a.env.AppEnv)
 	} else {
@@ -12,6 +12,8 @@ import (
 
     "github.com/ccastromar/aos-agent-orchestration-system/internal/agent"
     "github.com/ccastromar/aos-agent-orchestration-system/internal/bus"
+    "github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+    "github.com/ccastromar/aos-agent-orchestration-system/internal/llm"
 )
 
 // fakeAgent implements agent.Agent for testing App.Run lifecycle.
@@ -113,3 +115,39 @@ func TestAppRun_StartsAgentsAndHTTP_AndStopsOnContextCancel(t *testing.T) {
         t.Fatal("timeout waiting for Run to return after cancel")
     }
 }
+
+func TestNewLLMClient_RoutesRoles(t *testing.T) {
+    env := &config.EnvVars{
+        LLMProvider:        "ollama",
+        OllamaBaseURL:      "http://ollama",
+        OllamaModel:        "qwen3:0.6b",
+        LLMParamsModel:     "qwen3:8b",
+        LLMSummaryProvider: "openai",
+        LLMApiKey:          "sk-test",
+        LLMModel:           "gpt-4.1",
+    }
+    c, err := newLLMClient(env)
+    if err != nil {
+        t.Fatalf("newLLMClient: %v", err)
+    }
+    r, ok := c.(*llm.Router)
+    if !ok {
+        t.Fatalf("expected a Router, got %T", c)
+    }
+    if o, ok := r.For(llm.RoleIntent).(*llm.OllamaClient); !ok || o.Model != "qwen3:0.6b" {
+        t.Fatalf("intent must use the default client, got %#v", r.For(llm.RoleIntent))
+    }
+    if o, ok := r.For(llm.RoleParams).(*llm.OllamaClient); !ok || o.Model != "qwen3:8b" {
+        t.Fatalf("params must use qwen3:8b, got %#v", r.For(llm.RoleParams))
+    }
+    if _, ok := r.For(llm.RoleSummary).(*llm.OpenAIClient); !ok {
+        t.Fatalf("summary must use openai, got %T", r.For(llm.RoleSummary))
+    }
+
+    env.LLMParamsModel, env.LLMSummaryProvider = "", ""
+    if c, _ := newLLMClient(env); c == nil {
+        t.Fatalf("expected a client")
+    } else if _, ok := c.(*llm.Router); ok {
+        t.Fatalf("no role overrides must not build a Router")
+    }
+}
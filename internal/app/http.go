@@ -39,6 +39,7 @@ func NewHTTPServer(apiAgent *agent.APIAgent, uiStore *ui.UIStore, rt *runtime.Ru
     apiAgent.RegisterHTTP(mux)
     mux.HandleFunc("/ui", uiStore.HandleIndex)
     mux.HandleFunc("/ui/task", uiStore.HandleTask)
+    mux.HandleFunc("/ui/approvals", apiAgent.HandleApprovalsUI)
     mux.HandleFunc("/health/live", health.LiveHandler)
     mux.HandleFunc("/health/ready", health.ReadyHandler(rt))
     // Expose Prometheus metrics
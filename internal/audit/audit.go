@@ -0,0 +1,258 @@
+// Package audit keeps a tamper-evident record of the write and dangerous
+// tool calls executed by AOS.
+//
+// The log is an append-only JSONL file. Every entry carries the hash of the
+// previous one and its own hash over (previous hash + entry), so editing,
+// reordering or deleting an entry breaks the chain and is reported by
+// Verify. Deleting the last entries leaves a valid but shorter chain: keep
+// the head returned by Verify somewhere else to detect it.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry is one executed tool call.
+type Entry struct {
+	Seq          uint64            `json:"seq"`
+	Time         time.Time         `json:"time"`
+	TaskID       string            `json:"taskId"`
+	Caller       string            `json:"caller,omitempty"`
+	Intent       string            `json:"intent,omitempty"`
+	Step         string            `json:"step,omitempty"`
+	Tool         string            `json:"tool"`
+	Mode         string            `json:"mode"`
+	Compensation bool              `json:"compensation,omitempty"`
+	Method       string            `json:"method"`
+	URL          string            `json:"url"`              // secrets in the query redacted
+	Params       map[string]string `json:"params,omitempty"` // secrets redacted
+	Status       int               `json:"status"`           // HTTP status; 0 when there was no response
+	Error        string            `json:"error,omitempty"`
+	PrevHash     string            `json:"prevHash"`
+	Hash         string            `json:"hash"`
+}
+
+// Head identifies the last entry of a chain.
+type Head struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// Log is an audit log file open for appending.
+type Log struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	head Head
+}
+
+// Open opens (or creates) the log at path after verifying the chain it
+// already contains. A broken chain is an error: appending to it would hide
+// the tampering.
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	head, err := VerifyFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	return &Log{path: path, f: f, head: head}, nil
+}
+
+// Path returns the file of the log.
+func (l *Log) Path() string { return l.path }
+
+// Append chains e to the log and writes it to disk. Seq, PrevHash and Hash
+// are set by the log; Time defaults to now.
+func (l *Log) Append(e Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	e.Seq = l.head.Seq + 1
+	e.PrevHash = l.head.Hash
+	hash, err := entryHash(e)
+	if err != nil {
+		return Entry{}, err
+	}
+	e.Hash = hash
+	line, err := json.Marshal(e)
+	if err != nil {
+		return Entry{}, fmt.Errorf("audit: %w", err)
+	}
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		return Entry{}, fmt.Errorf("audit: %w", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return Entry{}, fmt.Errorf("audit: %w", err)
+	}
+	l.head = Head{Seq: e.Seq, Hash: e.Hash}
+	return e, nil
+}
+
+// Head returns the last entry written.
+func (l *Log) Head() Head {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.head
+}
+
+// Close closes the file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// Query filters the entries of the log. Empty fields match everything.
+type Query struct {
+	TaskID string
+	Caller string
+	Limit  int // most recent entries returned; 0 = all
+}
+
+// Query returns the entries matching q, oldest first.
+func (l *Log) Query(q Query) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	defer f.Close()
+
+	out := []Entry{}
+	sc := newScanner(f)
+	for sc.Scan() {
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("audit: entrada ilegible: %w", err)
+		}
+		if (q.TaskID == "" || e.TaskID == q.TaskID) && (q.Caller == "" || e.Caller == q.Caller) {
+			out = append(out, e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[len(out)-q.Limit:]
+	}
+	return out, nil
+}
+
+// ChainError describes where a chain is broken.
+type ChainError struct {
+	Line   int // 1-based
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit: cadena rota en la línea %d: %s", e.Line, e.Reason)
+}
+
+// Verify reads a log and checks every entry against the previous one. It
+// returns the head of the chain, or a *ChainError for the first entry that
+// was edited, reordered or follows a deleted one.
+func Verify(r io.Reader) (Head, error) {
+	return VerifyAnchored(r, Head{})
+}
+
+// VerifyAnchored is Verify plus a check against a head recorded earlier:
+// the log must still contain that entry with the same hash, which detects
+// entries deleted from the end.
+func VerifyAnchored(r io.Reader, anchor Head) (Head, error) {
+	var head Head
+	sc := newScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		raw := sc.Bytes()
+		var e Entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return head, &ChainError{Line: line, Reason: "JSON inválido"}
+		}
+		if e.Seq != head.Seq+1 {
+			return head, &ChainError{Line: line, Reason: fmt.Sprintf("seq %d, se esperaba %d (entrada borrada o reordenada)", e.Seq, head.Seq+1)}
+		}
+		if e.PrevHash != head.Hash {
+			return head, &ChainError{Line: line, Reason: "prevHash no coincide con la entrada anterior"}
+		}
+		hash, err := entryHash(e)
+		if err != nil {
+			return head, err
+		}
+		if hash != e.Hash {
+			return head, &ChainError{Line: line, Reason: "hash no coincide (entrada modificada)"}
+		}
+		// cualquier byte añadido o cambiado fuera de los campos conocidos
+		canonical, _ := json.Marshal(e)
+		if !bytes.Equal(canonical, raw) {
+			return head, &ChainError{Line: line, Reason: "la entrada no está en forma canónica (entrada modificada)"}
+		}
+		if e.Seq == anchor.Seq && anchor.Hash != "" && e.Hash != anchor.Hash {
+			return head, &ChainError{Line: line, Reason: fmt.Sprintf("hash de seq=%d distinto del registrado", e.Seq)}
+		}
+		head = Head{Seq: e.Seq, Hash: e.Hash}
+	}
+	if err := sc.Err(); err != nil {
+		return head, fmt.Errorf("audit: %w", err)
+	}
+	if head.Seq < anchor.Seq {
+		return head, &ChainError{Line: line, Reason: fmt.Sprintf("el log termina en seq=%d pero se registró seq=%d (entradas borradas al final)", head.Seq, anchor.Seq)}
+	}
+	return head, nil
+}
+
+// VerifyFile is Verify over a file.
+func VerifyFile(path string) (Head, error) {
+	return VerifyFileAnchored(path, Head{})
+}
+
+// VerifyFileAnchored is VerifyAnchored over a file.
+func VerifyFileAnchored(path string, anchor Head) (Head, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Head{}, err
+	}
+	defer f.Close()
+	return VerifyAnchored(f, anchor)
+}
+
+// entryHash is sha256(prevHash + "\n" + JSON of the entry without hash).
+func entryHash(e Entry) (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("audit: %w", err)
+	}
+	sum := sha256.Sum256(append([]byte(e.PrevHash+"\n"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// maxLine bounds the size of one entry when reading the log.
+const maxLine = 1 << 20
+
+func newScanner(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLine)
+	return sc
+}
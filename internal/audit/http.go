@@ -0,0 +1,43 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// defaultQueryLimit bounds GET /audit when no limit is given.
+const defaultQueryLimit = 100
+
+// HandleQuery serves GET /audit?task=<id>&caller=<id>&limit=<n>. It returns
+// the matching entries, oldest first, and the current head of the chain.
+func (l *Log) HandleQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	q := Query{
+		TaskID: r.URL.Query().Get("task"),
+		Caller: r.URL.Query().Get("caller"),
+		Limit:  defaultQueryLimit,
+	}
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit inválido", http.StatusBadRequest)
+			return
+		}
+		q.Limit = n
+	}
+	entries, err := l.Query(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"entries": entries,
+		"count":   len(entries),
+		"head":    l.Head(),
+	})
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"net/url"
+	"strings"
+)
+
+// sensitiveHints are substrings of param and query names whose values are
+// never written to the log; sensitiveNames must match the whole name.
+var (
+	sensitiveHints = []string{"password", "passwd", "secret", "token", "apikey", "api_key", "authorization", "cookie"}
+	sensitiveNames = map[string]bool{"pin": true, "cvv": true, "otp": true}
+)
+
+const redacted = "****"
+
+func sensitive(name string) bool {
+	n := strings.ToLower(name)
+	if sensitiveNames[n] {
+		return true
+	}
+	for _, h := range sensitiveHints {
+		if strings.Contains(n, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactParams returns a copy of params with secret values masked.
+func RedactParams(params map[string]string) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(params))
+	for k, v := range params {
+		if sensitive(k) && v != "" {
+			v = redacted
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// RedactURL masks user info and secret query values of a URL.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for k, vs := range q {
+			if sensitive(k) {
+				for i := range vs {
+					vs[i] = redacted
+				}
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
@@ -0,0 +1,161 @@
+// Package auth maps the credentials of an HTTP request to the caller that
+// policies, velocity limits and identity-bound params see.
+//
+// Three kinds of credentials are accepted, as X-API-Key or Bearer token:
+//
+//   - the shared API_KEY: a service key with no identity;
+//   - the key of a principal from PRINCIPALS_FILE;
+//   - an HS256 JWT signed with AUTH_JWT_SECRET, whose claims are the
+//     principal.
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+	"gopkg.in/yaml.v3"
+)
+
+// Principal is an identity and the key that authenticates it. Only the
+// sha256 of the key is kept, so principal files can be committed:
+//
+//	principals:
+//	  - id: ana
+//	    role: customer
+//	    key_sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	    attrs:
+//	      phone: "+34600111222"
+//	      accountIds: ["ES7620770024003102575766"]
+//	      tenant: acme
+type Principal struct {
+	ID        string         `yaml:"id"`
+	Role      string         `yaml:"role"`
+	KeySHA256 string         `yaml:"key_sha256"`
+	Attrs     map[string]any `yaml:"attrs"`
+}
+
+func (p Principal) caller() guard.Caller {
+	return guard.Caller{ID: p.ID, Role: p.Role, Attrs: p.Attrs}
+}
+
+// LoadPrincipals reads a principals file.
+func LoadPrincipals(path string) ([]Principal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
+	var doc struct {
+		Principals []Principal `yaml:"principals"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("auth: %s: %w", path, err)
+	}
+	return doc.Principals, nil
+}
+
+// Authenticator checks the credentials of requests.
+type Authenticator struct {
+	sharedKey string
+	keys      map[[sha256.Size]byte]guard.Caller
+	jwtSecret []byte
+	now       func() time.Time
+}
+
+// New builds an Authenticator. Any of its arguments may be empty; with all
+// of them empty authentication is disabled.
+func New(sharedKey string, principals []Principal, jwtSecret string) (*Authenticator, error) {
+	a := &Authenticator{
+		sharedKey: strings.TrimSpace(sharedKey),
+		keys:      make(map[[sha256.Size]byte]guard.Caller, len(principals)),
+		jwtSecret: []byte(jwtSecret),
+		now:       time.Now,
+	}
+	seen := map[string]bool{}
+	for i, p := range principals {
+		if strings.TrimSpace(p.ID) == "" {
+			return nil, fmt.Errorf("auth: principal %d sin id", i)
+		}
+		if seen[p.ID] {
+			return nil, fmt.Errorf("auth: principal %s duplicado", p.ID)
+		}
+		if strings.HasPrefix(p.ID, jwtIDPrefix) {
+			return nil, fmt.Errorf("auth: principal %s: el prefijo %q está reservado a los JWT", p.ID, jwtIDPrefix)
+		}
+		seen[p.ID] = true
+		raw, err := hex.DecodeString(strings.TrimSpace(p.KeySHA256))
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("auth: principal %s: key_sha256 inválido", p.ID)
+		}
+		var sum [sha256.Size]byte
+		copy(sum[:], raw)
+		if _, dup := a.keys[sum]; dup {
+			return nil, fmt.Errorf("auth: principal %s: key_sha256 repetido", p.ID)
+		}
+		a.keys[sum] = p.caller()
+	}
+	return a, nil
+}
+
+// FromEnv builds the Authenticator from API_KEY, PRINCIPALS_FILE and
+// AUTH_JWT_SECRET.
+func FromEnv() (*Authenticator, error) {
+	var principals []Principal
+	if path := strings.TrimSpace(os.Getenv("PRINCIPALS_FILE")); path != "" {
+		var err error
+		if principals, err = LoadPrincipals(path); err != nil {
+			return nil, err
+		}
+	}
+	return New(os.Getenv("API_KEY"), principals, os.Getenv("AUTH_JWT_SECRET"))
+}
+
+// Enabled reports whether requests must carry credentials.
+func (a *Authenticator) Enabled() bool {
+	return a.sharedKey != "" || len(a.keys) > 0 || len(a.jwtSecret) > 0
+}
+
+// Principals returns how many principal keys are configured.
+func (a *Authenticator) Principals() int { return len(a.keys) }
+
+// Authenticate returns the caller of a request. ok is false when
+// authentication is enabled and the credentials are missing or invalid.
+// With authentication disabled, and for the shared API_KEY, the caller is
+// empty.
+func (a *Authenticator) Authenticate(r *http.Request) (caller guard.Caller, ok bool) {
+	if !a.Enabled() {
+		return guard.Caller{}, true
+	}
+	cred := credential(r)
+	if cred == "" {
+		return guard.Caller{}, false
+	}
+	if a.sharedKey != "" && subtle.ConstantTimeCompare([]byte(cred), []byte(a.sharedKey)) == 1 {
+		return guard.Caller{}, true
+	}
+	if c, found := a.keys[sha256.Sum256([]byte(cred))]; found {
+		return c, true
+	}
+	if len(a.jwtSecret) > 0 && strings.Count(cred, ".") == 2 {
+		c, err := a.verifyJWT(cred)
+		return c, err == nil
+	}
+	return guard.Caller{}, false
+}
+
+// credential returns the X-API-Key header or the Bearer token.
+func credential(r *http.Request) string {
+	if k := strings.TrimSpace(r.Header.Get("X-API-Key")); k != "" {
+		return k
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(strings.ToLower(auth), "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
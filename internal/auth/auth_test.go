@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func keyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func signJWT(t *testing.T, secret, alg string, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := enc(map[string]string{"alg": alg, "typ": "JWT"}) + "." + enc(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthenticate_Keys(t *testing.T) {
+	a, err := New("shared", []Principal{{
+		ID: "ana", Role: "customer", KeySHA256: keyHash("ana-key"),
+		Attrs: map[string]any{"phone": "+34600111222"},
+	}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-API-Key", "ana-key")
+	c, ok := a.Authenticate(r)
+	if !ok || c.ID != "ana" || c.Role != "customer" || c.Attrs["phone"] != "+34600111222" {
+		t.Fatalf("expected ana, got %+v ok=%v", c, ok)
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer shared")
+	if c, ok := a.Authenticate(r); !ok || !c.IsZero() {
+		t.Fatalf("shared key must authenticate without identity, got %+v ok=%v", c, ok)
+	}
+
+	for _, key := range []string{"", "wrong"} {
+		r = httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-API-Key", key)
+		if _, ok := a.Authenticate(r); ok {
+			t.Fatalf("key %q must be rejected", key)
+		}
+	}
+}
+
+func TestAuthenticate_Disabled(t *testing.T) {
+	a, err := New("", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := a.Authenticate(httptest.NewRequest("GET", "/", nil)); !ok || !c.IsZero() || a.Enabled() {
+		t.Fatalf("expected auth disabled, got %+v ok=%v", c, ok)
+	}
+}
+
+func TestAuthenticate_JWT(t *testing.T) {
+	a, err := New("", nil, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	exp := float64(now.Add(time.Hour).Unix())
+
+	bearer := func(token string) bool {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		_, ok := a.Authenticate(r)
+		return ok
+	}
+
+	token := signJWT(t, "s3cret", "HS256", map[string]any{
+		"sub": "ana", "role": "customer", "exp": exp, "iss": "idp", "accountIds": []string{"ES01"},
+	})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	c, ok := a.Authenticate(r)
+	if !ok || c.ID != "jwt:ana" || c.Role != "customer" {
+		t.Fatalf("expected jwt:ana from claims, got %+v ok=%v", c, ok)
+	}
+	if ids, _ := c.Attrs["accountIds"].([]any); len(ids) != 1 || ids[0] != "ES01" {
+		t.Fatalf("private claims must be attributes, got %+v", c.Attrs)
+	}
+	if _, found := c.Attrs["iss"]; found {
+		t.Fatalf("registered claims must not be attributes, got %+v", c.Attrs)
+	}
+
+	cases := map[string]string{
+		"wrong secret": signJWT(t, "other", "HS256", map[string]any{"sub": "ana", "exp": exp}),
+		"alg none":     signJWT(t, "s3cret", "none", map[string]any{"sub": "ana", "exp": exp}),
+		"expired":      signJWT(t, "s3cret", "HS256", map[string]any{"sub": "ana", "exp": float64(now.Unix())}),
+		"no exp":       signJWT(t, "s3cret", "HS256", map[string]any{"sub": "ana"}),
+		"not yet":      signJWT(t, "s3cret", "HS256", map[string]any{"sub": "ana", "exp": exp, "nbf": exp}),
+		"no sub":       signJWT(t, "s3cret", "HS256", map[string]any{"exp": exp}),
+	}
+	for name, token := range cases {
+		if bearer(token) {
+			t.Fatalf("%s: token must be rejected", name)
+		}
+	}
+}
+
+func TestNew_RejectsInvalidPrincipals(t *testing.T) {
+	cases := map[string][]Principal{
+		"no id":    {{KeySHA256: keyHash("k")}},
+		"bad hash": {{ID: "ana", KeySHA256: "abc"}},
+		"dup id":   {{ID: "ana", KeySHA256: keyHash("k1")}, {ID: "ana", KeySHA256: keyHash("k2")}},
+		"dup key":  {{ID: "ana", KeySHA256: keyHash("k")}, {ID: "bob", KeySHA256: keyHash("k")}},
+		"jwt id":   {{ID: "jwt:ana", KeySHA256: keyHash("k")}},
+	}
+	for name, principals := range cases {
+		if _, err := New("", principals, ""); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLoadPrincipals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "principals.yaml")
+	doc := "principals:\n  - id: ana\n    role: customer\n    key_sha256: " + keyHash("ana-key") +
+		"\n    attrs:\n      accountIds: [ES01, ES02]\n"
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PRINCIPALS_FILE", path)
+	t.Setenv("API_KEY", "")
+	t.Setenv("AUTH_JWT_SECRET", "")
+
+	a, err := FromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Principals() != 1 || !a.Enabled() {
+		t.Fatalf("expected one principal, got %d", a.Principals())
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-API-Key", "ana-key")
+	c, ok := a.Authenticate(r)
+	if ids, _ := c.Attrs["accountIds"].([]any); !ok || len(ids) != 2 {
+		t.Fatalf("expected accountIds from the file, got %+v ok=%v", c, ok)
+	}
+}
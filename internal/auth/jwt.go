@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/guard"
+)
+
+// registeredClaims are not copied to the caller attributes.
+var registeredClaims = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "nbf": true, "iat": true, "jti": true, "role": true,
+}
+
+// jwtIDPrefix namespaces the ids of JWT subjects, so a token cannot claim
+// the id of a principal from PRINCIPALS_FILE or the other way round.
+const jwtIDPrefix = "jwt:"
+
+// verifyJWT checks an HS256 token and maps its claims to a caller: "jwt:"
+// plus sub is the id, role the role and every other private claim an
+// attribute.
+func (a *Authenticator) verifyJWT(token string) (guard.Caller, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return guard.Caller{}, errors.New("jwt mal formado")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return guard.Caller{}, err
+	}
+	if header.Alg != "HS256" {
+		return guard.Caller{}, fmt.Errorf("jwt: alg %q no soportado", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return guard.Caller{}, errors.New("jwt: firma mal codificada")
+	}
+	mac := hmac.New(sha256.New, a.jwtSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return guard.Caller{}, errors.New("jwt: firma inválida")
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return guard.Caller{}, err
+	}
+	now := a.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return guard.Caller{}, errors.New("jwt: falta exp")
+	}
+	if !now.Before(time.Unix(int64(exp), 0)) {
+		return guard.Caller{}, errors.New("jwt: caducado")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0)) {
+		return guard.Caller{}, errors.New("jwt: todavía no es válido")
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return guard.Caller{}, errors.New("jwt: falta sub")
+	}
+
+	c := guard.Caller{ID: jwtIDPrefix + sub, Attrs: map[string]any{}}
+	c.Role, _ = claims["role"].(string)
+	for k, v := range claims {
+		if !registeredClaims[k] {
+			c.Attrs[k] = v
+		}
+	}
+	return c, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.New("jwt: segmento mal codificado")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("jwt: segmento no es JSON")
+	}
+	return nil
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/expr"
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/logx"
 	"gopkg.in/yaml.v3"
 )
@@ -19,12 +22,60 @@ type Tool struct {
     Body      map[string]string `yaml:"body"`
     Model     string            `yaml:"model"`
     Headers   map[string]string `yaml:"headers"`
+    // Idempotent permite reintentar una tool dangerous (repetirla no duplica el efecto).
+    Idempotent bool `yaml:"idempotent"`
 }
 
 type PipelineStep struct {
+	// ID identifica el step en depends_on y en steps["..."]; por defecto el nombre de la tool.
+	ID         string            `yaml:"id"`
+	DependsOn  []string          `yaml:"depends_on"`
 	Tool       string            `yaml:"tool"`
 	WithParams map[string]string `yaml:"with_params"`
 	Analyst    bool              `yaml:"analyst"`
+	// When es una expresión (ver internal/expr) sobre params y steps; si es
+	// falsa el step se salta. Ej: steps["banking.aml_risk_check"].riskLevel == "LOW"
+	When string `yaml:"when"`
+	// TimeoutMs sustituye al timeout de la tool para este step.
+	TimeoutMs int          `yaml:"timeout"`
+	Retry     *RetryPolicy `yaml:"retry"`
+	// Compensate deshace el efecto del step si un step posterior falla.
+	Compensate *Compensation `yaml:"compensate"`
+}
+
+// Compensation es la invocación que deshace un step ya ejecutado. Sus
+// with_params pueden leer la salida del step: "{{ .steps.<id>.txId }}".
+type Compensation struct {
+	Tool       string            `yaml:"tool"`
+	WithParams map[string]string `yaml:"with_params"`
+	Retry      *RetryPolicy      `yaml:"retry"`
+}
+
+// RetryPolicy define los reintentos de un step. On acepta códigos HTTP y
+// "timeout"; por defecto [429, 503, timeout].
+type RetryPolicy struct {
+	Attempts  int      `yaml:"attempts"`
+	BackoffMs int      `yaml:"backoff"`
+	On        []string `yaml:"on"`
+}
+
+// DefaultRetryOn son las condiciones reintentables si la política no indica On.
+var DefaultRetryOn = []string{"429", "503", "timeout"}
+
+// RetryOn devuelve las condiciones reintentables efectivas.
+func (r *RetryPolicy) RetryOn() []string {
+	if r == nil || len(r.On) == 0 {
+		return DefaultRetryOn
+	}
+	return r.On
+}
+
+// MaxAttempts devuelve el número total de intentos (al menos 1).
+func (r *RetryPolicy) MaxAttempts() int {
+	if r == nil || r.Attempts < 1 {
+		return 1
+	}
+	return r.Attempts
 }
 
 type Pipeline struct {
@@ -33,11 +84,77 @@ type Pipeline struct {
 	Steps       []PipelineStep `yaml:"steps"`
 }
 
+// StepID devuelve el identificador del step: su id o, si no tiene, su tool.
+func (s PipelineStep) StepID() string {
+	if s.ID != "" {
+		return s.ID
+	}
+	if s.Analyst {
+		return "analyst"
+	}
+	return s.Tool
+}
+
+// IsDAG indica si el pipeline declara dependencias explícitas. Si ningún
+// step usa depends_on, los steps se ejecutan en orden, uno tras otro.
+func (p Pipeline) IsDAG() bool {
+	for _, s := range p.Steps {
+		if s.DependsOn != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ToolSteps devuelve los steps de tool que se ejecutan y sus dependencias
+// efectivas. En un pipeline secuencial cada step depende del anterior y un
+// step analyst corta el pipeline; en un DAG cada step depende solo de lo
+// que declara y el analyst se ejecuta siempre al final. En un pipeline
+// secuencial una tool repetida se identifica por su posición (tool#N) para
+// que se ejecute cada vez.
+func (p Pipeline) ToolSteps() ([]PipelineStep, map[string][]string) {
+	dag := p.IsDAG()
+	var steps []PipelineStep
+	deps := make(map[string][]string)
+	seen := make(map[string]bool)
+	prev := ""
+	for i, s := range p.Steps {
+		if s.Analyst {
+			if !dag {
+				break
+			}
+			continue
+		}
+		id := s.StepID()
+		if !dag && seen[id] {
+			s.ID = fmt.Sprintf("%s#%d", id, i+1)
+			id = s.ID
+		}
+		seen[id] = true
+		switch {
+		case dag:
+			deps[id] = s.DependsOn
+		case prev != "":
+			deps[id] = []string{prev}
+		}
+		steps = append(steps, s)
+		prev = id
+	}
+	return steps, deps
+}
+
 type Intent struct {
 	Type           string   `yaml:"type"`
 	Description    string   `yaml:"description"`
 	Pipeline       string   `yaml:"pipeline"`
 	RequiredParams []string `yaml:"required_params"`
+	// Params declares typed params (see ParamSpec). Those not optional and
+	// without default are required, like the ones in required_params.
+	Params []ParamSpec `yaml:"params"`
+	// Policies are rules over params, caller and time checked by the guard.
+	Policies []Policy `yaml:"policies"`
+	// Limits are velocity limits enforced before the dangerous step.
+	Limits []VelocityLimit `yaml:"limits"`
 	// ----- Guard-Rails -----
 	AllowDangerous bool    `yaml:"allow_dangerous"` // puede ejecutar tools peligrosas
 	RequiresAmount bool    `yaml:"requires_amount"` // debe venir "amount"
@@ -69,10 +186,52 @@ func LoadFromDir(base string) (*Config, error) {
 	if err := loadIntentsDir(filepath.Join(base, "intents"), cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.Validate(); err != nil {
+		logx.Error("Config", "invalid definitions: %v", err)
+		return nil, err
+	}
 
 	return cfg, nil
 }
 
+// Validate comprueba lo que no se puede expresar con el esquema YAML
+// (expresiones bien formadas, …) para fallar al arrancar y no en mitad
+// de un pipeline.
+func (c *Config) Validate() error {
+	for name, intent := range c.Intents {
+		if err := validateParams(&intent); err != nil {
+			return fmt.Errorf("intent %s: %w", name, err)
+		}
+		if err := validatePolicies(&intent); err != nil {
+			return fmt.Errorf("intent %s: %w", name, err)
+		}
+		if err := validateLimits(&intent); err != nil {
+			return fmt.Errorf("intent %s: %w", name, err)
+		}
+		c.Intents[name] = intent
+	}
+	for name, p := range c.Pipelines {
+		if err := validateDAG(p); err != nil {
+			return fmt.Errorf("pipeline %s: %w", name, err)
+		}
+		for i, step := range p.Steps {
+			if err := c.validateRetry(step); err != nil {
+				return fmt.Errorf("pipeline %s step %d (%s): %w", name, i, step.StepID(), err)
+			}
+			if err := c.validateCompensation(step); err != nil {
+				return fmt.Errorf("pipeline %s step %d (%s): %w", name, i, step.StepID(), err)
+			}
+			if step.When == "" {
+				continue
+			}
+			if _, err := expr.Compile(step.When); err != nil {
+				return fmt.Errorf("pipeline %s step %d: when inválido: %w", name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
 func loadToolsDir(dir string, cfg *Config) error {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
@@ -159,3 +318,96 @@ func loadIntentsDir(dir string, cfg *Config) error {
 	}
 	return nil
 }
+
+// validateDAG comprueba ids únicos, dependencias existentes y ausencia de ciclos.
+func validateDAG(p Pipeline) error {
+	if !p.IsDAG() {
+		return nil
+	}
+	steps, deps := p.ToolSteps()
+	known := make(map[string]bool, len(steps))
+	for _, s := range steps {
+		id := s.StepID()
+		if known[id] {
+			return fmt.Errorf("step id duplicado: %s", id)
+		}
+		known[id] = true
+	}
+	for id, ds := range deps {
+		for _, d := range ds {
+			if !known[d] {
+				return fmt.Errorf("step %s depende de un step inexistente: %s", id, d)
+			}
+		}
+	}
+
+	// DFS con colores: 1 = en la pila, 2 = terminado
+	state := make(map[string]int, len(steps))
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		switch state[id] {
+		case 1:
+			return fmt.Errorf("ciclo de dependencias: %s", strings.Join(append(path, id), " -> "))
+		case 2:
+			return nil
+		}
+		state[id] = 1
+		for _, d := range deps[id] {
+			if err := visit(d, append(path, id)); err != nil {
+				return err
+			}
+		}
+		state[id] = 2
+		return nil
+	}
+	for _, s := range steps {
+		if err := visit(s.StepID(), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRetry rechaza políticas mal formadas y reintentos sobre tools
+// dangerous que no se declaran idempotentes.
+func (c *Config) validateRetry(step PipelineStep) error {
+	if step.TimeoutMs < 0 {
+		return fmt.Errorf("timeout negativo: %d", step.TimeoutMs)
+	}
+	r := step.Retry
+	if r == nil {
+		return nil
+	}
+	if r.Attempts < 0 || r.BackoffMs < 0 {
+		return fmt.Errorf("retry inválido: attempts=%d backoff=%d", r.Attempts, r.BackoffMs)
+	}
+	for _, on := range r.On {
+		if on == "timeout" {
+			continue
+		}
+		code, err := strconv.Atoi(on)
+		if err != nil || code < 100 || code > 599 {
+			return fmt.Errorf("retry.on inválido: %q (código HTTP o timeout)", on)
+		}
+	}
+	if t, ok := c.Tools[step.Tool]; ok && r.MaxAttempts() > 1 && t.Mode == "dangerous" && !t.Idempotent {
+		return fmt.Errorf("la tool %s es dangerous y no idempotente: no admite retry", t.Name)
+	}
+	return nil
+}
+
+// validateCompensation exige una tool en compensate y aplica a sus
+// reintentos las mismas reglas que a los de un step.
+func (c *Config) validateCompensation(step PipelineStep) error {
+	comp := step.Compensate
+	if comp == nil {
+		return nil
+	}
+	if strings.TrimSpace(comp.Tool) == "" {
+		return fmt.Errorf("compensate sin tool")
+	}
+	if err := c.validateRetry(PipelineStep{Tool: comp.Tool, Retry: comp.Retry}); err != nil {
+		return fmt.Errorf("compensate: %w", err)
+	}
+	return nil
+}
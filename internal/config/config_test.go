@@ -1,77 +1,307 @@
 package config
 
 import (
-    "os"
-    "path/filepath"
-    "runtime"
-    "testing"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
 )
 
 // chdirToRepoRoot ensures relative paths like "definitions/..." resolve during tests
 func chdirToRepoRoot(t *testing.T) {
-    t.Helper()
-    _, file, _, _ := runtime.Caller(0)
-    // internal/config/config_test.go -> repo root is two levels up
-    root := filepath.Clean(filepath.Join(filepath.Dir(file), "../.."))
-    if err := os.Chdir(root); err != nil {
-        t.Fatalf("chdir to repo root: %v", err)
-    }
+	t.Helper()
+	_, file, _, _ := runtime.Caller(0)
+	// internal/config/config_test.go -> repo root is two levels up
+	root := filepath.Clean(filepath.Join(filepath.Dir(file), "../.."))
+	if err := os.Chdir(root); err != nil {
+		t.Fatalf("chdir to repo root: %v", err)
+	}
 }
 
 func TestLoadFromDir_Success(t *testing.T) {
-    chdirToRepoRoot(t)
-    cfg, err := LoadFromDir("definitions")
-    if err != nil {
-        t.Fatalf("LoadFromDir returned error: %v", err)
-    }
-
-    // Basic presence
-    if len(cfg.Tools) == 0 || len(cfg.Pipelines) == 0 || len(cfg.Intents) == 0 {
-        t.Fatalf("expected non-empty tools/pipelines/intents, got: %d/%d/%d", len(cfg.Tools), len(cfg.Pipelines), len(cfg.Intents))
-    }
-
-    // Known tool from repo
-    tb, ok := cfg.Tools["banking.core_get_balance"]
-    if !ok {
-        t.Fatalf("expected tool banking.core_get_balance to be loaded")
-    }
-    if tb.Method != "GET" || tb.Mode != "read" {
-        t.Fatalf("unexpected tool fields: %+v", tb)
-    }
-
-    // Known pipeline
-    pb, ok := cfg.Pipelines["pipeline_bizum"]
-    if !ok {
-        t.Fatalf("expected pipeline_bizum to be loaded")
-    }
-    if len(pb.Steps) < 2 {
-        t.Fatalf("pipeline_bizum should have multiple steps: %+v", pb)
-    }
-    // Ensure it contains the dangerous payment tool as defined
-    found := false
-    for _, s := range pb.Steps {
-        if s.Tool == "banking.payments_bizum_send" {
-            found = true
-            break
-        }
-    }
-    if !found {
-        t.Fatalf("pipeline_bizum missing expected tool banking.payments_bizum_send")
-    }
-
-    // Known intent
-    ib, ok := cfg.Intents["banking.send_bizum"]
-    if !ok {
-        t.Fatalf("expected intent banking.send_bizum to be loaded")
-    }
-    if !ib.AllowDangerous || !ib.RequiresAmount || !ib.RequiresPhone || ib.MaxAmount != 100 {
-        t.Fatalf("unexpected intent fields: %+v", ib)
-    }
+	chdirToRepoRoot(t)
+	cfg, err := LoadFromDir("definitions")
+	if err != nil {
+		t.Fatalf("LoadFromDir returned error: %v", err)
+	}
+
+	// Basic presence
+	if len(cfg.Tools) == 0 || len(cfg.Pipelines) == 0 || len(cfg.Intents) == 0 {
+		t.Fatalf("expected non-empty tools/pipelines/intents, got: %d/%d/%d", len(cfg.Tools), len(cfg.Pipelines), len(cfg.Intents))
+	}
+
+	// Known tool from repo
+	tb, ok := cfg.Tools["banking.core_get_balance"]
+	if !ok {
+		t.Fatalf("expected tool banking.core_get_balance to be loaded")
+	}
+	if tb.Method != "GET" || tb.Mode != "read" {
+		t.Fatalf("unexpected tool fields: %+v", tb)
+	}
+
+	// Known pipeline
+	pb, ok := cfg.Pipelines["pipeline_bizum"]
+	if !ok {
+		t.Fatalf("expected pipeline_bizum to be loaded")
+	}
+	if len(pb.Steps) < 2 {
+		t.Fatalf("pipeline_bizum should have multiple steps: %+v", pb)
+	}
+	// Ensure it contains the dangerous payment tool as defined
+	found := false
+	for _, s := range pb.Steps {
+		if s.Tool == "banking.payments_bizum_send" {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatalf("pipeline_bizum missing expected tool banking.payments_bizum_send")
+	}
+
+	// Known intent
+	ib, ok := cfg.Intents["banking.send_bizum"]
+	if !ok {
+		t.Fatalf("expected intent banking.send_bizum to be loaded")
+	}
+	if !ib.AllowDangerous || !ib.RequiresAmount || !ib.RequiresPhone || ib.MaxAmount != 100 {
+		t.Fatalf("unexpected intent fields: %+v", ib)
+	}
 }
 
 func TestLoadFromDir_NotFound(t *testing.T) {
-    chdirToRepoRoot(t)
-    if _, err := LoadFromDir("non-existent-dir-12345"); err == nil {
-        t.Fatalf("expected error when loading from non-existent dir")
-    }
+	chdirToRepoRoot(t)
+	if _, err := LoadFromDir("non-existent-dir-12345"); err == nil {
+		t.Fatalf("expected error when loading from non-existent dir")
+	}
+}
+
+// writeDefs creates a minimal definitions tree with the given pipelines YAML.
+func writeDefs(t *testing.T, pipelinesYAML string) string {
+	t.Helper()
+	base := t.TempDir()
+	files := map[string]string{
+		"tools/t.yaml":     "tools:\n  - name: t1\n    type: http\n    method: GET\n    url: http://x\n    mode: read\n",
+		"pipelines/p.yaml": pipelinesYAML,
+		"intents/i.yaml":   "intents:\n  - type: x.y\n    pipeline: p1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(base, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return base
+}
+
+func TestLoadFromDir_ParsesWhen(t *testing.T) {
+	base := writeDefs(t, `pipelines:
+  - name: p1
+    steps:
+      - tool: t1
+        when: 'params.amount <= 50'
+`)
+	cfg, err := LoadFromDir(base)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+	if got := cfg.Pipelines["p1"].Steps[0].When; got != "params.amount <= 50" {
+		t.Fatalf("unexpected when: %q", got)
+	}
+}
+
+func TestLoadFromDir_RejectsInvalidWhen(t *testing.T) {
+	base := writeDefs(t, `pipelines:
+  - name: p1
+    steps:
+      - tool: t1
+        when: 'params.amount <='
+`)
+	if _, err := LoadFromDir(base); err == nil {
+		t.Fatalf("expected error for malformed when expression")
+	}
+}
+
+func TestLoadFromDir_RepoDefinitions(t *testing.T) {
+	chdirToRepoRoot(t)
+	if _, err := LoadFromDir("definitions"); err != nil {
+		t.Fatalf("repo definitions must load: %v", err)
+	}
+}
+
+func TestLoadFromDir_ParsesDependsOn(t *testing.T) {
+	base := writeDefs(t, `pipelines:
+  - name: p1
+    steps:
+      - id: a
+        tool: t1
+        depends_on: []
+      - id: b
+        tool: t1
+        depends_on: []
+      - tool: t2
+        depends_on: [a, b]
+`)
+	cfg, err := LoadFromDir(base)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+	p := cfg.Pipelines["p1"]
+	if !p.IsDAG() {
+		t.Fatalf("expected pipeline with depends_on to be a DAG")
+	}
+	_, deps := p.ToolSteps()
+	if len(deps["a"]) != 0 || len(deps["t2"]) != 2 {
+		t.Fatalf("unexpected dependencies: %#v", deps)
+	}
+}
+
+func TestToolSteps_SequentialChainsSteps(t *testing.T) {
+	p := Pipeline{Steps: []PipelineStep{{Tool: "a"}, {Tool: "b"}, {Analyst: true}, {Tool: "c"}}}
+	steps, deps := p.ToolSteps()
+	if len(steps) != 2 {
+		t.Fatalf("steps after analyst must not run in a sequential pipeline, got %d", len(steps))
+	}
+	if len(deps["a"]) != 0 || len(deps["b"]) != 1 || deps["b"][0] != "a" {
+		t.Fatalf("unexpected dependencies: %#v", deps)
+	}
+}
+
+func TestToolSteps_SequentialRepeatedToolIsNotDeduplicated(t *testing.T) {
+	p := Pipeline{Steps: []PipelineStep{{Tool: "a"}, {Tool: "b"}, {Tool: "a"}}}
+	steps, deps := p.ToolSteps()
+	if len(steps) != 3 || steps[0].StepID() != "a" || steps[2].StepID() != "a#3" {
+		t.Fatalf("a repeated tool must keep a step of its own: %+v", steps)
+	}
+	if len(deps["a#3"]) != 1 || deps["a#3"][0] != "b" {
+		t.Fatalf("unexpected dependencies: %#v", deps)
+	}
+}
+
+func TestLoadFromDir_RejectsDependencyCycle(t *testing.T) {
+	base := writeDefs(t, `pipelines:
+  - name: p1
+    steps:
+      - id: a
+        tool: t1
+        depends_on: [c]
+      - id: b
+        tool: t1
+        depends_on: [a]
+      - id: c
+        tool: t1
+        depends_on: [b]
+`)
+	_, err := LoadFromDir(base)
+	if err == nil || !strings.Contains(err.Error(), "ciclo") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
+
+func TestLoadFromDir_RejectsUnknownDependency(t *testing.T) {
+	base := writeDefs(t, `pipelines:
+  - name: p1
+    steps:
+      - id: a
+        tool: t1
+        depends_on: [missing]
+`)
+	if _, err := LoadFromDir(base); err == nil {
+		t.Fatalf("expected error for unknown dependency")
+	}
+}
+
+func TestLoadFromDir_ParsesRetryPolicy(t *testing.T) {
+	base := writeDefs(t, `pipelines:
+  - name: p1
+    steps:
+      - tool: t1
+        timeout: 2000
+        retry:
+          attempts: 3
+          backoff: 200
+          on: [429, 503, timeout]
+`)
+	cfg, err := LoadFromDir(base)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+	step := cfg.Pipelines["p1"].Steps[0]
+	if step.TimeoutMs != 2000 || step.Retry.MaxAttempts() != 3 || step.Retry.BackoffMs != 200 {
+		t.Fatalf("unexpected step policy: %+v %+v", step, step.Retry)
+	}
+	if on := step.Retry.RetryOn(); len(on) != 3 || on[0] != "429" || on[2] != "timeout" {
+		t.Fatalf("unexpected retry.on: %#v", on)
+	}
+}
+
+func TestValidate_RetryPolicy(t *testing.T) {
+	cfg := &Config{
+		Tools: map[string]Tool{
+			"pay":     {Name: "pay", Mode: "dangerous"},
+			"payIdem": {Name: "payIdem", Mode: "dangerous", Idempotent: true},
+		},
+	}
+	cases := []struct {
+		step PipelineStep
+		ok   bool
+	}{
+		{PipelineStep{Tool: "pay", Retry: &RetryPolicy{Attempts: 3}}, false},
+		{PipelineStep{Tool: "pay", Retry: &RetryPolicy{Attempts: 1}}, true},
+		{PipelineStep{Tool: "payIdem", Retry: &RetryPolicy{Attempts: 3}}, true},
+		{PipelineStep{Tool: "payIdem", Retry: &RetryPolicy{Attempts: 2, On: []string{"5xx"}}}, false},
+	}
+	for i, c := range cases {
+		cfg.Pipelines = map[string]Pipeline{"p": {Name: "p", Steps: []PipelineStep{c.step}}}
+		if err := cfg.Validate(); (err == nil) != c.ok {
+			t.Fatalf("case %d: ok=%v, got err=%v", i, c.ok, err)
+		}
+	}
+}
+
+func TestLoadFromDir_ValidatesPolicies(t *testing.T) {
+	cases := map[string]bool{
+		"    policies:\n      - name: limit\n        rule: 'amount <= 50'\n":                                    true,
+		"    policies:\n      - name: window\n        rule: 'now.hour < 17'\n        timezone: Europe/Madrid\n": true,
+		"    policies:\n      - rule: 'amount <= 50'\n":                                                         false,
+		"    policies:\n      - name: limit\n":                                                                  false,
+		"    policies:\n      - name: limit\n        rule: 'amount <='\n":                                       false,
+		"    policies:\n      - name: window\n        rule: 'true'\n        timezone: Mars/Olympus\n":           false,
+		"    policies:\n      - name: a\n        rule: 'true'\n      - name: a\n        rule: 'true'\n":         false,
+	}
+	for policies, ok := range cases {
+		base := writeDefs(t, "pipelines:\n  - name: p1\n    steps:\n      - tool: t1\n")
+		intents := "intents:\n  - type: x.y\n    pipeline: p1\n" + policies
+		if err := os.WriteFile(filepath.Join(base, "intents", "i.yaml"), []byte(intents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadFromDir(base); (err == nil) != ok {
+			t.Fatalf("policies %q: ok=%v, got err=%v", policies, ok, err)
+		}
+	}
+}
+
+func TestLoadFromDir_ValidatesLimits(t *testing.T) {
+	cases := map[string]bool{
+		"    limits:\n      - name: daily\n        by: caller.id\n        window: 24h\n        max_amount: 300\n": true,
+		"    limits:\n      - name: hourly\n        window: 1h\n        max_count: 5\n":                           true,
+		"    limits:\n      - name: daily\n        window: 24h\n":                                                 false,
+		"    limits:\n      - name: daily\n        window: 1d\n        max_count: 5\n":                            false,
+		"    limits:\n      - name: daily\n        by: 'caller.'\n        window: 1h\n        max_count: 5\n":     false,
+		"    limits:\n      - window: 1h\n        max_count: 5\n":                                                 false,
+	}
+	for limits, ok := range cases {
+		base := writeDefs(t, "pipelines:\n  - name: p1\n    steps:\n      - tool: t1\n")
+		intents := "intents:\n  - type: x.y\n    pipeline: p1\n" + limits
+		if err := os.WriteFile(filepath.Join(base, "intents", "i.yaml"), []byte(intents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadFromDir(base); (err == nil) != ok {
+			t.Fatalf("limits %q: ok=%v, got err=%v", limits, ok, err)
+		}
+	}
 }
@@ -7,24 +7,45 @@ import (
 )
 
 type EnvVars struct {
-    AppEnv       string        `env:"APP_ENV" default:"dev"`
-    Port         int           `env:"PORT" default:"8080"`
-    ReadTimeout  time.Duration `env:"READ_TIMEOUT" default:"5s"`
-    WriteTimeout time.Duration `env:"WRITE_TIMEOUT" default:"5s"`
-
-    BusWorkers int `env:"BUS_WORKERS" default:"4"`
-    BusBuffer  int `env:"BUS_BUFFER"  default:"100"`
-
-    LLMApiKey  string        `env:"LLM_API_KEY" required:"true"`
-    LLMBaseURL string        `env:"LLM_BASE_URL" default:"https://api.openai.com/v1"`
-    LLMModel   string        `env:"LLM_MODEL" default:"gpt-4.1"`
-    LLMTimeout time.Duration `env:"LLM_TIMEOUT" default:"10s"`
+    AppEnv       string        `envconfig:"APP_ENV" default:"dev"`
+    Port         int           `envconfig:"PORT" default:"8080"`
+    ReadTimeout  time.Duration `envconfig:"READ_TIMEOUT" default:"5s"`
+    WriteTimeout time.Duration `envconfig:"WRITE_TIMEOUT" default:"5s"`
+
+    BusWorkers int `envconfig:"BUS_WORKERS" default:"4"`
+    BusBuffer  int `envconfig:"BUS_BUFFER"  default:"100"`
+
+    // LLMProvider is the LLM backend, or a comma separated fallback chain
+    // tried in order: ollama, openai, anthropic, "ollama,anthropic".
+    LLMProvider string `envconfig:"LLM_PROVIDER" default:"ollama"`
+    // LLMRetryAfter is how long a failed provider of the chain is skipped.
+    LLMRetryAfter time.Duration `envconfig:"LLM_RETRY_AFTER" default:"30s"`
+
+    // OpenAI-compatible provider
+    LLMApiKey  string        `envconfig:"LLM_API_KEY"`
+    LLMBaseURL string        `envconfig:"LLM_BASE_URL" default:"https://api.openai.com/v1"`
+    LLMModel   string        `envconfig:"LLM_MODEL" default:"gpt-4.1"`
+    LLMTimeout time.Duration `envconfig:"LLM_TIMEOUT" default:"10s"`
+
+    // Anthropic Messages API
+    AnthropicApiKey  string `envconfig:"ANTHROPIC_API_KEY"`
+    AnthropicBaseURL string `envconfig:"ANTHROPIC_BASE_URL" default:"https://api.anthropic.com"`
+    AnthropicModel   string `envconfig:"ANTHROPIC_MODEL" default:"claude-sonnet-4-5"`
+
+    // Per-role overrides. A role without provider nor model uses the
+    // default client; one without provider uses LLM_PROVIDER with its model.
+    LLMIntentProvider  string `envconfig:"LLM_INTENT_PROVIDER"`
+    LLMIntentModel     string `envconfig:"LLM_INTENT_MODEL"`
+    LLMParamsProvider  string `envconfig:"LLM_PARAMS_PROVIDER"`
+    LLMParamsModel     string `envconfig:"LLM_PARAMS_MODEL"`
+    LLMSummaryProvider string `envconfig:"LLM_SUMMARY_PROVIDER"`
+    LLMSummaryModel    string `envconfig:"LLM_SUMMARY_MODEL"`
 
     // Ollama (local LLM) configuration
-    OllamaBaseURL string `env:"OLLAMA_BASE_URL" default:"http://localhost:11434"`
-    OllamaModel   string `env:"OLLAMA_MODEL" default:"qwen3:0.6b"`
+    OllamaBaseURL string `envconfig:"OLLAMA_BASE_URL" default:"http://localhost:11434"`
+    OllamaModel   string `envconfig:"OLLAMA_MODEL" default:"qwen3:0.6b"`
 
-    LogLevel string `env:"LOG_LEVEL" default:"info"`
+    LogLevel string `envconfig:"LOG_LEVEL" default:"info"`
 }
 
 func LoadEnv() (*EnvVars, error) {
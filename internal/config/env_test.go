@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLoadEnv_ReadsDocumentedNames(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "ollama,openai")
+	t.Setenv("LLM_API_KEY", "sk-test")
+	t.Setenv("LLM_RETRY_AFTER", "10s")
+	t.Setenv("OLLAMA_BASE_URL", "http://gpu:11434")
+	t.Setenv("PORT", "9090")
+
+	env, err := LoadEnv()
+	if err != nil {
+		t.Fatalf("LoadEnv: %v", err)
+	}
+	if env.LLMProvider != "ollama,openai" || env.LLMApiKey != "sk-test" || env.LLMRetryAfter != 10*time.Second {
+		t.Fatalf("unexpected LLM settings: %+v", env)
+	}
+	if env.OllamaBaseURL != "http://gpu:11434" || env.Port != 9090 {
+		t.Fatalf("unexpected settings: %+v", env)
+	}
+}
+
+func TestLoadEnv_Defaults(t *testing.T) {
+	for _, k := range []string{"LLM_PROVIDER", "LLM_API_KEY", "OLLAMA_BASE_URL"} {
+		t.Setenv(k, "") // restaura el valor al acabar
+		os.Unsetenv(k)
+	}
+	env, err := LoadEnv()
+	if err != nil {
+		t.Fatalf("LoadEnv without LLM_API_KEY: %v", err)
+	}
+	if env.LLMProvider != "ollama" || env.OllamaBaseURL != "http://localhost:11434" {
+		t.Fatalf("unexpected defaults: %+v", env)
+	}
+}
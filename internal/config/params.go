@@ -0,0 +1,386 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/expr"
+)
+
+// Param types of a ParamSpec.
+const (
+	ParamString  = "string"
+	ParamInt     = "int"
+	ParamDecimal = "decimal"
+	ParamDate    = "date"
+	ParamPhone   = "phone"
+	ParamEnum    = "enum"
+)
+
+// ParamSpec declares a typed param of an intent:
+//
+//	params:
+//	  - name: amount
+//	    type: decimal
+//	    min: 0.01
+//	    max: 100
+//	    description: Importe en euros
+//	  - name: fromPhone
+//	    type: phone
+//	    from: caller.phone          # never taken from the message
+//	  - name: accountId
+//	    owned_by: caller.accountIds # must be one of the caller's
+type ParamSpec struct {
+	Name        string   `yaml:"name"`
+	Type        string   `yaml:"type"`    // string (default), int, decimal, date, phone, enum
+	Pattern     string   `yaml:"pattern"` // regexp the coerced value must match
+	Min         *float64 `yaml:"min"`     // int/decimal: value; string: length
+	Max         *float64 `yaml:"max"`
+	Values      []string `yaml:"values"` // enum
+	Default     string   `yaml:"default"`
+	Optional    bool     `yaml:"optional"`
+	Description string   `yaml:"description"`
+	// From binds the param to the identity of the caller: an expression
+	// over caller whose value replaces whatever the message says.
+	From string `yaml:"from"`
+	// OwnedBy is an expression over caller; the value of the param must be
+	// in it (a list) or equal to it.
+	OwnedBy string `yaml:"owned_by"`
+
+	pattern *regexp.Regexp
+	from    *expr.Expr
+	ownedBy *expr.Expr
+}
+
+// dateLayouts are the formats accepted for date params; values are
+// normalized to the first one.
+var dateLayouts = []string{"2006-01-02", "02/01/2006", "2/1/2006", time.RFC3339}
+
+var phoneRe = regexp.MustCompile(`^\+?[0-9]{6,15}$`)
+
+// Required returns the params the Planner must ask for when missing:
+// required_params plus the declared params that are neither optional, nor
+// have a default, nor come from the caller.
+func (i Intent) Required() []string {
+	out := append([]string(nil), i.RequiredParams...)
+	for _, p := range i.Params {
+		if !p.Optional && p.Default == "" && p.From == "" && !contains(out, p.Name) {
+			out = append(out, p.Name)
+		}
+	}
+	return out
+}
+
+// ParamNames returns every param the intent knows about, to be extracted
+// from the user message. Params bound to the caller are left out.
+func (i Intent) ParamNames() []string {
+	out := append([]string(nil), i.RequiredParams...)
+	for _, p := range i.Params {
+		if p.From == "" && !contains(out, p.Name) {
+			out = append(out, p.Name)
+		}
+	}
+	return out
+}
+
+// ParamSpec returns the declaration of a param, if any.
+func (i Intent) ParamSpec(name string) (ParamSpec, bool) {
+	for _, p := range i.Params {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return ParamSpec{}, false
+}
+
+// Hint describes the param for the ExtractParams prompt, e.g.
+// "decimal, min 0.01, max 100. Importe en euros".
+func (p ParamSpec) Hint() string {
+	parts := []string{p.typeName()}
+	if p.Type == ParamEnum {
+		parts[0] += " (" + strings.Join(p.Values, " | ") + ")"
+	}
+	if p.Type == ParamDate {
+		parts = append(parts, "format YYYY-MM-DD")
+	}
+	if p.Min != nil {
+		parts = append(parts, "min "+formatNumber(*p.Min))
+	}
+	if p.Max != nil {
+		parts = append(parts, "max "+formatNumber(*p.Max))
+	}
+	hint := strings.Join(parts, ", ")
+	if d := strings.TrimSpace(p.Description); d != "" {
+		hint += ". " + d
+	}
+	return hint
+}
+
+// Identity returns the parsed from and owned_by expressions (nil when not
+// set), compiling them when the param was not loaded through Validate.
+func (p ParamSpec) Identity() (from, ownedBy *expr.Expr, err error) {
+	from, ownedBy = p.from, p.ownedBy
+	if from == nil && strings.TrimSpace(p.From) != "" {
+		if from, err = expr.Compile(p.From); err != nil {
+			return nil, nil, fmt.Errorf("from: %w", err)
+		}
+	}
+	if ownedBy == nil && strings.TrimSpace(p.OwnedBy) != "" {
+		if ownedBy, err = expr.Compile(p.OwnedBy); err != nil {
+			return nil, nil, fmt.Errorf("owned_by: %w", err)
+		}
+	}
+	return from, ownedBy, nil
+}
+
+func (p ParamSpec) typeName() string {
+	if p.Type == "" {
+		return ParamString
+	}
+	return p.Type
+}
+
+// Coerce validates a raw value and returns it in canonical form: trimmed,
+// numbers without thousands separators, dates as YYYY-MM-DD, phones as
+// digits, enums with the declared spelling.
+func (p ParamSpec) Coerce(raw string) (string, error) {
+	v := strings.TrimSpace(raw)
+	var num float64
+	switch p.typeName() {
+	case ParamString:
+		num = float64(len([]rune(v)))
+	case ParamInt:
+		f, err := parseNumber(v)
+		if err != nil || f != float64(int64(f)) {
+			return "", fmt.Errorf("%s: %q no es un entero", p.Name, raw)
+		}
+		v, num = strconv.FormatInt(int64(f), 10), f
+	case ParamDecimal:
+		f, err := parseNumber(v)
+		if err != nil {
+			return "", fmt.Errorf("%s: %q no es un número", p.Name, raw)
+		}
+		v, num = formatNumber(f), f
+	case ParamDate:
+		d, ok := parseDate(v)
+		if !ok {
+			return "", fmt.Errorf("%s: %q no es una fecha (YYYY-MM-DD)", p.Name, raw)
+		}
+		v = d
+	case ParamPhone:
+		v = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "").Replace(v)
+		if !phoneRe.MatchString(v) {
+			return "", fmt.Errorf("%s: %q no es un teléfono válido", p.Name, raw)
+		}
+	case ParamEnum:
+		found := false
+		for _, allowed := range p.Values {
+			if strings.EqualFold(v, allowed) {
+				v, found = allowed, true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("%s: %q no es uno de %v", p.Name, raw, p.Values)
+		}
+	default:
+		return "", fmt.Errorf("%s: tipo desconocido %q", p.Name, p.Type)
+	}
+
+	if hasBounds(p.typeName()) {
+		what := "valor"
+		if p.typeName() == ParamString {
+			what = "longitud"
+		}
+		if p.Min != nil && num < *p.Min {
+			return "", fmt.Errorf("%s: %s %s por debajo del mínimo %s", p.Name, what, formatNumber(num), formatNumber(*p.Min))
+		}
+		if p.Max != nil && num > *p.Max {
+			return "", fmt.Errorf("%s: %s %s excede el máximo %s", p.Name, what, formatNumber(num), formatNumber(*p.Max))
+		}
+	}
+	if p.Pattern != "" {
+		re := p.pattern
+		if re == nil {
+			var err error
+			if re, err = regexp.Compile(p.Pattern); err != nil {
+				return "", fmt.Errorf("%s: pattern inválido: %w", p.Name, err)
+			}
+		}
+		if !re.MatchString(v) {
+			return "", fmt.Errorf("%s: %q no cumple el patrón %s", p.Name, raw, p.Pattern)
+		}
+	}
+	return v, nil
+}
+
+// guardRailParams turns the requires_amount, requires_phone and
+// max_amount guard-rails into typed params: amount is a required decimal
+// above zero and up to max_amount, toPhone a required phone. A declared
+// param keeps its own settings unless they are looser. Like the rest of
+// the guard-rails they only apply to intents with allow_dangerous.
+func guardRailParams(intent *Intent) error {
+	if !intent.AllowDangerous {
+		return nil
+	}
+	if intent.RequiresAmount {
+		p := intent.paramSpecFor("amount", ParamDecimal)
+		if t := p.typeName(); t != ParamDecimal && t != ParamInt {
+			return fmt.Errorf("requires_amount: param amount debe ser decimal o int, no %s", t)
+		}
+		if p.Min == nil || *p.Min <= 0 {
+			min := 0.01
+			p.Min = &min
+		}
+		if intent.MaxAmount > 0 && (p.Max == nil || *p.Max > intent.MaxAmount) {
+			max := intent.MaxAmount
+			p.Max = &max
+		}
+		p.Optional, p.Default = false, ""
+	}
+	if intent.RequiresPhone {
+		p := intent.paramSpecFor("toPhone", ParamPhone)
+		if t := p.typeName(); t != ParamPhone {
+			return fmt.Errorf("requires_phone: param toPhone debe ser phone, no %s", t)
+		}
+		p.Optional, p.Default = false, ""
+	}
+	return nil
+}
+
+// WithGuardRails returns a copy of the intent with its guard-rails turned
+// into typed params, as the loader does. Applying it to a loaded intent
+// changes nothing; intents built in code get the same checks.
+func (i Intent) WithGuardRails() (Intent, error) {
+	i.Params = append([]ParamSpec(nil), i.Params...)
+	if err := guardRailParams(&i); err != nil {
+		return Intent{}, err
+	}
+	return i, nil
+}
+
+// paramSpecFor returns the declaration of a param, adding one of type typ
+// when there is none. An untyped declaration gets typ.
+func (i *Intent) paramSpecFor(name, typ string) *ParamSpec {
+	for k := range i.Params {
+		if p := &i.Params[k]; p.Name == name {
+			if p.Type == "" {
+				p.Type = typ
+			}
+			return p
+		}
+	}
+	i.Params = append(i.Params, ParamSpec{Name: name, Type: typ})
+	return &i.Params[len(i.Params)-1]
+}
+
+// validateParams checks the param declarations of an intent and compiles
+// their patterns.
+func validateParams(intent *Intent) error {
+	if err := guardRailParams(intent); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for i := range intent.Params {
+		p := &intent.Params[i]
+		if strings.TrimSpace(p.Name) == "" {
+			return fmt.Errorf("param %d sin name", i)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("param %s duplicado", p.Name)
+		}
+		seen[p.Name] = true
+		switch p.typeName() {
+		case ParamString, ParamInt, ParamDecimal, ParamDate, ParamPhone:
+		case ParamEnum:
+			if len(p.Values) == 0 {
+				return fmt.Errorf("param %s: enum sin values", p.Name)
+			}
+		default:
+			return fmt.Errorf("param %s: type inválido %q (string, int, decimal, date, phone, enum)", p.Name, p.Type)
+		}
+		if (p.Min != nil || p.Max != nil) && !hasBounds(p.typeName()) {
+			return fmt.Errorf("param %s: min/max no aplican a %s", p.Name, p.typeName())
+		}
+		if p.Min != nil && p.Max != nil && *p.Min > *p.Max {
+			return fmt.Errorf("param %s: min > max", p.Name)
+		}
+		if p.Pattern != "" {
+			re, err := regexp.Compile(p.Pattern)
+			if err != nil {
+				return fmt.Errorf("param %s: pattern inválido: %w", p.Name, err)
+			}
+			p.pattern = re
+		}
+		if p.Default != "" {
+			if _, err := p.Coerce(p.Default); err != nil {
+				return fmt.Errorf("param %s: default inválido: %w", p.Name, err)
+			}
+		}
+		if p.From != "" && p.Default != "" {
+			return fmt.Errorf("param %s: from y default son incompatibles", p.Name)
+		}
+		if p.From != "" && contains(intent.RequiredParams, p.Name) {
+			return fmt.Errorf("param %s: viene de from, no puede estar en required_params", p.Name)
+		}
+		from, ownedBy, err := p.Identity()
+		if err != nil {
+			return fmt.Errorf("param %s: %w", p.Name, err)
+		}
+		p.from, p.ownedBy = from, ownedBy
+	}
+	return nil
+}
+
+func hasBounds(typ string) bool {
+	return typ == ParamInt || typ == ParamDecimal || typ == ParamString
+}
+
+// parseNumber accepts "1234.5", "1.234,5", "1,234.5", "25,50" and a
+// trailing or leading currency sign. NaN and infinities are rejected: they
+// slip past every min/max comparison.
+func parseNumber(s string) (float64, error) {
+	s = strings.TrimSpace(strings.Trim(strings.TrimSpace(s), "€$"))
+	if strings.Contains(s, ",") && strings.Contains(s, ".") {
+		if strings.LastIndex(s, ",") > strings.LastIndex(s, ".") {
+			s = strings.ReplaceAll(s, ".", "") // 1.234,5
+		} else {
+			s = strings.ReplaceAll(s, ",", "") // 1,234.5
+		}
+	}
+	s = strings.ReplaceAll(s, ",", ".")
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%q is not a finite number", s)
+	}
+	return f, nil
+}
+
+func parseDate(s string) (string, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(dateLayouts[0]), true
+		}
+	}
+	return "", false
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func ptr(f float64) *float64 { return &f }
+
+func TestParamSpec_Coerce(t *testing.T) {
+	cases := []struct {
+		spec ParamSpec
+		raw  string
+		want string
+		ok   bool
+	}{
+		{ParamSpec{Name: "s"}, "  hola ", "hola", true},
+		{ParamSpec{Name: "s", Max: ptr(3)}, "hola", "", false},
+		{ParamSpec{Name: "n", Type: ParamInt}, "42", "42", true},
+		{ParamSpec{Name: "n", Type: ParamInt}, "4.5", "", false},
+		{ParamSpec{Name: "a", Type: ParamDecimal, Min: ptr(0.01), Max: ptr(100)}, "25,50 €", "25.5", true},
+		{ParamSpec{Name: "a", Type: ParamDecimal}, "1.234,5", "1234.5", true},
+		{ParamSpec{Name: "a", Type: ParamDecimal, Max: ptr(100)}, "150", "", false},
+		{ParamSpec{Name: "a", Type: ParamDecimal}, "mucho", "", false},
+		{ParamSpec{Name: "a", Type: ParamDecimal, Min: ptr(0.01), Max: ptr(100)}, "NaN", "", false},
+		{ParamSpec{Name: "a", Type: ParamDecimal}, "Inf", "", false},
+		{ParamSpec{Name: "a", Type: ParamDecimal}, "-Inf", "", false},
+		{ParamSpec{Name: "n", Type: ParamInt}, "NaN", "", false},
+		{ParamSpec{Name: "n", Type: ParamInt}, "+Inf", "", false},
+		{ParamSpec{Name: "d", Type: ParamDate}, "17/10/2026", "2026-10-17", true},
+		{ParamSpec{Name: "d", Type: ParamDate}, "ayer", "", false},
+		{ParamSpec{Name: "p", Type: ParamPhone}, "+34 600-111-222", "+34600111222", true},
+		{ParamSpec{Name: "p", Type: ParamPhone}, "600abc", "", false},
+		{ParamSpec{Name: "e", Type: ParamEnum, Values: []string{"EUR", "USD"}}, "usd", "USD", true},
+		{ParamSpec{Name: "e", Type: ParamEnum, Values: []string{"EUR", "USD"}}, "GBP", "", false},
+		{ParamSpec{Name: "id", Pattern: `^ES[0-9]{4}$`}, "ES1234", "ES1234", true},
+		{ParamSpec{Name: "id", Pattern: `^ES[0-9]{4}$`}, "FR1234", "", false},
+	}
+	for i, c := range cases {
+		got, err := c.spec.Coerce(c.raw)
+		if (err == nil) != c.ok || got != c.want {
+			t.Fatalf("case %d (%s %q): got %q err=%v, want %q ok=%v", i, c.spec.Name, c.raw, got, err, c.want, c.ok)
+		}
+	}
+}
+
+func TestIntent_RequiredAndParamNames(t *testing.T) {
+	intent := Intent{
+		RequiredParams: []string{"accountId"},
+		Params: []ParamSpec{
+			{Name: "amount", Type: ParamDecimal},
+			{Name: "currency", Type: ParamEnum, Values: []string{"EUR"}, Default: "EUR"},
+			{Name: "concept", Optional: true},
+			{Name: "accountId"},
+		},
+	}
+	if got := strings.Join(intent.Required(), ","); got != "accountId,amount" {
+		t.Fatalf("unexpected required: %s", got)
+	}
+	if got := strings.Join(intent.ParamNames(), ","); got != "accountId,amount,currency,concept" {
+		t.Fatalf("unexpected names: %s", got)
+	}
+}
+
+func TestIntent_ParamsFromCallerAreNotAskedFor(t *testing.T) {
+	intent := Intent{Params: []ParamSpec{
+		{Name: "amount", Type: ParamDecimal},
+		{Name: "fromPhone", Type: ParamPhone, From: "caller.phone"},
+		{Name: "accountId", OwnedBy: "caller.accountIds"},
+	}}
+	if got := strings.Join(intent.Required(), ","); got != "amount,accountId" {
+		t.Fatalf("unexpected required: %s", got)
+	}
+	if got := strings.Join(intent.ParamNames(), ","); got != "amount,accountId" {
+		t.Fatalf("unexpected names: %s", got)
+	}
+}
+
+func TestParamSpec_Hint(t *testing.T) {
+	p := ParamSpec{Name: "amount", Type: ParamDecimal, Min: ptr(0.01), Max: ptr(100), Description: "Importe en euros"}
+	if got := p.Hint(); got != "decimal, min 0.01, max 100. Importe en euros" {
+		t.Fatalf("unexpected hint: %q", got)
+	}
+}
+
+func TestLoadFromDir_ValidatesParams(t *testing.T) {
+	cases := map[string]bool{
+		"    params:\n      - name: amount\n        type: decimal\n        max: 100\n":         true,
+		"    params:\n      - name: amount\n        type: money\n":                             false,
+		"    params:\n      - name: when\n        type: date\n        min: 1\n":                false,
+		"    params:\n      - name: c\n        type: enum\n":                                   false,
+		"    params:\n      - name: c\n        pattern: '['\n":                                 false,
+		"    params:\n      - name: n\n        type: int\n        default: x\n":                false,
+		"    params:\n      - name: a\n      - name: a\n":                                      false,
+		"    params:\n      - name: p\n        from: caller.phone\n":                           true,
+		"    params:\n      - name: p\n        from: 'caller.'\n":                              false,
+		"    params:\n      - name: p\n        from: caller.phone\n        default: x\n":       false,
+		"    params:\n      - name: a\n        owned_by: caller.accountIds\n":                  true,
+		"    params:\n      - name: a\n        owned_by: 'caller.accountIds['\n":               false,
+		"    required_params: [p]\n    params:\n      - name: p\n        from: caller.phone\n": false,
+	}
+	for params, ok := range cases {
+		base := writeDefs(t, "pipelines:\n  - name: p1\n    steps:\n      - tool: t1\n")
+		intents := "intents:\n  - type: x.y\n    pipeline: p1\n" + params
+		if err := os.WriteFile(filepath.Join(base, "intents", "i.yaml"), []byte(intents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadFromDir(base); (err == nil) != ok {
+			t.Fatalf("params %q: ok=%v, got err=%v", params, ok, err)
+		}
+	}
+}
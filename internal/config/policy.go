@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // timezone: funciona aunque el host no tenga zoneinfo
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/expr"
+)
+
+// Policy is a named rule an intent must satisfy before its pipeline runs:
+//
+//	policies:
+//	  - name: bizum_limit_for_customers
+//	    rule: 'amount <= 50 || caller.role == "teller"'
+//	    message: Los clientes no pueden enviar más de 50 €
+//
+// The rule is an expression (see internal/expr) over params (also
+// reachable by name), caller and now.
+type Policy struct {
+	Name     string `yaml:"name"`
+	Rule     string `yaml:"rule"`
+	Message  string `yaml:"message"`  // shown when the rule is violated
+	Timezone string `yaml:"timezone"` // IANA zone for now.*; server local time by default
+
+	rule *expr.Expr
+	loc  *time.Location
+}
+
+// Compiled returns the parsed rule and the location of now.*, compiling
+// them when the policy was not loaded through Validate.
+func (p Policy) Compiled() (*expr.Expr, *time.Location, error) {
+	rule, loc := p.rule, p.loc
+	if rule == nil {
+		var err error
+		if rule, err = expr.Compile(p.Rule); err != nil {
+			return nil, nil, err
+		}
+	}
+	if loc == nil {
+		loc = time.Local
+		if p.Timezone != "" {
+			var err error
+			if loc, err = time.LoadLocation(p.Timezone); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return rule, loc, nil
+}
+
+// validatePolicies checks the policies of an intent and compiles them.
+func validatePolicies(intent *Intent) error {
+	seen := map[string]bool{}
+	for i := range intent.Policies {
+		p := &intent.Policies[i]
+		if strings.TrimSpace(p.Name) == "" {
+			return fmt.Errorf("policy %d sin name", i)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("policy %s duplicada", p.Name)
+		}
+		seen[p.Name] = true
+		if strings.TrimSpace(p.Rule) == "" {
+			return fmt.Errorf("policy %s sin rule", p.Name)
+		}
+		rule, loc, err := p.Compiled()
+		if err != nil {
+			return fmt.Errorf("policy %s inválida: %w", p.Name, err)
+		}
+		p.rule, p.loc = rule, loc
+	}
+	return nil
+}
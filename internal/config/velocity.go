@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/expr"
+)
+
+// VelocityLimit caps how many times, or how much, an intent can be used by
+// the same key within a sliding window:
+//
+//	limits:
+//	  - name: bizum_daily_amount
+//	    by: caller.id
+//	    window: 24h
+//	    max_amount: 300
+//	  - name: bizum_hourly_count
+//	    by: caller.id
+//	    window: 1h
+//	    max_count: 5
+//
+// Limits with the same name share their counters, also across intents.
+type VelocityLimit struct {
+	Name      string  `yaml:"name"`
+	By        string  `yaml:"by"`         // expression over params and caller; the key of the counter
+	Window    string  `yaml:"window"`     // Go duration: 1h, 24h
+	MaxCount  int     `yaml:"max_count"`  // operations per window
+	MaxAmount float64 `yaml:"max_amount"` // sum of Amount per window
+	Amount    string  `yaml:"amount"`     // param summed by max_amount; "amount" by default
+
+	by     *expr.Expr
+	window time.Duration
+}
+
+// AmountParam returns the param summed by max_amount.
+func (l VelocityLimit) AmountParam() string {
+	if l.Amount == "" {
+		return "amount"
+	}
+	return l.Amount
+}
+
+// Compiled returns the parsed key expression and window, compiling them
+// when the limit was not loaded through Validate.
+func (l VelocityLimit) Compiled() (*expr.Expr, time.Duration, error) {
+	by, window := l.by, l.window
+	if by == nil {
+		src := l.By
+		if strings.TrimSpace(src) == "" {
+			src = `""`
+		}
+		var err error
+		if by, err = expr.Compile(src); err != nil {
+			return nil, 0, err
+		}
+	}
+	if window == 0 {
+		var err error
+		if window, err = time.ParseDuration(l.Window); err != nil {
+			return nil, 0, fmt.Errorf("window inválida %q", l.Window)
+		}
+		if window <= 0 {
+			return nil, 0, fmt.Errorf("window inválida %q", l.Window)
+		}
+	}
+	return by, window, nil
+}
+
+// validateLimits checks the velocity limits of an intent and compiles them.
+func validateLimits(intent *Intent) error {
+	seen := map[string]bool{}
+	for i := range intent.Limits {
+		l := &intent.Limits[i]
+		if strings.TrimSpace(l.Name) == "" {
+			return fmt.Errorf("limit %d sin name", i)
+		}
+		if seen[l.Name] {
+			return fmt.Errorf("limit %s duplicado", l.Name)
+		}
+		seen[l.Name] = true
+		if l.MaxCount <= 0 && l.MaxAmount <= 0 {
+			return fmt.Errorf("limit %s: falta max_count o max_amount", l.Name)
+		}
+		by, window, err := l.Compiled()
+		if err != nil {
+			return fmt.Errorf("limit %s inválido: %w", l.Name, err)
+		}
+		l.by, l.window = by, window
+	}
+	return nil
+}
@@ -0,0 +1,575 @@
+// Package expr implements the small boolean expression language used in the
+// YAML definitions (pipeline `when:` conditions, intent policies).
+//
+// Supported syntax:
+//
+//	literals     "text" 'text' 42 3.5 true false null [a, b, c]
+//	variables    params.amount  steps["banking.aml_risk_check"].riskLevel
+//	comparison   == != < <= > >= in
+//	logic        && || ! ( )
+//
+// Values are compared numerically when both sides look like numbers (params
+// are strings, tool outputs are JSON numbers), otherwise as strings.
+// Unknown variables and fields evaluate to null instead of failing, so a
+// condition over a skipped step is simply false.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr is a compiled expression, safe for concurrent evaluation.
+type Expr struct {
+	src  string
+	root node
+}
+
+// Compile parses an expression.
+func Compile(src string) (*Expr, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, fmt.Errorf("expr %q: %w", src, err)
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("expr %q: %w", src, err)
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("expr %q: unexpected %q", src, p.peek().text)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string { return e.src }
+
+// Eval evaluates the expression against env.
+func (e *Expr) Eval(env map[string]any) (any, error) {
+	return e.root.eval(env)
+}
+
+// EvalBool evaluates the expression and converts the result to a boolean.
+func (e *Expr) EvalBool(env map[string]any) (bool, error) {
+	v, err := e.root.eval(env)
+	if err != nil {
+		return false, fmt.Errorf("expr %q: %w", e.src, err)
+	}
+	return Truthy(v), nil
+}
+
+// EvalBool compiles and evaluates src in one call.
+func EvalBool(src string, env map[string]any) (bool, error) {
+	e, err := Compile(src)
+	if err != nil {
+		return false, err
+	}
+	return e.EvalBool(env)
+}
+
+// Truthy converts a value to a boolean: null, false, 0, "" and "false" are false.
+func Truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != "" && !strings.EqualFold(x, "false")
+	case float64:
+		return x != 0
+	case int:
+		return x != 0
+	case []any:
+		return len(x) > 0
+	}
+	return true
+}
+
+// -------------------------------------------------------------
+// LEXER
+// -------------------------------------------------------------
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			var b strings.Builder
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				b.WriteByte(src[j])
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			toks = append(toks, token{tokString, b.String()})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tokNumber, src[i:j]})
+			i = j
+		case isIdentStart(c):
+			j := i
+			for j < len(src) && (isIdentStart(src[j]) || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, token{tokIdent, src[i:j]})
+			i = j
+		default:
+			two := ""
+			if i+1 < len(src) {
+				two = src[i : i+2]
+			}
+			switch two {
+			case "==", "!=", "<=", ">=", "&&", "||":
+				toks = append(toks, token{tokOp, two})
+				i += 2
+				continue
+			}
+			if strings.ContainsRune("<>!()[].,-", rune(c)) {
+				toks = append(toks, token{tokOp, string(c)})
+				i++
+				continue
+			}
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// -------------------------------------------------------------
+// PARSER
+// -------------------------------------------------------------
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(op string) bool {
+	t := p.peek()
+	if (t.kind == tokOp || t.kind == tokIdent) && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		return fmt.Errorf("expected %q, got %q", op, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner: inner}, nil
+	}
+	return p.parseCmp()
+}
+
+func (p *parser) parseCmp() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	switch {
+	case t.kind == tokOp && (t.text == "==" || t.text == "!=" || t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="),
+		t.kind == tokIdent && t.text == "in":
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		return cmpNode{op: t.text, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	var n node
+	switch {
+	case t.kind == tokString:
+		n = litNode{v: t.text}
+	case t.kind == tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		n = litNode{v: f}
+	case t.kind == tokOp && t.text == "-":
+		num := p.next()
+		if num.kind != tokNumber {
+			return nil, fmt.Errorf("expected number after '-'")
+		}
+		f, err := strconv.ParseFloat(num.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", num.text)
+		}
+		n = litNode{v: -f}
+	case t.kind == tokOp && t.text == "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		n = inner
+	case t.kind == tokOp && t.text == "[":
+		var items []node
+		for !p.accept("]") {
+			if len(items) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			item, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		n = listNode{items: items}
+	case t.kind == tokIdent:
+		switch t.text {
+		case "true":
+			n = litNode{v: true}
+		case "false":
+			n = litNode{v: false}
+		case "null", "nil":
+			n = litNode{v: nil}
+		default:
+			n = varNode{name: t.text}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+
+	// postfix: .field and ["key"]
+	for {
+		switch {
+		case p.accept("."):
+			f := p.next()
+			if f.kind != tokIdent {
+				return nil, fmt.Errorf("expected field name after '.'")
+			}
+			n = indexNode{target: n, key: litNode{v: f.text}}
+		case p.accept("["):
+			key, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = indexNode{target: n, key: key}
+		default:
+			return n, nil
+		}
+	}
+}
+
+// -------------------------------------------------------------
+// EVALUATION
+// -------------------------------------------------------------
+
+type node interface {
+	eval(env map[string]any) (any, error)
+}
+
+type litNode struct{ v any }
+
+func (n litNode) eval(map[string]any) (any, error) { return n.v, nil }
+
+type varNode struct{ name string }
+
+func (n varNode) eval(env map[string]any) (any, error) { return env[n.name], nil }
+
+type listNode struct{ items []node }
+
+func (n listNode) eval(env map[string]any) (any, error) {
+	out := make([]any, 0, len(n.items))
+	for _, it := range n.items {
+		v, err := it.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+type indexNode struct {
+	target node
+	key    node
+}
+
+func (n indexNode) eval(env map[string]any) (any, error) {
+	t, err := n.target.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	k, err := n.key.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	key := toString(k)
+	switch m := t.(type) {
+	case map[string]any:
+		return m[key], nil
+	case map[string]string:
+		if v, ok := m[key]; ok {
+			return v, nil
+		}
+		return nil, nil
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(m) {
+			return nil, nil
+		}
+		return m[i], nil
+	case []string:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(m) {
+			return nil, nil
+		}
+		return m[i], nil
+	}
+	return nil, nil
+}
+
+type notNode struct{ inner node }
+
+func (n notNode) eval(env map[string]any) (any, error) {
+	v, err := n.inner.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return !Truthy(v), nil
+}
+
+type logicNode struct {
+	op          string
+	left, right node
+}
+
+func (n logicNode) eval(env map[string]any) (any, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" && !Truthy(l) {
+		return false, nil
+	}
+	if n.op == "||" && Truthy(l) {
+		return true, nil
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return Truthy(r), nil
+}
+
+type cmpNode struct {
+	op          string
+	left, right node
+}
+
+func (n cmpNode) eval(env map[string]any) (any, error) {
+	l, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		for _, item := range toList(r) {
+			if equal(l, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if l == nil || r == nil {
+		return false, nil
+	}
+	var c int
+	lf, lok := toNumber(l)
+	rf, rok := toNumber(r)
+	if lok && rok {
+		switch {
+		case lf < rf:
+			c = -1
+		case lf > rf:
+			c = 1
+		}
+	} else {
+		c = strings.Compare(toString(l), toString(r))
+	}
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", n.op)
+}
+
+func equal(l, r any) bool {
+	if l == nil || r == nil {
+		return l == nil && r == nil
+	}
+	if lb, ok := l.(bool); ok {
+		return lb == Truthy(r)
+	}
+	if rb, ok := r.(bool); ok {
+		return rb == Truthy(l)
+	}
+	lf, lok := toNumber(l)
+	rf, rok := toNumber(r)
+	if lok && rok {
+		return lf == rf
+	}
+	return toString(l) == toString(r)
+}
+
+func toNumber(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func toList(v any) []any {
+	switch x := v.(type) {
+	case []any:
+		return x
+	case []string:
+		out := make([]any, len(x))
+		for i, s := range x {
+			out[i] = s
+		}
+		return out
+	case string:
+		// comma separated values, e.g. an attribute loaded from YAML/env
+		var out []any
+		for _, s := range strings.Split(x, ",") {
+			out = append(out, strings.TrimSpace(s))
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package expr
+
+import "testing"
+
+func TestEvalBool(t *testing.T) {
+	env := map[string]any{
+		"params": map[string]string{"amount": "25", "toPhone": "600111222"},
+		"steps": map[string]any{
+			"banking.aml_risk_check": map[string]any{"riskLevel": "LOW", "riskScore": 12.0, "sanctioned": false},
+		},
+		"caller": map[string]any{"role": "teller", "accountIds": []any{"ES01", "ES02"}},
+	}
+
+	cases := []struct {
+		src  string
+		want bool
+	}{
+		{`steps["banking.aml_risk_check"].riskLevel == "LOW"`, true},
+		{`steps["banking.aml_risk_check"].riskLevel != 'LOW'`, false},
+		{`steps["banking.aml_risk_check"].riskScore < 50`, true},
+		{`steps["banking.aml_risk_check"].sanctioned == false`, true},
+		{`!steps["banking.aml_risk_check"].sanctioned`, true},
+		{`params.amount <= 50`, true},
+		{`params.amount > 100 || caller.role == "teller"`, true},
+		{`params.amount > 100 && caller.role == "teller"`, false},
+		{`(params.amount > 10) && (params.amount < 30)`, true},
+		{`caller.role in ["teller", "admin"]`, true},
+		{`"ES03" in caller.accountIds`, false},
+		{`params.missing == null`, true},
+		{`steps["banking.payments_bizum_send"].status == "ok"`, false},
+		{`params.amount >= -1`, true},
+		{`true`, true},
+	}
+	for _, c := range cases {
+		got, err := EvalBool(c.src, env)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.src, err)
+		}
+		if got != c.want {
+			t.Fatalf("%s: got %v, want %v", c.src, got, c.want)
+		}
+	}
+}
+
+func TestCompile_SyntaxErrors(t *testing.T) {
+	for _, src := range []string{
+		`params.amount ==`,
+		`"unterminated`,
+		`(a == b`,
+		`a == b c`,
+		`a # b`,
+		`x.`,
+	} {
+		if _, err := Compile(src); err == nil {
+			t.Fatalf("expected syntax error for %q", src)
+		}
+	}
+}
+
+func TestTruthy(t *testing.T) {
+	if Truthy(nil) || Truthy("") || Truthy("false") || Truthy(0.0) || Truthy(false) {
+		t.Fatalf("falsy values reported as true")
+	}
+	if !Truthy("x") || !Truthy(1.0) || !Truthy(true) || !Truthy(map[string]any{}) {
+		t.Fatalf("truthy values reported as false")
+	}
+}
@@ -2,19 +2,11 @@ package guard
 
 import (
 	"fmt"
-	"regexp"
-	"strconv"
+	"strings"
 
 	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
 )
 
-// ---- helpers internos ----
-
-func isValidPhoneNumber(s string) bool {
-	re := regexp.MustCompile(`^[0-9+][0-9]{5,14}$`)
-	return re.MatchString(s)
-}
-
 // Intent puede usar herramientas peligrosas?
 func ValidateIntentPermissions(intent config.Intent, pipeline config.Pipeline, tools map[string]config.Tool) error {
 	for _, step := range pipeline.Steps {
@@ -36,36 +28,33 @@ func ValidateIntentPermissions(intent config.Intent, pipeline config.Pipeline, t
 	return nil
 }
 
-// Validar params sensibles (amount, phone…)
+// Validar params sensibles (amount, phone…) con los guard-rails del intent,
+// aunque no haya pasado por config.Validate
 func ValidateDangerousParams(intent config.Intent, params map[string]string) error {
 	if !intent.AllowDangerous {
 		return nil
 	}
-
+	typed, err := intent.WithGuardRails()
+	if err != nil {
+		return err
+	}
+	var names []string
 	if intent.RequiresAmount {
-		raw := params["amount"]
-		if raw == "" {
-			return fmt.Errorf("falta parámetro requerido: amount")
-		}
-		amount, err := strconv.ParseFloat(raw, 64)
-		if err != nil || amount <= 0 {
-			return fmt.Errorf("amount inválido: %s", raw)
-		}
-		if intent.MaxAmount > 0 && amount > intent.MaxAmount {
-			return fmt.Errorf("amount excede límite permitido: %v > %v", amount, intent.MaxAmount)
-		}
+		names = append(names, "amount")
 	}
-
 	if intent.RequiresPhone {
-		phone := params["toPhone"]
-		if phone == "" {
-			return fmt.Errorf("falta parámetro requerido: toPhone")
+		names = append(names, "toPhone")
+	}
+	for _, name := range names {
+		spec, _ := typed.ParamSpec(name)
+		raw := strings.TrimSpace(params[name])
+		if raw == "" {
+			return fmt.Errorf("falta parámetro requerido: %s", name)
 		}
-		if !isValidPhoneNumber(phone) {
-			return fmt.Errorf("toPhone no válido: %s", phone)
+		if _, err := spec.Coerce(raw); err != nil {
+			return fmt.Errorf("parámetro inválido: %w", err)
 		}
 	}
-
 	return nil
 }
 
@@ -95,17 +84,65 @@ func ValidateDangerousChain(pipeline config.Pipeline, tools map[string]config.To
 	return nil
 }
 
+// CoerceParams aplica los defaults de los params tipados del intent y
+// devuelve los params en forma canónica (ver config.ParamSpec.Coerce). Los
+// params ausentes o vacíos se dejan tal cual para que el Planner los pida.
+func CoerceParams(intent config.Intent, params map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(params))
+	for k, v := range params {
+		out[k] = v
+	}
+	for _, spec := range intent.Params {
+		raw := strings.TrimSpace(out[spec.Name])
+		if raw == "" {
+			raw = spec.Default
+		}
+		if raw == "" {
+			continue
+		}
+		v, err := spec.Coerce(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parámetro inválido: %w", err)
+		}
+		out[spec.Name] = v
+	}
+	return out, nil
+}
+
+// Validar params tipados: presentes si son requeridos y con valor válido
+func ValidateParams(intent config.Intent, params map[string]string) error {
+	coerced, err := CoerceParams(intent, params)
+	if err != nil {
+		return err
+	}
+	for _, name := range intent.Required() {
+		if strings.TrimSpace(coerced[name]) == "" {
+			return fmt.Errorf("falta parámetro requerido: %s", name)
+		}
+	}
+	return nil
+}
+
 // ---- API pública: un solo punto de entrada ----
 
-func ValidateAll(intent config.Intent, pipeline config.Pipeline, params map[string]string, tools map[string]config.Tool) error {
+func ValidateAll(intent config.Intent, pipeline config.Pipeline, params map[string]string, tools map[string]config.Tool, caller Caller) error {
 	if err := ValidateIntentPermissions(intent, pipeline, tools); err != nil {
 		return err
 	}
 	if err := ValidateDangerousParams(intent, params); err != nil {
 		return err
 	}
+	if err := ValidateParams(intent, params); err != nil {
+		return err
+	}
+	if err := ValidateIdentity(intent, params, caller); err != nil {
+		return err
+	}
 	if err := ValidateDangerousChain(pipeline, tools); err != nil {
 		return err
 	}
+	if err := ValidatePolicies(intent, params, caller); err != nil {
+		return err
+	}
 	return nil
 }
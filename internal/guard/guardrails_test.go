@@ -60,6 +60,25 @@ func TestValidateDangerousParams(t *testing.T) {
         }
     })
 
+    t.Run("max_amount tightens a declared param", func(t *testing.T) {
+        max := 500.0
+        intent := config.Intent{AllowDangerous: true, RequiresAmount: true, MaxAmount: 10,
+            Params: []config.ParamSpec{{Name: "amount", Type: config.ParamDecimal, Max: &max, Optional: true}}}
+        if err := ValidateDangerousParams(intent, map[string]string{"amount": "11"}); err == nil {
+            t.Fatalf("expected error for amount over max_amount")
+        }
+        if *intent.Params[0].Max != 500 {
+            t.Fatalf("the intent must not be modified")
+        }
+    })
+
+    t.Run("non-finite amount", func(t *testing.T) {
+        intent := config.Intent{AllowDangerous: true, RequiresAmount: true, MaxAmount: 100}
+        if err := ValidateDangerousParams(intent, map[string]string{"amount": "NaN"}); err == nil {
+            t.Fatalf("expected error for NaN amount")
+        }
+    })
+
     t.Run("missing phone", func(t *testing.T) {
         intent := config.Intent{AllowDangerous: true, RequiresPhone: true}
         if err := ValidateDangerousParams(intent, map[string]string{}); err == nil {
@@ -117,13 +136,53 @@ func TestValidateAll(t *testing.T) {
     intent := config.Intent{Type: "bizum", AllowDangerous: true, RequiresAmount: true, RequiresPhone: true, MaxAmount: 100}
     params := map[string]string{"amount": "50", "toPhone": "+34123456789"}
 
-    if err := ValidateAll(intent, pipeline, params, tools); err != nil {
+    if err := ValidateAll(intent, pipeline, params, tools, Caller{}); err != nil {
         t.Fatalf("unexpected: %v", err)
     }
 
     // Failing case: over max triggers param validation error
     params["amount"] = "1000"
-    if err := ValidateAll(intent, pipeline, params, tools); err == nil {
+    if err := ValidateAll(intent, pipeline, params, tools, Caller{}); err == nil {
+        t.Fatalf("expected error when amount exceeds max")
+    }
+}
+
+func TestCoerceParams(t *testing.T) {
+    max := 100.0
+    intent := config.Intent{Params: []config.ParamSpec{
+        {Name: "amount", Type: config.ParamDecimal, Max: &max},
+        {Name: "currency", Type: config.ParamEnum, Values: []string{"EUR", "USD"}, Default: "EUR"},
+        {Name: "concept", Optional: true},
+    }}
+
+    out, err := CoerceParams(intent, map[string]string{"amount": "25,50", "extra": "x"})
+    if err != nil {
+        t.Fatalf("unexpected: %v", err)
+    }
+    if out["amount"] != "25.5" || out["currency"] != "EUR" || out["extra"] != "x" {
+        t.Fatalf("unexpected params: %#v", out)
+    }
+    if _, ok := out["concept"]; ok {
+        t.Fatalf("optional params without value must stay absent")
+    }
+
+    if _, err := CoerceParams(intent, map[string]string{"amount": "150"}); err == nil {
         t.Fatalf("expected error when amount exceeds max")
     }
 }
+
+func TestValidateParams(t *testing.T) {
+    intent := config.Intent{Params: []config.ParamSpec{
+        {Name: "toPhone", Type: config.ParamPhone},
+        {Name: "concept", Optional: true},
+    }}
+    if err := ValidateParams(intent, map[string]string{}); err == nil {
+        t.Fatalf("expected error for missing toPhone")
+    }
+    if err := ValidateParams(intent, map[string]string{"toPhone": "hola"}); err == nil {
+        t.Fatalf("expected error for invalid phone")
+    }
+    if err := ValidateParams(intent, map[string]string{"toPhone": "600 111 222"}); err != nil {
+        t.Fatalf("unexpected: %v", err)
+    }
+}
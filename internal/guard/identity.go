@@ -0,0 +1,161 @@
+package guard
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+)
+
+// IsZero reports whether there is no caller identity: authentication is
+// disabled or the request used the shared API key. Intents with params
+// bound to the caller or owned by it reject such callers.
+func (c Caller) IsZero() bool {
+	return c.ID == "" && c.Role == "" && len(c.Attrs) == 0
+}
+
+// requireIdentity fails when the intent declares `from:` or `owned_by:`
+// params and there is no caller identity to bind or check them against.
+func requireIdentity(intent config.Intent, caller Caller) error {
+	if !caller.IsZero() {
+		return nil
+	}
+	for _, spec := range intent.Params {
+		if spec.From != "" || spec.OwnedBy != "" {
+			return &IdentityError{Param: spec.Name, Reason: "requiere un llamante identificado"}
+		}
+	}
+	return nil
+}
+
+// IdentityError is returned when a param does not match the identity of
+// the caller: a bound param with another value, or a value the caller does
+// not own.
+type IdentityError struct {
+	Param  string
+	Value  string
+	Reason string
+}
+
+func (e *IdentityError) Error() string {
+	return fmt.Sprintf("parámetro '%s' rechazado: %s", e.Param, e.Reason)
+}
+
+// BindParams fills the params declared with `from:` with the values of the
+// caller's identity and returns a copy of params. A value given in the
+// message or the request that differs from the caller's is an error, not
+// silently replaced, so spoofing attempts show up.
+func BindParams(intent config.Intent, params map[string]string, caller Caller) (map[string]string, error) {
+	out := make(map[string]string, len(params))
+	for k, v := range params {
+		out[k] = v
+	}
+	if err := requireIdentity(intent, caller); err != nil {
+		return nil, err
+	}
+	for _, spec := range intent.Params {
+		if spec.From == "" {
+			continue
+		}
+		bound, err := boundValue(spec, params, caller)
+		if err != nil {
+			return nil, err
+		}
+		if given := strings.TrimSpace(out[spec.Name]); given != "" && !sameValue(spec, given, bound) {
+			return nil, &IdentityError{Param: spec.Name, Value: given, Reason: "no coincide con la identidad del llamante"}
+		}
+		out[spec.Name] = bound
+	}
+	return out, nil
+}
+
+// Validar que los params ligados al llamante y los que debe poseer
+// (owned_by) corresponden a su identidad
+func ValidateIdentity(intent config.Intent, params map[string]string, caller Caller) error {
+	if err := requireIdentity(intent, caller); err != nil {
+		return err
+	}
+	for _, spec := range intent.Params {
+		value := strings.TrimSpace(params[spec.Name])
+		if spec.From != "" {
+			bound, err := boundValue(spec, params, caller)
+			if err != nil {
+				return err
+			}
+			if !sameValue(spec, value, bound) {
+				return &IdentityError{Param: spec.Name, Value: value, Reason: "no coincide con la identidad del llamante"}
+			}
+		}
+		if spec.OwnedBy == "" || value == "" {
+			continue
+		}
+		_, ownedBy, err := spec.Identity()
+		if err != nil {
+			return fmt.Errorf("param '%s' inválido: %w", spec.Name, err)
+		}
+		owned, err := ownedBy.Eval(policyEnv(params, caller, now()))
+		if err != nil {
+			return fmt.Errorf("param '%s': %w", spec.Name, err)
+		}
+		if !owns(spec, owned, value) {
+			return &IdentityError{Param: spec.Name, Value: value, Reason: fmt.Sprintf("%q no pertenece al llamante", value)}
+		}
+	}
+	return nil
+}
+
+// boundValue evaluates the `from:` of a param, in canonical form.
+func boundValue(spec config.ParamSpec, params map[string]string, caller Caller) (string, error) {
+	from, _, err := spec.Identity()
+	if err != nil {
+		return "", fmt.Errorf("param '%s' inválido: %w", spec.Name, err)
+	}
+	v, err := from.Eval(policyEnv(params, caller, now()))
+	if err != nil {
+		return "", fmt.Errorf("param '%s': %w", spec.Name, err)
+	}
+	raw := strings.TrimSpace(exprString(v))
+	if raw == "" {
+		return "", &IdentityError{Param: spec.Name, Reason: fmt.Sprintf("el llamante no tiene %s", spec.From)}
+	}
+	bound, err := spec.Coerce(raw)
+	if err != nil {
+		return "", &IdentityError{Param: spec.Name, Reason: fmt.Sprintf("%s del llamante inválido: %v", spec.From, err)}
+	}
+	return bound, nil
+}
+
+// owns reports whether value is in owned (a list) or equal to it.
+func owns(spec config.ParamSpec, owned any, value string) bool {
+	switch xs := owned.(type) {
+	case []any:
+		for _, x := range xs {
+			if sameValue(spec, value, exprString(x)) {
+				return true
+			}
+		}
+		return false
+	case []string:
+		for _, x := range xs {
+			if sameValue(spec, value, x) {
+				return true
+			}
+		}
+		return false
+	case nil:
+		return false
+	}
+	return sameValue(spec, value, exprString(owned))
+}
+
+// sameValue compares two values of a param after coercing them, so
+// "+34 600 11 22 33" and "+34600112233" are the same phone.
+func sameValue(spec config.ParamSpec, a, b string) bool {
+	if ca, err := spec.Coerce(a); err == nil {
+		a = ca
+	}
+	if cb, err := spec.Coerce(b); err == nil {
+		b = cb
+	}
+	return strings.TrimSpace(a) == strings.TrimSpace(b)
+}
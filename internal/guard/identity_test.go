@@ -0,0 +1,101 @@
+package guard
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+)
+
+func identityIntent() config.Intent {
+	return config.Intent{Type: "bizum", Params: []config.ParamSpec{
+		{Name: "fromPhone", Type: config.ParamPhone, From: "caller.phone"},
+		{Name: "accountId", OwnedBy: "caller.accountIds"},
+		{Name: "tenant", OwnedBy: "caller.tenant", Optional: true},
+	}}
+}
+
+var ana = Caller{ID: "ana", Attrs: map[string]any{
+	"phone":      "+34 600 111 222",
+	"accountIds": []any{"ES01", "ES02"},
+	"tenant":     "acme",
+}}
+
+func TestBindParams(t *testing.T) {
+	intent := identityIntent()
+
+	got, err := BindParams(intent, map[string]string{"accountId": "ES01"}, ana)
+	if err != nil || got["fromPhone"] != "+34600111222" {
+		t.Fatalf("expected fromPhone bound to the caller, got %v err=%v", got, err)
+	}
+
+	// el mismo teléfono escrito de otra forma no es un intento de suplantación
+	if _, err := BindParams(intent, map[string]string{"fromPhone": "+34600111222"}, ana); err != nil {
+		t.Fatalf("same phone must be accepted: %v", err)
+	}
+
+	_, err = BindParams(intent, map[string]string{"fromPhone": "+34699999999"}, ana)
+	var ierr *IdentityError
+	if !errors.As(err, &ierr) || ierr.Param != "fromPhone" {
+		t.Fatalf("expected spoofed fromPhone to be rejected, got %v", err)
+	}
+
+	_, err = BindParams(intent, nil, Caller{ID: "bob"})
+	if !errors.As(err, &ierr) || err.Error() != "parámetro 'fromPhone' rechazado: el llamante no tiene caller.phone" {
+		t.Fatalf("expected missing attribute error, got %v", err)
+	}
+
+	// sin identidad no hay nada que ligar: el intent se rechaza
+	_, err = BindParams(intent, map[string]string{"fromPhone": "600"}, Caller{})
+	if !errors.As(err, &ierr) || err.Error() != "parámetro 'fromPhone' rechazado: requiere un llamante identificado" {
+		t.Fatalf("expected anonymous caller to be rejected, got %v", err)
+	}
+	if got, err := BindParams(config.Intent{Params: []config.ParamSpec{{Name: "amount"}}}, map[string]string{"amount": "5"}, Caller{}); err != nil || got["amount"] != "5" {
+		t.Fatalf("intents without identity params accept anonymous callers, got %v err=%v", got, err)
+	}
+}
+
+func TestValidateIdentity_Ownership(t *testing.T) {
+	intent := identityIntent()
+	params := func(account, tenant string) map[string]string {
+		return map[string]string{"fromPhone": "+34600111222", "accountId": account, "tenant": tenant}
+	}
+
+	if err := ValidateIdentity(intent, params("ES02", "acme"), ana); err != nil {
+		t.Fatalf("unexpected: %v", err)
+	}
+	if err := ValidateIdentity(intent, params("ES01", ""), ana); err != nil {
+		t.Fatalf("empty optional param must not be checked: %v", err)
+	}
+
+	var ierr *IdentityError
+	err := ValidateIdentity(intent, params("ES99", "acme"), ana)
+	if !errors.As(err, &ierr) || ierr.Param != "accountId" {
+		t.Fatalf("expected foreign account to be rejected, got %v", err)
+	}
+	if err.Error() != `parámetro 'accountId' rechazado: "ES99" no pertenece al llamante` {
+		t.Fatalf("unexpected message: %v", err)
+	}
+	if err := ValidateIdentity(intent, params("ES01", "other"), ana); !errors.As(err, &ierr) || ierr.Param != "tenant" {
+		t.Fatalf("expected scalar ownership mismatch, got %v", err)
+	}
+	if err := ValidateIdentity(intent, map[string]string{"fromPhone": "+34611111111", "accountId": "ES01"}, ana); !errors.As(err, &ierr) || ierr.Param != "fromPhone" {
+		t.Fatalf("expected bound param mismatch, got %v", err)
+	}
+	if err := ValidateIdentity(intent, params("ES99", "other"), Caller{}); !errors.As(err, &ierr) {
+		t.Fatalf("expected anonymous caller to be rejected, got %v", err)
+	}
+	ownedOnly := config.Intent{Params: []config.ParamSpec{{Name: "accountId", OwnedBy: "caller.accountIds"}}}
+	if err := ValidateIdentity(ownedOnly, map[string]string{"accountId": "ES01"}, Caller{}); !errors.As(err, &ierr) || ierr.Param != "accountId" {
+		t.Fatalf("expected owned_by without identity to be rejected, got %v", err)
+	}
+}
+
+func TestValidateAll_ChecksOwnership(t *testing.T) {
+	intent := identityIntent()
+	err := ValidateAll(intent, config.Pipeline{}, map[string]string{"fromPhone": "+34600111222", "accountId": "ES99"}, nil, ana)
+	var ierr *IdentityError
+	if !errors.As(err, &ierr) {
+		t.Fatalf("expected IdentityError, got %v", err)
+	}
+}
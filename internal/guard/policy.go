@@ -0,0 +1,97 @@
+package guard
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+)
+
+// Caller es quien pide la tarea, tal y como lo ven las policies (caller.*).
+type Caller struct {
+	ID    string         `json:"id,omitempty"`
+	Role  string         `json:"role,omitempty"`
+	Attrs map[string]any `json:"attrs,omitempty"`
+}
+
+// exprValue returns the caller as seen by expressions: id, role and every
+// attribute at the top level (caller.accountIds).
+func (c Caller) exprValue() map[string]any {
+	out := make(map[string]any, len(c.Attrs)+2)
+	for k, v := range c.Attrs {
+		out[k] = v
+	}
+	out["id"] = c.ID
+	out["role"] = c.Role
+	return out
+}
+
+// PolicyError is returned when an intent policy is violated.
+type PolicyError struct {
+	Policy  string
+	Rule    string
+	Message string
+}
+
+func (e *PolicyError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("política '%s' violada: %s", e.Policy, e.Message)
+	}
+	return fmt.Sprintf("política '%s' violada: %s", e.Policy, e.Rule)
+}
+
+// now is replaced in tests.
+var now = time.Now
+
+// reservedVars can not be shadowed by a param of the same name.
+var reservedVars = map[string]bool{"params": true, "caller": true, "now": true}
+
+// Validar las policies del intent: la primera regla falsa aborta la tarea
+func ValidatePolicies(intent config.Intent, params map[string]string, caller Caller) error {
+	if len(intent.Policies) == 0 {
+		return nil
+	}
+	t := now()
+	for _, p := range intent.Policies {
+		rule, loc, err := p.Compiled()
+		if err != nil {
+			return fmt.Errorf("política '%s' inválida: %w", p.Name, err)
+		}
+		ok, err := rule.EvalBool(policyEnv(params, caller, t.In(loc)))
+		if err != nil {
+			return fmt.Errorf("política '%s': %w", p.Name, err)
+		}
+		if !ok {
+			return &PolicyError{Policy: p.Name, Rule: p.Rule, Message: p.Message}
+		}
+	}
+	return nil
+}
+
+// policyEnv builds the variables of a policy rule: params (also by name),
+// caller and now.
+func policyEnv(params map[string]string, caller Caller, t time.Time) map[string]any {
+	ps := make(map[string]any, len(params))
+	env := make(map[string]any, len(params)+3)
+	for k, v := range params {
+		ps[k] = v
+		if !reservedVars[k] {
+			env[k] = v
+		}
+	}
+	weekday := int(t.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	env["params"] = ps
+	env["caller"] = caller.exprValue()
+	env["now"] = map[string]any{
+		"weekday":    t.Format("Mon"),  // Mon … Sun
+		"weekdayNum": float64(weekday), // 1 = lunes … 7 = domingo
+		"hour":       float64(t.Hour()),
+		"minute":     float64(t.Minute()),
+		"time":       t.Format("15:04"),
+		"date":       t.Format("2006-01-02"),
+	}
+	return env
+}
@@ -0,0 +1,76 @@
+package guard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+)
+
+func TestValidatePolicies(t *testing.T) {
+	intent := config.Intent{Type: "bizum", Policies: []config.Policy{
+		{Name: "customer_limit", Rule: `amount <= 50 || caller.role == "teller"`, Message: "máximo 50 €"},
+		{Name: "own_account", Rule: `params.accountId in caller.accountIds`},
+	}}
+	teller := Caller{ID: "t1", Role: "teller", Attrs: map[string]any{"accountIds": []any{"ES01"}}}
+	customer := Caller{ID: "c1", Role: "customer", Attrs: map[string]any{"accountIds": []any{"ES01"}}}
+
+	if err := ValidatePolicies(intent, map[string]string{"amount": "80", "accountId": "ES01"}, teller); err != nil {
+		t.Fatalf("unexpected: %v", err)
+	}
+
+	err := ValidatePolicies(intent, map[string]string{"amount": "80", "accountId": "ES01"}, customer)
+	var perr *PolicyError
+	if !errors.As(err, &perr) || perr.Policy != "customer_limit" {
+		t.Fatalf("expected customer_limit violation, got %v", err)
+	}
+	if err.Error() != "política 'customer_limit' violada: máximo 50 €" {
+		t.Fatalf("unexpected message: %v", err)
+	}
+
+	err = ValidatePolicies(intent, map[string]string{"amount": "20", "accountId": "ES02"}, customer)
+	if !errors.As(err, &perr) || perr.Policy != "own_account" {
+		t.Fatalf("expected own_account violation, got %v", err)
+	}
+}
+
+func TestValidatePolicies_TimeWindow(t *testing.T) {
+	defer func(orig func() time.Time) { now = orig }(now)
+	intent := config.Intent{Type: "deploy", Policies: []config.Policy{{
+		Name:     "deploy_window",
+		Rule:     `now.weekdayNum <= 4 && now.hour >= 9 && now.hour < 17`,
+		Timezone: "Europe/Madrid",
+	}}}
+
+	cases := map[string]bool{
+		"2026-10-15T10:00:00+02:00": true,  // jueves
+		"2026-10-15T07:30:00Z":      true,  // jueves 09:30 en Madrid
+		"2026-10-15T17:00:00+02:00": false, // fuera de horario
+		"2026-10-16T10:00:00+02:00": false, // viernes
+		"2026-10-18T10:00:00+02:00": false, // domingo
+	}
+	for ts, ok := range cases {
+		at, _ := time.Parse(time.RFC3339, ts)
+		now = func() time.Time { return at }
+		if err := ValidatePolicies(intent, nil, Caller{}); (err == nil) != ok {
+			t.Fatalf("%s: ok=%v, got %v", ts, ok, err)
+		}
+	}
+}
+
+func TestValidateAll_ReturnsViolatedPolicy(t *testing.T) {
+	tools := map[string]config.Tool{"send": {Name: "send", Mode: "dangerous"}}
+	pipeline := config.Pipeline{Name: "bizum", Steps: []config.PipelineStep{{Tool: "send"}}}
+	intent := config.Intent{Type: "bizum", AllowDangerous: true, Policies: []config.Policy{
+		{Name: "teller_only", Rule: `caller.role == "teller"`},
+	}}
+
+	err := ValidateAll(intent, pipeline, map[string]string{}, tools, Caller{Role: "customer"})
+	if err == nil || err.Error() != `política 'teller_only' violada: caller.role == "teller"` {
+		t.Fatalf("unexpected: %v", err)
+	}
+	if err := ValidateAll(intent, pipeline, map[string]string{}, tools, Caller{Role: "teller"}); err != nil {
+		t.Fatalf("unexpected: %v", err)
+	}
+}
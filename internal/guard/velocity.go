@@ -0,0 +1,283 @@
+package guard
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+)
+
+// Usage is what a key consumed within a window.
+type Usage struct {
+	Count  int     `json:"count"`
+	Amount float64 `json:"amount"`
+}
+
+// CounterStore keeps the events counted by velocity limits. Implementations
+// must be safe for concurrent use; a store shared by several AOS instances
+// (e.g. Redis) makes the limits global instead of per process.
+type CounterStore interface {
+	// Usage sums the events recorded under key since the given time.
+	Usage(key string, since time.Time) (Usage, error)
+	// Add records an event and returns its id. Events older than keep may
+	// be discarded.
+	Add(key string, at time.Time, amount float64, keep time.Duration) (string, error)
+	// Remove deletes an event, returning its quota.
+	Remove(key, eventID string) error
+}
+
+// VelocityError is returned when an operation would exceed a limit.
+type VelocityError struct {
+	Limit     string        `json:"limit"`
+	Key       string        `json:"key,omitempty"`
+	Window    time.Duration `json:"-"`
+	Used      Usage         `json:"used"`
+	MaxCount  int           `json:"maxCount,omitempty"`
+	MaxAmount float64       `json:"maxAmount,omitempty"`
+	Amount    float64       `json:"amount,omitempty"` // of the rejected operation
+}
+
+// RemainingCount returns the operations left in the window.
+func (e *VelocityError) RemainingCount() int {
+	return max(e.MaxCount-e.Used.Count, 0)
+}
+
+// RemainingAmount returns the amount left in the window.
+func (e *VelocityError) RemainingAmount() float64 {
+	return max(e.MaxAmount-e.Used.Amount, 0)
+}
+
+func (e *VelocityError) Error() string {
+	if e.MaxAmount > 0 && e.Used.Amount+e.Amount > e.MaxAmount {
+		return fmt.Sprintf("límite '%s' superado: %s + %s > %s en %s (quedan %s)",
+			e.Limit, formatAmount(e.Used.Amount), formatAmount(e.Amount), formatAmount(e.MaxAmount), e.Window, formatAmount(e.RemainingAmount()))
+	}
+	return fmt.Sprintf("límite '%s' superado: %d de %d operaciones en %s (quedan %d)",
+		e.Limit, e.Used.Count, e.MaxCount, e.Window, e.RemainingCount())
+}
+
+// Velocity enforces the velocity limits of intents over a CounterStore.
+type Velocity struct {
+	mu    sync.Mutex // check + add de un proceso son atómicos
+	store CounterStore
+}
+
+func NewVelocity(store CounterStore) *Velocity {
+	return &Velocity{store: store}
+}
+
+// Reservation is the quota taken by an operation, so it can be returned
+// when the operation did not happen.
+type Reservation struct {
+	v      *Velocity
+	events []counterEvent
+}
+
+type counterEvent struct{ key, id string }
+
+// Check reports whether the operation fits every limit of the intent,
+// without consuming quota.
+func (v *Velocity) Check(intent config.Intent, params map[string]string, caller Caller) error {
+	if v == nil || len(intent.Limits) == 0 {
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	_, err := v.check(intent, params, caller, now())
+	return err
+}
+
+// Reserve checks every limit of the intent and, when all fit, records the
+// operation in each of them.
+func (v *Velocity) Reserve(intent config.Intent, params map[string]string, caller Caller) (*Reservation, error) {
+	if v == nil || len(intent.Limits) == 0 {
+		return &Reservation{}, nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	at := now()
+	ops, err := v.check(intent, params, caller, at)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reservation{v: v}
+	for _, op := range ops {
+		id, err := v.store.Add(op.key, at, op.amount, op.window)
+		if err != nil {
+			r.release()
+			return nil, fmt.Errorf("límite '%s': %w", op.name, err)
+		}
+		r.events = append(r.events, counterEvent{key: op.key, id: id})
+	}
+	return r, nil
+}
+
+// Release returns the quota of the reservation.
+func (r *Reservation) Release() {
+	if r == nil || r.v == nil {
+		return
+	}
+	r.v.mu.Lock()
+	defer r.v.mu.Unlock()
+	r.release()
+}
+
+func (r *Reservation) release() {
+	for _, e := range r.events {
+		_ = r.v.store.Remove(e.key, e.id)
+	}
+	r.events = nil
+}
+
+// limitOp is one limit applied to one operation.
+type limitOp struct {
+	name   string
+	key    string
+	window time.Duration
+	amount float64
+}
+
+func (v *Velocity) check(intent config.Intent, params map[string]string, caller Caller, at time.Time) ([]limitOp, error) {
+	env := policyEnv(params, caller, at)
+	ops := make([]limitOp, 0, len(intent.Limits))
+	for _, l := range intent.Limits {
+		by, window, err := l.Compiled()
+		if err != nil {
+			return nil, fmt.Errorf("límite '%s' inválido: %w", l.Name, err)
+		}
+		keyVal, err := by.Eval(env)
+		if err != nil {
+			return nil, fmt.Errorf("límite '%s': %w", l.Name, err)
+		}
+		op := limitOp{name: l.Name, key: l.Name + ":" + exprString(keyVal), window: window}
+		if l.MaxAmount > 0 {
+			raw := strings.TrimSpace(params[l.AmountParam()])
+			amount, err := strconv.ParseFloat(raw, 64)
+			// NaN passes every comparison and would poison the window's sum.
+			if err != nil || amount < 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+				return nil, fmt.Errorf("límite '%s': %s inválido: %q", l.Name, l.AmountParam(), raw)
+			}
+			op.amount = amount
+		}
+
+		used, err := v.store.Usage(op.key, at.Add(-window))
+		if err != nil {
+			return nil, fmt.Errorf("límite '%s': %w", l.Name, err)
+		}
+		if (l.MaxCount > 0 && used.Count+1 > l.MaxCount) || (l.MaxAmount > 0 && used.Amount+op.amount > l.MaxAmount) {
+			return nil, &VelocityError{
+				Limit:     l.Name,
+				Key:       exprString(keyVal),
+				Window:    window,
+				Used:      used,
+				MaxCount:  l.MaxCount,
+				MaxAmount: l.MaxAmount,
+				Amount:    op.amount,
+			}
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func exprString(v any) string {
+	if v == nil {
+		return ""
+	}
+	if f, ok := v.(float64); ok {
+		return formatAmount(f)
+	}
+	return fmt.Sprint(v)
+}
+
+func formatAmount(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// ---- CounterStore en memoria ----
+
+// MemoryCounterStore keeps the counters in process memory: they are lost on
+// restart and not shared between instances.
+type MemoryCounterStore struct {
+	mu     sync.Mutex
+	seq    uint64
+	events map[string][]memoryEvent
+	keep   map[string]time.Duration
+}
+
+// sweepEvery is how many Adds trigger a sweep of keys with no live events.
+const sweepEvery = 1024
+
+type memoryEvent struct {
+	id     string
+	at     time.Time
+	amount float64
+}
+
+func NewMemoryCounterStore() *MemoryCounterStore {
+	return &MemoryCounterStore{events: make(map[string][]memoryEvent), keep: make(map[string]time.Duration)}
+}
+
+func (s *MemoryCounterStore) Usage(key string, since time.Time) (Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var u Usage
+	for _, e := range s.events[key] {
+		if e.at.After(since) {
+			u.Count++
+			u.Amount += e.amount
+		}
+	}
+	return u, nil
+}
+
+func (s *MemoryCounterStore) Add(key string, at time.Time, amount float64, keep time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cutoff := at.Add(-keep)
+	kept := s.events[key][:0]
+	for _, e := range s.events[key] {
+		if e.at.After(cutoff) {
+			kept = append(kept, e)
+		}
+	}
+	s.seq++
+	id := strconv.FormatUint(s.seq, 10)
+	s.events[key] = append(kept, memoryEvent{id: id, at: at, amount: amount})
+	s.keep[key] = keep
+	if s.seq%sweepEvery == 0 {
+		s.sweep(at)
+	}
+	return id, nil
+}
+
+// sweep drops the keys whose newest event is already out of its window.
+func (s *MemoryCounterStore) sweep(now time.Time) {
+	for key, events := range s.events {
+		if len(events) == 0 || !events[len(events)-1].at.After(now.Add(-s.keep[key])) {
+			delete(s.events, key)
+			delete(s.keep, key)
+		}
+	}
+}
+
+func (s *MemoryCounterStore) Remove(key, eventID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := s.events[key]
+	for i, e := range events {
+		if e.id == eventID {
+			s.events[key] = append(events[:i], events[i+1:]...)
+			break
+		}
+	}
+	if len(s.events[key]) == 0 {
+		delete(s.events, key)
+		delete(s.keep, key)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package guard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/config"
+)
+
+func bizumLimits() config.Intent {
+	return config.Intent{Type: "bizum", Limits: []config.VelocityLimit{
+		{Name: "daily_amount", By: "caller.id", Window: "24h", MaxAmount: 300},
+		{Name: "hourly_count", By: "caller.id", Window: "1h", MaxCount: 2},
+	}}
+}
+
+func TestVelocity_CountAndAmountLimits(t *testing.T) {
+	defer func(orig func() time.Time) { now = orig }(now)
+	at := time.Date(2026, 10, 15, 10, 0, 0, 0, time.UTC)
+	now = func() time.Time { return at }
+
+	v := NewVelocity(NewMemoryCounterStore())
+	intent := bizumLimits()
+	ana := Caller{ID: "ana"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := v.Reserve(intent, map[string]string{"amount": "100"}, ana); err != nil {
+			t.Fatalf("reserve %d: %v", i, err)
+		}
+	}
+	err := v.Check(intent, map[string]string{"amount": "10"}, ana)
+	var verr *VelocityError
+	if !errors.As(err, &verr) || verr.Limit != "hourly_count" || verr.RemainingCount() != 0 {
+		t.Fatalf("expected hourly_count violation, got %v", err)
+	}
+	if err.Error() != "límite 'hourly_count' superado: 2 de 2 operaciones en 1h0m0s (quedan 0)" {
+		t.Fatalf("unexpected message: %v", err)
+	}
+
+	// otro caller tiene su propio contador
+	if err := v.Check(intent, map[string]string{"amount": "10"}, Caller{ID: "luis"}); err != nil {
+		t.Fatalf("unexpected: %v", err)
+	}
+
+	// una hora después el contador horario se ha vaciado, el diario no
+	at = at.Add(time.Hour + time.Minute)
+	err = v.Check(intent, map[string]string{"amount": "150"}, ana)
+	if !errors.As(err, &verr) || verr.Limit != "daily_amount" || verr.RemainingAmount() != 100 {
+		t.Fatalf("expected daily_amount violation, got %v", err)
+	}
+	if err.Error() != "límite 'daily_amount' superado: 200 + 150 > 300 en 24h0m0s (quedan 100)" {
+		t.Fatalf("unexpected message: %v", err)
+	}
+	if _, err := v.Reserve(intent, map[string]string{"amount": "100"}, ana); err != nil {
+		t.Fatalf("unexpected: %v", err)
+	}
+}
+
+func TestVelocity_ReleaseReturnsQuota(t *testing.T) {
+	v := NewVelocity(NewMemoryCounterStore())
+	intent := config.Intent{Limits: []config.VelocityLimit{{Name: "once", By: "params.toPhone", Window: "1h", MaxCount: 1}}}
+	params := map[string]string{"toPhone": "600111222"}
+
+	r, err := v.Reserve(intent, params, Caller{})
+	if err != nil {
+		t.Fatalf("unexpected: %v", err)
+	}
+	if _, err := v.Reserve(intent, params, Caller{}); err == nil {
+		t.Fatalf("expected limit to be reached")
+	}
+	r.Release()
+	if _, err := v.Reserve(intent, params, Caller{}); err != nil {
+		t.Fatalf("released quota must be available again: %v", err)
+	}
+}
+
+func TestVelocity_InvalidAmountFailsClosed(t *testing.T) {
+	v := NewVelocity(NewMemoryCounterStore())
+	intent := config.Intent{Limits: []config.VelocityLimit{{Name: "daily", Window: "24h", MaxAmount: 300}}}
+	if err := v.Check(intent, map[string]string{"amount": "mucho"}, Caller{}); err == nil {
+		t.Fatalf("expected error for an amount that cannot be counted")
+	}
+}
+
+func TestVelocity_NonFiniteAmountDoesNotDisableLimit(t *testing.T) {
+	v := NewVelocity(NewMemoryCounterStore())
+	intent := config.Intent{Limits: []config.VelocityLimit{{Name: "daily", Window: "24h", MaxAmount: 300}}}
+	for _, raw := range []string{"NaN", "Inf", "-Inf"} {
+		if _, err := v.Reserve(intent, map[string]string{"amount": raw}, Caller{}); err == nil {
+			t.Fatalf("expected error for amount %q", raw)
+		}
+	}
+	err := v.Check(intent, map[string]string{"amount": "301"}, Caller{})
+	var verr *VelocityError
+	if !errors.As(err, &verr) || verr.Limit != "daily" {
+		t.Fatalf("over-limit amount must still be refused, got %v", err)
+	}
+}
+
+func TestMemoryCounterStore_SweepsExpiredKeys(t *testing.T) {
+	s := NewMemoryCounterStore()
+	at := time.Now()
+	if _, err := s.Add("old", at, 1, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	later := at.Add(time.Hour)
+	for i := 1; i < sweepEvery; i++ {
+		_, _ = s.Add("new", later, 1, time.Minute)
+	}
+	if _, ok := s.events["old"]; ok {
+		t.Fatalf("expired key should have been swept")
+	}
+	if u, _ := s.Usage("new", later.Add(-time.Minute)); u.Count != sweepEvery-1 {
+		t.Fatalf("unexpected usage: %+v", u)
+	}
+}
@@ -4,12 +4,36 @@ import (
     "context"
     "encoding/json"
     "fmt"
+    "strings"
 )
 
+// ShadowNotice encabeza el resumen de una ejecución en shadow mode.
+const ShadowNotice = "[SIMULACIÓN] No se ha ejecutado ninguna operación real: las escrituras se han simulado."
+
 func SummarizeResult(ctx context.Context, c LLMClient, intentType string, rawResult map[string]any) (string, error) {
+	return c.Chat(WithRole(ctx, RoleSummary), summaryPrompt(intentType, rawResult, ""))
+}
+
+// SummarizeShadowResult resume una ejecución en shadow mode, en la que las
+// tools de escritura no se han ejecutado de verdad. El resumen siempre
+// empieza por ShadowNotice, aunque el modelo lo omita.
+func SummarizeShadowResult(ctx context.Context, c LLMClient, intentType string, rawResult map[string]any) (string, error) {
+	note := `
+IMPORTANTE: esta operación se ha ejecutado en MODO SIMULACIÓN (shadow mode).
+Los resultados marcados con "shadow": true NO se han enviado: son la petición
+que se habría hecho. Deja claro al usuario que no se ha ejecutado nada real.
+`
+	out, err := c.Chat(WithRole(ctx, RoleSummary), summaryPrompt(intentType, rawResult, note))
+	if err != nil {
+		return "", err
+	}
+	return ShadowNotice + " " + strings.TrimSpace(out), nil
+}
+
+func summaryPrompt(intentType string, rawResult map[string]any, note string) string {
 	rawJSON, _ := json.Marshal(rawResult)
 
-	prompt := fmt.Sprintf(`
+	return fmt.Sprintf(`
 Eres un asistente multi dominio (banking, devops, CRM, Helpdesk, salud) experto.
 
 Has ejecutado una operación con intent: "%s".
@@ -23,11 +47,5 @@ Escribe un resumen corto en español para el usuario final, explicando:
 - cualquier detalle relevante.
 
 Devuelve SOLO texto plano, sin JSON, sin listas.
-`, intentType, string(rawJSON))
-
- out, err := c.Chat(ctx, prompt)
-	if err != nil {
-		return "", err
-	}
-	return out, nil
+%s`, intentType, string(rawJSON), note)
 }
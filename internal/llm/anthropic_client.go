@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/metrics"
+)
+
+// AnthropicVersion is the anthropic-version header sent with every request.
+const AnthropicVersion = "2023-06-01"
+
+// AnthropicClient calls the Anthropic Messages API.
+type AnthropicClient struct {
+	BaseURL   string
+	APIKey    string
+	Model     string
+	MaxTokens int // max_tokens of each reply; 0 = 1024
+	HTTP      *http.Client
+	Timeout   time.Duration
+}
+
+// Compile-time interface conformance
+var _ LLMClient = (*AnthropicClient)(nil)
+
+// NewAnthropicClient crea un nuevo proveedor Anthropic.
+func NewAnthropicClient(baseURL, apiKey, model string) *AnthropicClient {
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com"
+	}
+	return &AnthropicClient{
+		BaseURL:   baseURL,
+		APIKey:    apiKey,
+		Model:     model,
+		MaxTokens: 1024,
+		HTTP: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		Timeout: 30 * time.Second,
+	}
+}
+
+// Ping lista los modelos para comprobar la API key y la conectividad.
+func (c *AnthropicClient) Ping(ctx context.Context) error {
+	if c.APIKey == "" {
+		return fmt.Errorf("anthropic api key is empty")
+	}
+	to := c.Timeout
+	if to <= 0 {
+		to = 2 * time.Second
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, to)
+	defer cancel()
+
+	url := strings.TrimRight(c.BaseURL, "/") + "/v1/models"
+	resp, err := retryHTTP(ctx, 3, 100*time.Millisecond, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.setHeaders(req)
+		return c.httpClient(to).Do(req)
+	})
+	if err != nil {
+		metrics.LLMPings.Inc(metricLabels(ctx, "anthropic", "error"))
+		return fmt.Errorf("anthropic ping failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		metrics.LLMPings.Inc(metricLabels(ctx, "anthropic", "error"))
+		return fmt.Errorf("anthropic ping bad status: %d, body: %s", resp.StatusCode, string(b))
+	}
+	metrics.LLMPings.Inc(metricLabels(ctx, "anthropic", "ok"))
+	return nil
+}
+
+// Chat envía el prompt como un único mensaje de usuario y devuelve el texto
+// de la respuesta.
+func (c *AnthropicClient) Chat(ctx context.Context, prompt string) (string, error) {
+	if c.APIKey == "" {
+		return "", fmt.Errorf("anthropic api key is empty")
+	}
+	maxTokens := c.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = 1024
+	}
+	body, err := json.Marshal(map[string]any{
+		"model":      c.Model,
+		"max_tokens": maxTokens,
+		"messages": []map[string]string{
+			{"role": "user", "content": prompt},
+		},
+		"temperature": 0,
+	})
+	if err != nil {
+		return "", fmt.Errorf("marshal payload: %w", err)
+	}
+
+	to := c.Timeout
+	if to <= 0 {
+		to = 30 * time.Second
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, to)
+	defer cancel()
+
+	url := strings.TrimRight(c.BaseURL, "/") + "/v1/messages"
+	start := time.Now()
+	resp, err := retryHTTP(ctx, 3, 100*time.Millisecond, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		c.setHeaders(req)
+		req.Header.Set("Content-Type", "application/json")
+		return c.httpClient(to).Do(req)
+	})
+	if err != nil {
+		metrics.LLMChats.Inc(metricLabels(ctx, "anthropic", "error"))
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		metrics.LLMChats.Inc(metricLabels(ctx, "anthropic", "error"))
+		return "", fmt.Errorf("anthropic chat failed: status %d, body: %s", resp.StatusCode, string(b))
+	}
+
+	var result struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		metrics.LLMChats.Inc(metricLabels(ctx, "anthropic", "error"))
+		return "", err
+	}
+	recordUsage(ctx, "anthropic", result.Usage.InputTokens, result.Usage.OutputTokens)
+
+	var text strings.Builder
+	for _, block := range result.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		metrics.LLMChats.Inc(metricLabels(ctx, "anthropic", "error"))
+		return "", fmt.Errorf("anthropic: empty response (stop_reason=%s)", result.StopReason)
+	}
+
+	metrics.LLMChats.Inc(metricLabels(ctx, "anthropic", "ok"))
+	metrics.LLMChatDur.Observe(metricLabels(ctx, "anthropic", "ok"), time.Since(start).Seconds())
+	return text.String(), nil
+}
+
+func (c *AnthropicClient) setHeaders(req *http.Request) {
+	req.Header.Set("x-api-key", c.APIKey)
+	req.Header.Set("anthropic-version", AnthropicVersion)
+}
+
+func (c *AnthropicClient) httpClient(timeout time.Duration) *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return &http.Client{Timeout: timeout}
+}
@@ -0,0 +1,176 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/metrics"
+)
+
+func TestAnthropic_Ping_OK(t *testing.T) {
+	var gotKey, gotVersion string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		gotKey, gotVersion = r.Header.Get("x-api-key"), r.Header.Get("anthropic-version")
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer ts.Close()
+
+	c := NewAnthropicClient(ts.URL, "test-key", "claude-sonnet-4-5")
+	c.Timeout = 500 * time.Millisecond
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() unexpected error: %v", err)
+	}
+	if gotKey != "test-key" || gotVersion != AnthropicVersion {
+		t.Fatalf("unexpected headers: x-api-key=%q anthropic-version=%q", gotKey, gotVersion)
+	}
+}
+
+func TestAnthropic_Ping_Non200(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"type":"error","error":{"type":"authentication_error"}}`, http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	c := NewAnthropicClient(ts.URL, "bad-key", "claude-sonnet-4-5")
+	err := c.Ping(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "401") || !strings.Contains(err.Error(), "authentication_error") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAnthropic_Chat_Success(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/messages" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "key" || r.Header.Get("anthropic-version") != AnthropicVersion {
+			t.Fatalf("missing API headers: %v", r.Header)
+		}
+		var body struct {
+			Model     string `json:"model"`
+			MaxTokens int    `json:"max_tokens"`
+			Messages  []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("bad body: %v", err)
+		}
+		if body.Model != "claude-sonnet-4-5" || body.MaxTokens != 1024 || len(body.Messages) != 1 ||
+			body.Messages[0].Role != "user" || body.Messages[0].Content != "hola" {
+			t.Fatalf("unexpected body: %+v", body)
+		}
+		_, _ = w.Write([]byte(`{
+			"content":[{"type":"text","text":"Hola, "},{"type":"text","text":"¿qué tal?"}],
+			"stop_reason":"end_turn",
+			"usage":{"input_tokens":12,"output_tokens":5}
+		}`))
+	}))
+	defer ts.Close()
+
+	c := NewAnthropicClient(ts.URL, "key", "claude-sonnet-4-5")
+	var usage Usage
+	ctx := WithUsage(WithRole(context.Background(), RoleSummary), &usage)
+	out, err := c.Chat(ctx, "hola")
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if out != "Hola, ¿qué tal?" {
+		t.Fatalf("unexpected output: %q", out)
+	}
+	if in, outTok := usage.Tokens(); in != 12 || outTok != 5 {
+		t.Fatalf("unexpected usage: in=%d out=%d", in, outTok)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`aos_llm_chats_total{outcome="ok",provider="anthropic",role="summary"}`,
+		`aos_llm_tokens_total{kind="input",provider="anthropic",role="summary"}`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("missing %s in metrics", want)
+		}
+	}
+}
+
+func TestAnthropic_Chat_RetriesRateLimit(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"content":[{"type":"text","text":"ok"}],"usage":{}}`))
+	}))
+	defer ts.Close()
+
+	c := NewAnthropicClient(ts.URL, "key", "claude-sonnet-4-5")
+	if out, err := c.Chat(context.Background(), "hi"); err != nil || out != "ok" {
+		t.Fatalf("expected retry to succeed, got %q err=%v", out, err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestAnthropic_Chat_Errors(t *testing.T) {
+	cases := map[string]struct {
+		status int
+		body   string
+		want   string
+	}{
+		"status":      {http.StatusBadRequest, `{"type":"error","error":{"message":"max_tokens"}}`, "status 400"},
+		"bad json":    {http.StatusOK, `{"content":`, "unexpected EOF"},
+		"no text":     {http.StatusOK, `{"content":[],"stop_reason":"max_tokens"}`, "stop_reason=max_tokens"},
+		"tool blocks": {http.StatusOK, `{"content":[{"type":"tool_use"}],"stop_reason":"tool_use"}`, "empty response"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer ts.Close()
+
+			c := NewAnthropicClient(ts.URL, "key", "claude-sonnet-4-5")
+			if _, err := c.Chat(context.Background(), "hi"); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestAnthropic_APIKey_Required(t *testing.T) {
+	c := NewAnthropicClient("http://example", "", "claude-sonnet-4-5")
+	if err := c.Ping(context.Background()); err == nil {
+		t.Fatalf("expected error when API key is empty for Ping")
+	}
+	if _, err := c.Chat(context.Background(), "hello"); err == nil {
+		t.Fatalf("expected error when API key is empty for Chat")
+	}
+}
+
+func TestAnthropic_Chat_ContextTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"content":[{"type":"text","text":"late"}]}`))
+	}))
+	defer ts.Close()
+
+	c := NewAnthropicClient(ts.URL, "key", "claude-sonnet-4-5")
+	c.Timeout = 100 * time.Millisecond
+	if _, err := c.Chat(context.Background(), "hi"); err == nil {
+		t.Fatalf("expected timeout error from context")
+	}
+}
@@ -47,7 +47,7 @@ User message:
 "%s"
 `, intentsJSON, text)
 
- raw, err := c.Chat(ctx, prompt)
+ raw, err := c.Chat(WithRole(ctx, RoleIntent), prompt)
 	if err != nil {
 		return nil, err
 	}
@@ -94,7 +94,7 @@ User message:
 "%s"
 `, intentsJSON, userMsg)
 
- raw, err := client.Chat(ctx, prompt)
+ raw, err := client.Chat(WithRole(ctx, RoleIntent), prompt)
 	if err != nil {
 		return nil, err
 	}
@@ -6,10 +6,48 @@ import (
     "fmt"
     "regexp"
     "strings"
+
+    "github.com/ccastromar/aos-agent-orchestration-system/internal/logx"
+    "github.com/ccastromar/aos-agent-orchestration-system/internal/metrics"
 )
 
+// ParamHint tells the LLM what a param means, e.g. "decimal, max 100.
+// Importe en euros". Enum restricts the JSON schema of the param and
+// Validate, when set, checks every non-empty extracted value.
+type ParamHint struct {
+	Name        string
+	Description string
+	Enum        []string
+	Validate    func(string) error
+}
+
+// MaxRepairRounds is how many times ExtractParamsWithHints sends the
+// validation errors back to the model before giving up.
+const MaxRepairRounds = 1
+
 func ExtractParams(ctx context.Context, client LLMClient, userMsg string, required []string) (map[string]string, error) {
-	paramsJSON, _ := json.Marshal(required)
+	hints := make([]ParamHint, 0, len(required))
+	for _, name := range required {
+		hints = append(hints, ParamHint{Name: name})
+	}
+	return ExtractParamsWithHints(ctx, client, userMsg, hints)
+}
+
+// ExtractParamsWithHints is ExtractParams with a description of each param
+// added to the prompt.
+func ExtractParamsWithHints(ctx context.Context, client LLMClient, userMsg string, params []ParamHint) (map[string]string, error) {
+	names := make([]string, 0, len(params))
+	var meanings strings.Builder
+	for _, p := range params {
+		names = append(names, p.Name)
+		if d := strings.TrimSpace(p.Description); d != "" {
+			if meanings.Len() == 0 {
+				meanings.WriteString("\nParameter meanings:\n")
+			}
+			fmt.Fprintf(&meanings, "- %s: %s\n", p.Name, d)
+		}
+	}
+	paramsJSON, _ := json.Marshal(names)
 
 	prompt := fmt.Sprintf(`
 Extract ONLY the required parameters from the user message.
@@ -23,33 +61,138 @@ Requirements:
 - NO explanation.
 - NO prefix.
 - NO suffix.
-- If missing, infer value from message.
-
+- If a value is NOT explicitly present in the message, use an empty string "".
+- NEVER invent or guess values.
+%s
 User message: "%s"
-`, string(paramsJSON), userMsg)
+`, string(paramsJSON), meanings.String(), userMsg)
+
+ ctx = WithRole(ctx, RoleParams)
+	schema := paramsSchema(params)
+	attempt := prompt
+	var best map[string]string
+	var problems []string
+	for round := 0; ; round++ {
+		raw, err := chatJSON(ctx, client, attempt, schema)
+		if err != nil {
+			if best != nil {
+				break
+			}
+			return nil, fmt.Errorf("error en LLM: %w", err)
+		}
+		logx.Debug("LLM", "ExtractParams raw output: %s", raw)
 
- raw, err := client.Chat(ctx, prompt)
-	if err != nil {
-		return nil, fmt.Errorf("error en LLM: %w", err)
+		var values map[string]string
+		values, problems = parseParams(raw, params)
+		if values != nil {
+			best = values
+		}
+		if len(problems) == 0 {
+			if round > 0 {
+				metrics.LLMRepairs.Inc(map[string]string{"outcome": "ok"})
+			}
+			return values, nil
+		}
+		if round == MaxRepairRounds {
+			break
+		}
+		logx.Warn("LLM", "ExtractParams: salida inválida, reparando: %s", strings.Join(problems, "; "))
+		attempt = repairPrompt(prompt, raw, problems)
 	}
+	metrics.LLMRepairs.Inc(map[string]string{"outcome": "failed"})
 
-	// Log para debug
-	fmt.Printf("RAW LLM OUTPUT: %s\n", raw)
+	if best == nil {
+		return nil, fmt.Errorf("error parseando JSON de parámetros: %s", strings.Join(problems, "; "))
+	}
+	// Valores inválidos tras la reparación: se devuelven tal cual y la
+	// validación de los params tipados los rechaza con su error.
+	return best, nil
+}
 
+// parseParams decodes the JSON object of an ExtractParams reply into string
+// values (null -> absent, so the Planner asks for it) and lists what is
+// wrong with it. values is nil when the reply is not a JSON object.
+func parseParams(raw string, params []ParamHint) (values map[string]string, problems []string) {
 	clean := sanitizeLLMOutput(raw)
-
 	var tmp map[string]interface{}
 	if err := json.Unmarshal([]byte(clean), &tmp); err != nil {
-		return nil, fmt.Errorf("error parseando JSON de parámetros: %w; clean=%s", err, clean)
+		return nil, []string{fmt.Sprintf("la salida no es un objeto JSON válido (%v): %s", err, clean)}
 	}
 
-	// Convertir todo a string
-	out := map[string]string{}
+	values = map[string]string{}
 	for k, v := range tmp {
-		out[k] = fmt.Sprintf("%v", v)
+		if v == nil {
+			continue
+		}
+		values[k] = fmt.Sprintf("%v", v)
 	}
+	return values, validateParams(values, params)
+}
 
-	return out, nil
+// validateParams checks the non-empty values against their hints.
+func validateParams(values map[string]string, params []ParamHint) (problems []string) {
+	for _, p := range params {
+		v := strings.TrimSpace(values[p.Name])
+		if v == "" {
+			continue
+		}
+		if len(p.Enum) > 0 && !containsFold(p.Enum, v) {
+			problems = append(problems, fmt.Sprintf("%s: %q no es uno de %s", p.Name, v, strings.Join(p.Enum, ", ")))
+			continue
+		}
+		if p.Validate != nil {
+			if err := p.Validate(v); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	return problems
+}
+
+// paramsSchema is the JSON schema of an ExtractParams reply: an object with
+// exactly the given keys, all strings ("" when absent).
+func paramsSchema(params []ParamHint) map[string]any {
+	props := make(map[string]any, len(params))
+	required := make([]string, 0, len(params))
+	for _, p := range params {
+		prop := map[string]any{"type": "string"}
+		if d := strings.TrimSpace(p.Description); d != "" {
+			prop["description"] = d
+		}
+		if len(p.Enum) > 0 {
+			prop["enum"] = append(append([]string(nil), p.Enum...), "")
+		}
+		props[p.Name] = prop
+		required = append(required, p.Name)
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// repairPrompt asks the model to fix its previous reply.
+func repairPrompt(prompt, previous string, problems []string) string {
+	return fmt.Sprintf(`%s
+Your previous answer was:
+%s
+
+It is invalid:
+- %s
+
+Return the corrected JSON only. Use "" for values not present in the message.
+`, prompt, previous, strings.Join(problems, "\n- "))
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
 }
 
 func sanitizeLLMOutput(s string) string {
@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// jsonScript answers ChatJSON with its replies in order and records the
+// prompts and the schema it got.
+type jsonScript struct {
+	replies []string
+	prompts []string
+	schema  map[string]any
+}
+
+func (s *jsonScript) Ping(ctx context.Context) error { return nil }
+
+func (s *jsonScript) Chat(ctx context.Context, prompt string) (string, error) {
+	return "", errors.New("Chat must not be used when JSON mode is available")
+}
+
+func (s *jsonScript) ChatJSON(ctx context.Context, prompt string, schema map[string]any) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	s.schema = schema
+	if len(s.replies) == 0 {
+		return "", errors.New("no more replies")
+	}
+	out := s.replies[0]
+	s.replies = s.replies[1:]
+	return out, nil
+}
+
+func amountHints() []ParamHint {
+	return []ParamHint{
+		{Name: "amount", Description: "decimal", Validate: func(v string) error {
+			if strings.Trim(v, "0123456789.,") != "" {
+				return errors.New("amount: no es un número")
+			}
+			return nil
+		}},
+		{Name: "kind", Enum: []string{"bizum", "transfer"}},
+	}
+}
+
+func TestExtractParams_UsesJSONModeWithSchema(t *testing.T) {
+	c := &jsonScript{replies: []string{`{"amount":"20","kind":"Bizum"}`}}
+	got, err := ExtractParamsWithHints(context.Background(), c, "20 por bizum", amountHints())
+	if err != nil || got["amount"] != "20" || got["kind"] != "Bizum" {
+		t.Fatalf("unexpected %v err=%v", got, err)
+	}
+
+	props := c.schema["properties"].(map[string]any)
+	kind := props["kind"].(map[string]any)
+	if c.schema["additionalProperties"] != false || len(c.schema["required"].([]string)) != 2 {
+		t.Fatalf("unexpected schema %v", c.schema)
+	}
+	if enum := kind["enum"].([]string); strings.Join(enum, ",") != "bizum,transfer," {
+		t.Fatalf("enum must allow the values and \"\", got %v", enum)
+	}
+}
+
+func TestExtractParams_RepairsInvalidReply(t *testing.T) {
+	c := &jsonScript{replies: []string{
+		`{"amount":"veinte","kind":"cheque"}`,
+		`{"amount":"20","kind":"bizum"}`,
+	}}
+	got, err := ExtractParamsWithHints(context.Background(), c, "veinte euros", amountHints())
+	if err != nil || got["amount"] != "20" || got["kind"] != "bizum" {
+		t.Fatalf("unexpected %v err=%v", got, err)
+	}
+	if len(c.prompts) != 2 {
+		t.Fatalf("expected one repair round, got %d calls", len(c.prompts))
+	}
+	repair := c.prompts[1]
+	for _, want := range []string{`{"amount":"veinte","kind":"cheque"}`, "amount: no es un número", `kind: "cheque" no es uno de bizum, transfer`} {
+		if !strings.Contains(repair, want) {
+			t.Fatalf("repair prompt lacks %q:\n%s", want, repair)
+		}
+	}
+}
+
+func TestExtractParams_RepairIsBounded(t *testing.T) {
+	c := &jsonScript{replies: []string{`{"amount":"veinte"}`, `{"amount":"muchos"}`, `{"amount":"20"}`}}
+	got, err := ExtractParamsWithHints(context.Background(), c, "veinte euros", amountHints())
+	if err != nil {
+		t.Fatalf("invalid values are returned for the caller to reject: %v", err)
+	}
+	if got["amount"] != "muchos" || len(c.prompts) != 1+MaxRepairRounds {
+		t.Fatalf("expected the last reply after %d calls, got %v after %d", 1+MaxRepairRounds, got, len(c.prompts))
+	}
+}
+
+func TestExtractParams_UnparseableAfterRepairIsAnError(t *testing.T) {
+	c := &jsonScript{replies: []string{"no sé", "tampoco"}}
+	if _, err := ExtractParamsWithHints(context.Background(), c, "hola", amountHints()); err == nil || !strings.Contains(err.Error(), "JSON") {
+		t.Fatalf("expected JSON error, got %v", err)
+	}
+}
+
+func TestExtractParams_RepairsBrokenJSON(t *testing.T) {
+	c := &jsonScript{replies: []string{`{"amount": 20,`, `{"amount": 20}`}}
+	got, err := ExtractParamsWithHints(context.Background(), c, "20", amountHints())
+	if err != nil || got["amount"] != "20" {
+		t.Fatalf("unexpected %v err=%v", got, err)
+	}
+}
@@ -0,0 +1,222 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/logx"
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/metrics"
+)
+
+// NamedClient is a provider of a FallbackClient.
+type NamedClient struct {
+	Name   string
+	Client LLMClient
+}
+
+// ProviderStatus is the health of a provider as seen by a FallbackClient.
+type ProviderStatus struct {
+	Name      string    `json:"name"`
+	Healthy   bool      `json:"healthy"`
+	LastError string    `json:"lastError,omitempty"`
+	Since     time.Time `json:"since"` // last change of Healthy
+}
+
+// FallbackClient tries its providers in order. A provider whose Chat or
+// Ping fails is marked unhealthy and skipped for retryAfter; after that it
+// must answer a Ping before it gets traffic again. When every provider is
+// unhealthy they are all tried anyway, so a recovered one is not missed.
+type FallbackClient struct {
+	retryAfter time.Duration
+	now        func() time.Time
+
+	mu        sync.Mutex
+	providers []*providerState
+}
+
+type providerState struct {
+	NamedClient
+	healthy   bool
+	lastError string
+	since     time.Time
+}
+
+var (
+	_ LLMClient  = (*FallbackClient)(nil)
+	_ JSONClient = (*FallbackClient)(nil)
+	_ ToolClient = (*FallbackClient)(nil)
+)
+
+// NewFallbackClient builds a FallbackClient; retryAfter <= 0 means 30s.
+func NewFallbackClient(retryAfter time.Duration, providers ...NamedClient) *FallbackClient {
+	if retryAfter <= 0 {
+		retryAfter = 30 * time.Second
+	}
+	f := &FallbackClient{retryAfter: retryAfter, now: time.Now}
+	for _, p := range providers {
+		f.providers = append(f.providers, &providerState{NamedClient: p, healthy: true, since: f.now()})
+	}
+	return f
+}
+
+// Chat sends the prompt to the first healthy provider that answers.
+func (f *FallbackClient) Chat(ctx context.Context, prompt string) (string, error) {
+	return fallbackCall(f, ctx, func(ctx context.Context, c LLMClient) (string, error) {
+		return c.Chat(ctx, prompt)
+	})
+}
+
+// ChatJSON is Chat in JSON mode; providers without one get a plain Chat.
+func (f *FallbackClient) ChatJSON(ctx context.Context, prompt string, schema map[string]any) (string, error) {
+	return fallbackCall(f, ctx, func(ctx context.Context, c LLMClient) (string, error) {
+		return chatJSON(ctx, c, prompt, schema)
+	})
+}
+
+// ChatTools is ChatTools of the first healthy provider that supports it.
+func (f *FallbackClient) ChatTools(ctx context.Context, prompt string, tools []ToolSpec) (*ToolCall, error) {
+	return fallbackCall(f, ctx, func(ctx context.Context, c LLMClient) (*ToolCall, error) {
+		return chatTools(ctx, c, prompt, tools)
+	})
+}
+
+// fallbackCall runs op against the first healthy provider of f that
+// succeeds. A provider without the feature (ErrToolsUnsupported) is
+// skipped but not marked unhealthy.
+func fallbackCall[T any](f *FallbackClient, ctx context.Context, op func(context.Context, LLMClient) (T, error)) (T, error) {
+	var zero T
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var errs []error
+	tried := map[*providerState]bool{}
+	try := func(p *providerState) (T, bool) {
+		tried[p] = true
+		out, err := op(ctx, p.Client)
+		if err == nil {
+			f.markHealthy(p)
+			return out, true
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+		if ctx.Err() == nil && !errors.Is(err, ErrToolsUnsupported) {
+			// el fallo es del provider, no de quien llama
+			f.markUnhealthy(p, err)
+		}
+		return zero, false
+	}
+
+	from := ""
+	for _, p := range f.candidates(ctx) {
+		if from != "" {
+			metrics.LLMFallbacks.Inc(map[string]string{"from": from, "to": p.Name, "role": string(RoleFrom(ctx))})
+			logx.Warn("LLM", "fallback %s → %s (%s)", from, p.Name, RoleFrom(ctx))
+		}
+		if out, ok := try(p); ok {
+			return out, nil
+		}
+		if ctx.Err() != nil {
+			return zero, errors.Join(errs...)
+		}
+		from = p.Name
+	}
+	// Todos caídos: se prueban igualmente los que se saltaron.
+	for _, p := range f.snapshot() {
+		if tried[p] {
+			continue
+		}
+		if out, ok := try(p); ok {
+			return out, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errs) == 0 {
+		return zero, errors.New("llm: no hay providers configurados")
+	}
+	return zero, errors.Join(errs...)
+}
+
+// Ping pings every provider, updating their health, and succeeds when at
+// least one answers.
+func (f *FallbackClient) Ping(ctx context.Context) error {
+	providers := f.snapshot()
+	if len(providers) == 0 {
+		return errors.New("llm: no hay providers configurados")
+	}
+	var errs []error
+	for _, p := range providers {
+		if err := p.Client.Ping(ctx); err != nil {
+			f.markUnhealthy(p, err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+			continue
+		}
+		f.markHealthy(p)
+	}
+	if len(errs) == len(providers) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// Status returns the health of every provider, in order.
+func (f *FallbackClient) Status() []ProviderStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]ProviderStatus, 0, len(f.providers))
+	for _, p := range f.providers {
+		out = append(out, ProviderStatus{Name: p.Name, Healthy: p.healthy, LastError: p.lastError, Since: p.since})
+	}
+	return out
+}
+
+// candidates returns the providers to try, in order: the healthy ones and
+// the unhealthy ones whose retryAfter elapsed and answer a Ping.
+func (f *FallbackClient) candidates(ctx context.Context) []*providerState {
+	var out []*providerState
+	for _, p := range f.snapshot() {
+		f.mu.Lock()
+		healthy, due := p.healthy, f.now().Sub(p.since) >= f.retryAfter
+		f.mu.Unlock()
+		switch {
+		case healthy:
+			out = append(out, p)
+		case due:
+			if err := p.Client.Ping(ctx); err != nil {
+				f.markUnhealthy(p, err) // reinicia la espera
+				continue
+			}
+			f.markHealthy(p)
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func (f *FallbackClient) snapshot() []*providerState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*providerState(nil), f.providers...)
+}
+
+func (f *FallbackClient) markHealthy(p *providerState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !p.healthy {
+		logx.Info("LLM", "provider %s recovered", p.Name)
+		p.healthy, p.since = true, f.now()
+	}
+	p.lastError = ""
+}
+
+func (f *FallbackClient) markUnhealthy(p *providerState, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if p.healthy {
+		logx.Warn("LLM", "provider %s unhealthy: %v", p.Name, err)
+	}
+	p.healthy, p.since, p.lastError = false, f.now(), err.Error()
+}
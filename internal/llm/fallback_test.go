@@ -0,0 +1,133 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProvider answers with its name, or fails while down is set.
+type fakeProvider struct {
+	name  string
+	down  bool
+	chats int
+	pings int
+}
+
+func (f *fakeProvider) Chat(ctx context.Context, prompt string) (string, error) {
+	f.chats++
+	if f.down {
+		return "", errors.New(f.name + " down")
+	}
+	return f.name, nil
+}
+
+func (f *fakeProvider) Ping(ctx context.Context) error {
+	f.pings++
+	if f.down {
+		return errors.New(f.name + " down")
+	}
+	return nil
+}
+
+func newTestFallback(providers ...*fakeProvider) (*FallbackClient, *time.Time) {
+	clients := make([]NamedClient, 0, len(providers))
+	for _, p := range providers {
+		clients = append(clients, NamedClient{Name: p.name, Client: p})
+	}
+	f := NewFallbackClient(time.Minute, clients...)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+	return f, &now
+}
+
+func TestFallbackClient_UsesFirstHealthyProvider(t *testing.T) {
+	ollama, openai := &fakeProvider{name: "ollama"}, &fakeProvider{name: "openai"}
+	f, _ := newTestFallback(ollama, openai)
+
+	if out, err := f.Chat(context.Background(), "hola"); err != nil || out != "ollama" {
+		t.Fatalf("expected ollama, got %q err=%v", out, err)
+	}
+	if openai.chats != 0 {
+		t.Fatalf("second provider must not be called while the first works")
+	}
+}
+
+func TestFallbackClient_FailsOverAndRecovers(t *testing.T) {
+	ollama, openai := &fakeProvider{name: "ollama", down: true}, &fakeProvider{name: "openai"}
+	f, now := newTestFallback(ollama, openai)
+
+	if out, err := f.Chat(context.Background(), "hola"); err != nil || out != "openai" {
+		t.Fatalf("expected fallback to openai, got %q err=%v", out, err)
+	}
+	if st := f.Status(); st[0].Healthy || !strings.Contains(st[0].LastError, "ollama down") || !st[1].Healthy {
+		t.Fatalf("unexpected status %+v", st)
+	}
+
+	// dentro de retryAfter el provider caído ni se prueba
+	ollama.down = false
+	if out, _ := f.Chat(context.Background(), "hola"); out != "openai" || ollama.chats != 1 || ollama.pings != 0 {
+		t.Fatalf("unhealthy provider must be skipped, got %q chats=%d pings=%d", out, ollama.chats, ollama.pings)
+	}
+
+	// pasado retryAfter vuelve tras responder a un Ping
+	*now = now.Add(time.Minute)
+	if out, _ := f.Chat(context.Background(), "hola"); out != "ollama" || ollama.pings != 1 {
+		t.Fatalf("expected recovered ollama, got %q pings=%d", out, ollama.pings)
+	}
+	if st := f.Status(); !st[0].Healthy || st[0].LastError != "" {
+		t.Fatalf("expected ollama healthy again, got %+v", st[0])
+	}
+}
+
+func TestFallbackClient_AllUnhealthyAreTriedAnyway(t *testing.T) {
+	ollama, openai := &fakeProvider{name: "ollama", down: true}, &fakeProvider{name: "openai", down: true}
+	f, _ := newTestFallback(ollama, openai)
+
+	_, err := f.Chat(context.Background(), "hola")
+	if err == nil || !strings.Contains(err.Error(), "ollama: ollama down") || !strings.Contains(err.Error(), "openai: openai down") {
+		t.Fatalf("expected both errors, got %v", err)
+	}
+
+	openai.down = false
+	if out, err := f.Chat(context.Background(), "hola"); err != nil || out != "openai" {
+		t.Fatalf("expected last-resort try to reach openai, got %q err=%v", out, err)
+	}
+}
+
+func TestFallbackClient_CancelledContextDoesNotMarkUnhealthy(t *testing.T) {
+	slow := &ctxProvider{}
+	f := NewFallbackClient(time.Minute, NamedClient{Name: "slow", Client: slow}, NamedClient{Name: "other", Client: &fakeProvider{name: "other"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.Chat(ctx, "hola"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if st := f.Status(); !st[0].Healthy || !st[1].Healthy {
+		t.Fatalf("a cancelled call must not change health, got %+v", st)
+	}
+}
+
+func TestFallbackClient_Ping(t *testing.T) {
+	ollama, openai := &fakeProvider{name: "ollama", down: true}, &fakeProvider{name: "openai"}
+	f, _ := newTestFallback(ollama, openai)
+	if err := f.Ping(context.Background()); err != nil {
+		t.Fatalf("one healthy provider is enough: %v", err)
+	}
+	if f.Status()[0].Healthy {
+		t.Fatalf("Ping must record the failed provider")
+	}
+	openai.down = true
+	if err := f.Ping(context.Background()); err == nil {
+		t.Fatalf("expected error with every provider down")
+	}
+}
+
+// ctxProvider fails with the error of its context.
+type ctxProvider struct{}
+
+func (ctxProvider) Chat(ctx context.Context, prompt string) (string, error) { return "", ctx.Err() }
+func (ctxProvider) Ping(ctx context.Context) error                          { return ctx.Err() }
@@ -0,0 +1,18 @@
+package llm
+
+import "context"
+
+// JSONClient is implemented by providers with a structured-output mode:
+// the reply is constrained to JSON matching schema (a JSON Schema object).
+type JSONClient interface {
+	ChatJSON(ctx context.Context, prompt string, schema map[string]any) (string, error)
+}
+
+// chatJSON uses the JSON mode of c when it has one, and a plain Chat
+// otherwise; callers still have to parse and validate the reply.
+func chatJSON(ctx context.Context, c LLMClient, prompt string, schema map[string]any) (string, error) {
+	if jc, ok := c.(JSONClient); ok {
+		return jc.ChatJSON(ctx, prompt, schema)
+	}
+	return c.Chat(ctx, prompt)
+}
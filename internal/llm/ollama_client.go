@@ -24,6 +24,8 @@ type OllamaClient struct {
 
 // Asegura que implementa la interfaz
 var _ LLMClient = (*OllamaClient)(nil)
+var _ JSONClient = (*OllamaClient)(nil)
+var _ ToolClient = (*OllamaClient)(nil)
 
 func NewOllamaClient(baseURL, model string) *OllamaClient {
 	return &OllamaClient{
@@ -55,6 +57,18 @@ type ollamaChatResponse struct {
 }
 
 func (c *OllamaClient) Chat(ctx context.Context, prompt string) (string, error) {
+    return c.chat(ctx, prompt, nil)
+}
+
+// ChatJSON pide la respuesta en JSON con el schema dado (campo "format").
+func (c *OllamaClient) ChatJSON(ctx context.Context, prompt string, schema map[string]any) (string, error) {
+    if schema == nil {
+        return c.chat(ctx, prompt, "json")
+    }
+    return c.chat(ctx, prompt, schema)
+}
+
+func (c *OllamaClient) chat(ctx context.Context, prompt string, format any) (string, error) {
     payload := map[string]any{
         "model": c.Model,
         "messages": []map[string]any{
@@ -62,6 +76,9 @@ func (c *OllamaClient) Chat(ctx context.Context, prompt string) (string, error)
         },
         "stream": true,
     }
+    if format != nil {
+        payload["format"] = format
+    }
 
 	data, err := json.Marshal(payload)
 	if err != nil {
@@ -89,13 +106,13 @@ func (c *OllamaClient) Chat(ctx context.Context, prompt string) (string, error)
         return httpClient.Do(req)
     })
     if err != nil {
-        metrics.LLMChats.Inc(map[string]string{"provider": "ollama", "outcome": "error"})
+        metrics.LLMChats.Inc(metricLabels(ctx, "ollama", "error"))
         return "", err
     }
     defer resp.Body.Close()
     if resp.StatusCode != http.StatusOK {
         b, _ := io.ReadAll(resp.Body)
-        metrics.LLMChats.Inc(map[string]string{"provider": "ollama", "outcome": "error"})
+        metrics.LLMChats.Inc(metricLabels(ctx, "ollama", "error"))
         return "", fmt.Errorf("ollama chat failed: status %d, body: %s", resp.StatusCode, string(b))
     }
 
@@ -115,7 +132,7 @@ func (c *OllamaClient) Chat(ctx context.Context, prompt string) (string, error)
             if err.Error() == "EOF" {
                 break
             }
-            metrics.LLMChats.Inc(map[string]string{"provider": "ollama", "outcome": "error"})
+            metrics.LLMChats.Inc(metricLabels(ctx, "ollama", "error"))
             return "", err
         }
 
@@ -128,8 +145,8 @@ func (c *OllamaClient) Chat(ctx context.Context, prompt string) (string, error)
 		}
 	}
 
-    metrics.LLMChats.Inc(map[string]string{"provider": "ollama", "outcome": "ok"})
-    metrics.LLMChatDur.Observe(map[string]string{"provider": "ollama", "outcome": "ok"}, time.Since(start).Seconds())
+    metrics.LLMChats.Inc(metricLabels(ctx, "ollama", "ok"))
+    metrics.LLMChatDur.Observe(metricLabels(ctx, "ollama", "ok"), time.Since(start).Seconds())
     return out.String(), nil
 }
 
@@ -155,15 +172,91 @@ func (c *OllamaClient) Ping(ctx context.Context) error {
         return httpClient.Do(req)
     })
     if err != nil {
-        metrics.LLMPings.Inc(map[string]string{"provider": "ollama", "outcome": "error"})
+        metrics.LLMPings.Inc(metricLabels(ctx, "ollama", "error"))
         return err
     }
     defer resp.Body.Close()
 
     if resp.StatusCode != http.StatusOK {
-        metrics.LLMPings.Inc(map[string]string{"provider": "ollama", "outcome": "error"})
+        metrics.LLMPings.Inc(metricLabels(ctx, "ollama", "error"))
         return fmt.Errorf("llm ping failed: status %d", resp.StatusCode)
     }
-    metrics.LLMPings.Inc(map[string]string{"provider": "ollama", "outcome": "ok"})
+    metrics.LLMPings.Inc(metricLabels(ctx, "ollama", "ok"))
     return nil
 }
+
+// ChatTools ofrece las funciones al modelo (campo "tools") y devuelve la
+// primera que llama. Los modelos sin soporte de tools responden 400.
+func (c *OllamaClient) ChatTools(ctx context.Context, prompt string, tools []ToolSpec) (*ToolCall, error) {
+	defs := make([]map[string]any, 0, len(tools))
+	for _, t := range tools {
+		defs = append(defs, map[string]any{
+			"type":     "function",
+			"function": map[string]any{"name": t.Name, "description": t.Description, "parameters": t.Parameters},
+		})
+	}
+	data, err := json.Marshal(map[string]any{
+		"model":    c.Model,
+		"messages": []map[string]any{{"role": "user", "content": prompt}},
+		"tools":    defs,
+		"stream":   false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal payload: %w", err)
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	start := time.Now()
+	resp, err := retryHTTP(ctx, 3, 100*time.Millisecond, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/api/chat", bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("new request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return httpClient.Do(req)
+	})
+	if err != nil {
+		metrics.LLMChats.Inc(metricLabels(ctx, "ollama", "error"))
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		metrics.LLMChats.Inc(metricLabels(ctx, "ollama", "error"))
+		if resp.StatusCode == http.StatusBadRequest && bytes.Contains(b, []byte("does not support tools")) {
+			return nil, fmt.Errorf("%w: %s", ErrToolsUnsupported, c.Model)
+		}
+		return nil, fmt.Errorf("ollama chat failed: status %d, body: %s", resp.StatusCode, string(b))
+	}
+
+	var out struct {
+		Message struct {
+			ToolCalls []struct {
+				Function struct {
+					Name      string         `json:"name"`
+					Arguments map[string]any `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		metrics.LLMChats.Inc(metricLabels(ctx, "ollama", "error"))
+		return nil, err
+	}
+	metrics.LLMChats.Inc(metricLabels(ctx, "ollama", "ok"))
+	metrics.LLMChatDur.Observe(metricLabels(ctx, "ollama", "ok"), time.Since(start).Seconds())
+	if len(out.Message.ToolCalls) == 0 {
+		return nil, nil
+	}
+	fn := out.Message.ToolCalls[0].Function
+	return &ToolCall{Name: fn.Name, Arguments: fn.Arguments}, nil
+}
@@ -4,6 +4,7 @@ import (
     "bufio"
     "context"
     "encoding/json"
+    "errors"
     "net/http"
     "net/http/httptest"
     "strings"
@@ -103,3 +104,63 @@ func TestChat_BadJSONChunk(t *testing.T) {
         t.Fatalf("expected error on malformed json stream")
     }
 }
+
+func TestChatJSON_SendsFormat(t *testing.T) {
+    var got map[string]any
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        _ = json.NewDecoder(r.Body).Decode(&got)
+        _ = json.NewEncoder(w).Encode(map[string]any{
+            "message": map[string]any{"role": "assistant", "content": `{"a":"1"}`},
+            "done":    true,
+        })
+    }))
+    defer ts.Close()
+
+    c := NewOllamaClient(ts.URL, "qwen3:0.6b")
+    schema := map[string]any{"type": "object"}
+    if out, err := c.ChatJSON(context.Background(), "x", schema); err != nil || out != `{"a":"1"}` {
+        t.Fatalf("ChatJSON() = %q, %v", out, err)
+    }
+    if f, ok := got["format"].(map[string]any); !ok || f["type"] != "object" {
+        t.Fatalf("expected the schema in format, got %v", got["format"])
+    }
+
+    got = nil
+    if _, err := c.Chat(context.Background(), "x"); err != nil {
+        t.Fatalf("Chat() unexpected error: %v", err)
+    }
+    if _, ok := got["format"]; ok {
+        t.Fatalf("Chat must not send format")
+    }
+}
+
+func TestChatTools_ParsesToolCall(t *testing.T) {
+    var got map[string]any
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        _ = json.NewDecoder(r.Body).Decode(&got)
+        w.Write([]byte(`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"banking__get_balance","arguments":{"accountId":"555"}}}]},"done":true}`))
+    }))
+    defer ts.Close()
+
+    c := NewOllamaClient(ts.URL, "qwen3:0.6b")
+    call, err := c.ChatTools(context.Background(), "saldo 555", []ToolSpec{{Name: "banking__get_balance", Parameters: map[string]any{"type": "object"}}})
+    if err != nil || call == nil || call.Name != "banking__get_balance" || call.Arguments["accountId"] != "555" {
+        t.Fatalf("ChatTools() = %+v, %v", call, err)
+    }
+    tools, _ := got["tools"].([]any)
+    if len(tools) != 1 || got["stream"] != false {
+        t.Fatalf("unexpected request: %v", got)
+    }
+}
+
+func TestChatTools_ModelWithoutTools(t *testing.T) {
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        http.Error(w, `{"error":"registry.ollama.ai/library/gemma:2b does not support tools"}`, http.StatusBadRequest)
+    }))
+    defer ts.Close()
+
+    c := NewOllamaClient(ts.URL, "gemma:2b")
+    if _, err := c.ChatTools(context.Background(), "x", nil); !errors.Is(err, ErrToolsUnsupported) {
+        t.Fatalf("expected ErrToolsUnsupported, got %v", err)
+    }
+}
@@ -22,6 +22,8 @@ type OpenAIClient struct {
 
 // Compile-time interface conformance
 var _ LLMClient = (*OpenAIClient)(nil)
+var _ JSONClient = (*OpenAIClient)(nil)
+var _ ToolClient = (*OpenAIClient)(nil)
 
 // NewOpenAIClient crea un nuevo proveedor OpenAI.
 func NewOpenAIClient(baseURL, apiKey, model string) *OpenAIClient {
@@ -74,24 +76,40 @@ func (c *OpenAIClient) Ping(ctx context.Context) error {
         return httpClient.Do(req)
     })
     if err != nil {
-        metrics.LLMPings.Inc(map[string]string{"provider": "openai", "outcome": "error"})
+        metrics.LLMPings.Inc(metricLabels(ctx, "openai", "error"))
         return fmt.Errorf("openai ping failed: %w", err)
     }
     defer resp.Body.Close()
 
     if resp.StatusCode != http.StatusOK {
         b, _ := io.ReadAll(resp.Body)
-        metrics.LLMPings.Inc(map[string]string{"provider": "openai", "outcome": "error"})
+        metrics.LLMPings.Inc(metricLabels(ctx, "openai", "error"))
         return fmt.Errorf("openai ping bad status: %d, body: %s", resp.StatusCode, string(b))
     }
 
-    metrics.LLMPings.Inc(map[string]string{"provider": "openai", "outcome": "ok"})
+    metrics.LLMPings.Inc(metricLabels(ctx, "openai", "ok"))
     return nil
 
 }
 
 // Chat llama al modelo de OpenAI en modo no-stream
 func (c *OpenAIClient) Chat(ctx context.Context, prompt string) (string, error) {
+    return c.chat(ctx, prompt, nil)
+}
+
+// ChatJSON pide la respuesta como JSON que cumple el schema
+// (response_format json_schema en modo strict).
+func (c *OpenAIClient) ChatJSON(ctx context.Context, prompt string, schema map[string]any) (string, error) {
+    if schema == nil {
+        return c.chat(ctx, prompt, map[string]any{"type": "json_object"})
+    }
+    return c.chat(ctx, prompt, map[string]any{
+        "type": "json_schema",
+        "json_schema": map[string]any{"name": "params", "strict": true, "schema": schema},
+    })
+}
+
+func (c *OpenAIClient) chat(ctx context.Context, prompt string, responseFormat map[string]any) (string, error) {
     if c.APIKey == "" {
         return "", fmt.Errorf("openai api key is empty")
     }
@@ -103,6 +121,9 @@ func (c *OpenAIClient) Chat(ctx context.Context, prompt string) (string, error)
         },
         "temperature": 0,
     }
+    if responseFormat != nil {
+        payload["response_format"] = responseFormat
+    }
 
     body, err := json.Marshal(payload)
     if err != nil {
@@ -138,14 +159,14 @@ func (c *OpenAIClient) Chat(ctx context.Context, prompt string) (string, error)
         return httpClient.Do(req)
     })
     if err != nil {
-        metrics.LLMChats.Inc(map[string]string{"provider": "openai", "outcome": "error"})
+        metrics.LLMChats.Inc(metricLabels(ctx, "openai", "error"))
         return "", err
     }
     defer resp.Body.Close()
 
     if resp.StatusCode != http.StatusOK {
         b, _ := io.ReadAll(resp.Body)
-        metrics.LLMChats.Inc(map[string]string{"provider": "openai", "outcome": "error"})
+        metrics.LLMChats.Inc(metricLabels(ctx, "openai", "error"))
         return "", fmt.Errorf("openai chat failed: status %d, body: %s", resp.StatusCode, string(b))
     }
 
@@ -158,17 +179,132 @@ func (c *OpenAIClient) Chat(ctx context.Context, prompt string) (string, error)
     }
 
     if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-        metrics.LLMChats.Inc(map[string]string{"provider": "openai", "outcome": "error"})
+        metrics.LLMChats.Inc(metricLabels(ctx, "openai", "error"))
         return "", err
     }
 
     if len(result.Choices) == 0 {
-        metrics.LLMChats.Inc(map[string]string{"provider": "openai", "outcome": "error"})
+        metrics.LLMChats.Inc(metricLabels(ctx, "openai", "error"))
         return "", fmt.Errorf("openai: empty response")
     }
 
-    metrics.LLMChats.Inc(map[string]string{"provider": "openai", "outcome": "ok"})
-    metrics.LLMChatDur.Observe(map[string]string{"provider": "openai", "outcome": "ok"}, time.Since(start).Seconds())
+    metrics.LLMChats.Inc(metricLabels(ctx, "openai", "ok"))
+    metrics.LLMChatDur.Observe(metricLabels(ctx, "openai", "ok"), time.Since(start).Seconds())
     return result.Choices[0].Message.Content, nil
 
 }
+
+// ChatTools ofrece las funciones al modelo (tools, tool_choice required) y
+// devuelve la primera que llama.
+func (c *OpenAIClient) ChatTools(ctx context.Context, prompt string, tools []ToolSpec) (*ToolCall, error) {
+	if c.APIKey == "" {
+		return nil, fmt.Errorf("openai api key is empty")
+	}
+	defs := make([]map[string]any, 0, len(tools))
+	for _, t := range tools {
+		defs = append(defs, map[string]any{
+			"type":     "function",
+			"function": map[string]any{"name": t.Name, "description": t.Description, "parameters": t.Parameters},
+		})
+	}
+	body, err := json.Marshal(map[string]any{
+		"model":       c.Model,
+		"messages":    []map[string]string{{"role": "user", "content": prompt}},
+		"tools":       defs,
+		"tool_choice": "required",
+		"temperature": 0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal payload: %w", err)
+	}
+
+	to := c.Timeout
+	if to <= 0 {
+		to = 30 * time.Second
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, to)
+	defer cancel()
+	url := strings.TrimRight(c.BaseURL, "/") + "/chat/completions"
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: to}
+	}
+
+	start := time.Now()
+	resp, err := retryHTTP(ctx, 3, 100*time.Millisecond, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+		req.Header.Set("Content-Type", "application/json")
+		return httpClient.Do(req)
+	})
+	if err != nil {
+		metrics.LLMChats.Inc(metricLabels(ctx, "openai", "error"))
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		metrics.LLMChats.Inc(metricLabels(ctx, "openai", "error"))
+		if resp.StatusCode == http.StatusBadRequest && openAIToolsUnsupported(b) {
+			return nil, fmt.Errorf("%w: %s", ErrToolsUnsupported, c.Model)
+		}
+		return nil, fmt.Errorf("openai chat failed: status %d, body: %s", resp.StatusCode, string(b))
+	}
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				ToolCalls []struct {
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"` // JSON codificado
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		metrics.LLMChats.Inc(metricLabels(ctx, "openai", "error"))
+		return nil, err
+	}
+	metrics.LLMChats.Inc(metricLabels(ctx, "openai", "ok"))
+	metrics.LLMChatDur.Observe(metricLabels(ctx, "openai", "ok"), time.Since(start).Seconds())
+	if len(result.Choices) == 0 || len(result.Choices[0].Message.ToolCalls) == 0 {
+		return nil, nil
+	}
+	fn := result.Choices[0].Message.ToolCalls[0].Function
+	call := &ToolCall{Name: fn.Name, Arguments: map[string]any{}}
+	if strings.TrimSpace(fn.Arguments) != "" {
+		if err := json.Unmarshal([]byte(fn.Arguments), &call.Arguments); err != nil {
+			return nil, fmt.Errorf("openai: argumentos inválidos de %s: %w", fn.Name, err)
+		}
+	}
+	return call, nil
+}
+
+// openAIToolsUnsupported reports whether a 400 body rejects function
+// calling: OpenAI names the unsupported parameter in error.param, while
+// compatible servers usually just say so in the message.
+func openAIToolsUnsupported(body []byte) bool {
+	var e struct {
+		Error struct {
+			Message string `json:"message"`
+			Param   string `json:"param"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &e)
+	if e.Error.Param == "tools" || e.Error.Param == "tool_choice" {
+		return true
+	}
+	msg := strings.ToLower(e.Error.Message)
+	if msg == "" {
+		msg = strings.ToLower(string(body))
+	}
+	return strings.Contains(msg, "tool") && (strings.Contains(msg, "not support") || strings.Contains(msg, "unsupported"))
+}
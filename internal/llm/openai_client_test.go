@@ -191,3 +191,108 @@ func indexOf(s, substr string) int {
     }
     return -1
 }
+
+func TestOpenAI_ChatJSON_SendsResponseFormat(t *testing.T) {
+    var got struct {
+        ResponseFormat struct {
+            Type       string `json:"type"`
+            JSONSchema struct {
+                Name   string         `json:"name"`
+                Strict bool           `json:"strict"`
+                Schema map[string]any `json:"schema"`
+            } `json:"json_schema"`
+        } `json:"response_format"`
+    }
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        _ = json.NewDecoder(r.Body).Decode(&got)
+        _, _ = w.Write([]byte(`{"choices":[{"message":{"content":"{\"a\":\"1\"}"}}]}`))
+    }))
+    defer ts.Close()
+
+    c := NewOpenAIClient(ts.URL, "key", "gpt-4.1")
+    out, err := c.ChatJSON(context.Background(), "x", map[string]any{"type": "object"})
+    if err != nil || out != `{"a":"1"}` {
+        t.Fatalf("ChatJSON() = %q, %v", out, err)
+    }
+    rf := got.ResponseFormat
+    if rf.Type != "json_schema" || !rf.JSONSchema.Strict || rf.JSONSchema.Schema["type"] != "object" {
+        t.Fatalf("unexpected response_format: %+v", rf)
+    }
+}
+
+func TestOpenAI_ChatTools_ParsesToolCall(t *testing.T) {
+    var got map[string]any
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        _ = json.NewDecoder(r.Body).Decode(&got)
+        _, _ = w.Write([]byte(`{"choices":[{"message":{"tool_calls":[{"type":"function","function":{"name":"banking__send_bizum","arguments":"{\"amount\":\"20\"}"}}]}}]}`))
+    }))
+    defer ts.Close()
+
+    c := NewOpenAIClient(ts.URL, "key", "gpt-4.1")
+    call, err := c.ChatTools(context.Background(), "x", []ToolSpec{{Name: "banking__send_bizum", Parameters: map[string]any{"type": "object"}}})
+    if err != nil || call == nil || call.Name != "banking__send_bizum" || call.Arguments["amount"] != "20" {
+        t.Fatalf("ChatTools() = %+v, %v", call, err)
+    }
+    if got["tool_choice"] != "required" {
+        t.Fatalf("expected tool_choice required, got %v", got["tool_choice"])
+    }
+    tools, _ := got["tools"].([]any)
+    fn := tools[0].(map[string]any)["function"].(map[string]any)
+    if fn["name"] != "banking__send_bizum" {
+        t.Fatalf("unexpected tools: %v", tools)
+    }
+}
+
+func TestOpenAI_ChatTools_BadArguments(t *testing.T) {
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        _, _ = w.Write([]byte(`{"choices":[{"message":{"tool_calls":[{"function":{"name":"f","arguments":"{nope"}}]}}]}`))
+    }))
+    defer ts.Close()
+
+    c := NewOpenAIClient(ts.URL, "key", "gpt-4.1")
+    if _, err := c.ChatTools(context.Background(), "x", nil); err == nil {
+        t.Fatalf("expected error for invalid arguments JSON")
+    }
+}
+
+func TestOpenAI_ChatTools_ModelWithoutTools(t *testing.T) {
+    bodies := []string{
+        `{"error":{"message":"Unsupported parameter: 'tools' is not supported with this model.","type":"invalid_request_error","param":"tools","code":"unsupported_parameter"}}`,
+        `{"error":{"message":"this model does not support tools","type":"invalid_request_error"}}`,
+        `tools are not supported by this server`,
+    }
+    for _, body := range bodies {
+        ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            http.Error(w, body, http.StatusBadRequest)
+        }))
+        c := NewOpenAIClient(ts.URL, "key", "o1-mini")
+        _, err := c.ChatTools(context.Background(), "x", nil)
+        ts.Close()
+        if !errors.Is(err, ErrToolsUnsupported) || !contains(err.Error(), "o1-mini") {
+            t.Fatalf("body %s: expected ErrToolsUnsupported, got %v", body, err)
+        }
+    }
+
+    // otros 400 siguen siendo errores del provider
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        http.Error(w, `{"error":{"message":"maximum context length exceeded","param":"messages"}}`, http.StatusBadRequest)
+    }))
+    defer ts.Close()
+    c := NewOpenAIClient(ts.URL, "key", "gpt-4.1")
+    if _, err := c.ChatTools(context.Background(), "x", nil); err == nil || errors.Is(err, ErrToolsUnsupported) {
+        t.Fatalf("expected a plain error, got %v", err)
+    }
+
+    // un modelo sin tools no marca el provider como caído
+    unsupported := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        http.Error(w, bodies[0], http.StatusBadRequest)
+    }))
+    defer unsupported.Close()
+    f := NewFallbackClient(time.Minute, NamedClient{Name: "openai", Client: NewOpenAIClient(unsupported.URL, "key", "o1-mini")})
+    if _, err := f.ChatTools(context.Background(), "x", nil); !errors.Is(err, ErrToolsUnsupported) {
+        t.Fatalf("expected ErrToolsUnsupported through the fallback, got %v", err)
+    }
+    if !f.Status()[0].Healthy {
+        t.Fatalf("a model without tool calling must not mark the provider unhealthy")
+    }
+}
@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Provider names accepted by NewProvider and LLM_PROVIDER.
+const (
+	ProviderOllama    = "ollama"
+	ProviderOpenAI    = "openai" // any OpenAI-compatible endpoint
+	ProviderAnthropic = "anthropic"
+)
+
+// ProviderConfig is what a provider needs to be built. Fields a provider
+// does not use are ignored.
+type ProviderConfig struct {
+	Name    string
+	BaseURL string
+	APIKey  string
+	Model   string
+	Timeout time.Duration // 0 = provider default
+}
+
+// NewProvider builds the client of one provider.
+func NewProvider(c ProviderConfig) (LLMClient, error) {
+	switch strings.ToLower(strings.TrimSpace(c.Name)) {
+	case ProviderOllama:
+		client := NewOllamaClient(c.BaseURL, c.Model)
+		if c.Timeout > 0 {
+			client.HTTPClient.Timeout = c.Timeout
+		}
+		return client, nil
+	case ProviderOpenAI:
+		if c.APIKey == "" {
+			return nil, fmt.Errorf("llm: provider %s requiere LLM_API_KEY", c.Name)
+		}
+		client := NewOpenAIClient(c.BaseURL, c.APIKey, c.Model)
+		if c.Timeout > 0 {
+			client.Timeout = c.Timeout
+			client.HTTP.Timeout = c.Timeout
+		}
+		return client, nil
+	case ProviderAnthropic:
+		if c.APIKey == "" {
+			return nil, fmt.Errorf("llm: provider %s requiere ANTHROPIC_API_KEY", c.Name)
+		}
+		client := NewAnthropicClient(c.BaseURL, c.APIKey, c.Model)
+		if c.Timeout > 0 {
+			client.Timeout = c.Timeout
+			client.HTTP.Timeout = c.Timeout
+		}
+		return client, nil
+	}
+	return nil, fmt.Errorf("llm: provider desconocido %q (ollama, openai, anthropic)", c.Name)
+}
+
+// ParseProviders splits an LLM_PROVIDER value ("ollama,openai") into
+// provider names, in order and without duplicates.
+func ParseProviders(s string) ([]string, error) {
+	var out []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("llm: provider %s repetido", name)
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("llm: ningún provider en %q", s)
+	}
+	return out, nil
+}
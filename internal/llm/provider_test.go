@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewProvider(t *testing.T) {
+	c, err := NewProvider(ProviderConfig{Name: "ollama", BaseURL: "http://ollama", Model: "qwen3"})
+	if o, ok := c.(*OllamaClient); err != nil || !ok || o.BaseURL != "http://ollama" || o.Model != "qwen3" {
+		t.Fatalf("expected an OllamaClient, got %#v err=%v", c, err)
+	}
+
+	c, err = NewProvider(ProviderConfig{Name: "OpenAI", BaseURL: "http://vllm/v1", APIKey: "k", Model: "m", Timeout: 5 * time.Second})
+	if o, ok := c.(*OpenAIClient); err != nil || !ok || o.BaseURL != "http://vllm/v1" || o.Timeout != 5*time.Second {
+		t.Fatalf("expected an OpenAIClient, got %#v err=%v", c, err)
+	}
+
+	if _, err := NewProvider(ProviderConfig{Name: "openai"}); err == nil || !strings.Contains(err.Error(), "LLM_API_KEY") {
+		t.Fatalf("expected missing api key error, got %v", err)
+	}
+	if _, err := NewProvider(ProviderConfig{Name: "llama"}); err == nil {
+		t.Fatalf("expected unknown provider error")
+	}
+}
+
+func TestParseProviders(t *testing.T) {
+	got, err := ParseProviders(" Ollama, openai ")
+	if err != nil || strings.Join(got, ",") != "ollama,openai" {
+		t.Fatalf("unexpected %v err=%v", got, err)
+	}
+	for _, bad := range []string{"", " , ", "ollama,ollama"} {
+		if _, err := ParseProviders(bad); err == nil {
+			t.Fatalf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestNewProvider_Anthropic(t *testing.T) {
+	c, err := NewProvider(ProviderConfig{Name: "anthropic", APIKey: "k", Model: "claude-sonnet-4-5", Timeout: 5 * time.Second})
+	if a, ok := c.(*AnthropicClient); err != nil || !ok || a.BaseURL != "https://api.anthropic.com" || a.HTTP.Timeout != 5*time.Second {
+		t.Fatalf("expected an AnthropicClient, got %#v err=%v", c, err)
+	}
+	if _, err := NewProvider(ProviderConfig{Name: "anthropic"}); err == nil || !strings.Contains(err.Error(), "ANTHROPIC_API_KEY") {
+		t.Fatalf("expected missing api key error, got %v", err)
+	}
+}
@@ -2,10 +2,11 @@ package llm
 
 import (
     "context"
-    "math/rand"
     "net"
     "net/http"
     "time"
+
+    "github.com/ccastromar/aos-agent-orchestration-system/internal/retry"
 )
 
 // retryHTTP wraps an operation with small exponential backoff retries for transient failures.
@@ -57,22 +58,9 @@ func retryHTTP(ctx context.Context, maxAttempts int, baseDelay time.Duration, op
             return resp, err
         }
 
-        // backoff with jitter
-        delay := baseDelay << (attempt - 1) // 100ms, 200ms, 400ms...
-        // cap delay to 1s to keep tests fast
-        if delay > time.Second {
-            delay = time.Second
-        }
-        // add jitter +/- 20%
-        jitter := time.Duration(rand.Int63n(int64(delay/5)))
-        delay = delay - delay/10 + jitter
-
-        timer := time.NewTimer(delay)
-        select {
-        case <-ctx.Done():
-            timer.Stop()
-            return nil, ctx.Err()
-        case <-timer.C:
+        // backoff with jitter, capped to 1s to keep tests fast
+        if err := retry.Wait(ctx, retry.Delay(baseDelay, attempt, time.Second)); err != nil {
+            return nil, err
         }
     }
     return nil, lastErr
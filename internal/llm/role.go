@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Role is what an LLM call is for. DetectIntent, ExtractParams and the
+// Summarize helpers tag their context with one, so a Router can send each
+// role to its own client and the aos_llm_* metrics are labelled with it.
+type Role string
+
+const (
+	RoleDefault Role = "default"
+	RoleIntent  Role = "intent"  // DetectIntent
+	RoleParams  Role = "params"  // ExtractParams, ExtractParamsWithHints
+	RoleSummary Role = "summary" // SummarizeResult, SummarizeShadowResult
+)
+
+// Roles lists the roles a Router can route, in a stable order.
+var Roles = []Role{RoleIntent, RoleParams, RoleSummary}
+
+type roleKey struct{}
+
+// WithRole returns ctx tagged with role.
+func WithRole(ctx context.Context, role Role) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFrom returns the role of ctx, RoleDefault when it has none.
+func RoleFrom(ctx context.Context) Role {
+	if ctx != nil {
+		if r, ok := ctx.Value(roleKey{}).(Role); ok && r != "" {
+			return r
+		}
+	}
+	return RoleDefault
+}
+
+// metricLabels are the labels of the aos_llm_* metrics of one call.
+func metricLabels(ctx context.Context, provider, outcome string) map[string]string {
+	return map[string]string{"provider": provider, "outcome": outcome, "role": string(RoleFrom(ctx))}
+}
+
+// Router sends each call to the client of the role of its context, or to
+// the default client when the role has none.
+type Router struct {
+	def    LLMClient
+	routes map[Role]LLMClient
+}
+
+var (
+	_ LLMClient  = (*Router)(nil)
+	_ JSONClient = (*Router)(nil)
+	_ ToolClient = (*Router)(nil)
+)
+
+// NewRouter builds a Router. Nil routes are ignored.
+func NewRouter(def LLMClient, routes map[Role]LLMClient) *Router {
+	r := &Router{def: def, routes: map[Role]LLMClient{}}
+	for role, c := range routes {
+		if c != nil {
+			r.routes[role] = c
+		}
+	}
+	return r
+}
+
+// For returns the client that serves role.
+func (r *Router) For(role Role) LLMClient {
+	if c, ok := r.routes[role]; ok {
+		return c
+	}
+	return r.def
+}
+
+// Chat sends the prompt to the client of the role of ctx.
+func (r *Router) Chat(ctx context.Context, prompt string) (string, error) {
+	return r.For(RoleFrom(ctx)).Chat(ctx, prompt)
+}
+
+// ChatJSON sends the prompt in JSON mode to the client of the role of ctx.
+func (r *Router) ChatJSON(ctx context.Context, prompt string, schema map[string]any) (string, error) {
+	return chatJSON(ctx, r.For(RoleFrom(ctx)), prompt, schema)
+}
+
+// ChatTools uses the function calling of the client of the role of ctx.
+func (r *Router) ChatTools(ctx context.Context, prompt string, tools []ToolSpec) (*ToolCall, error) {
+	return chatTools(ctx, r.For(RoleFrom(ctx)), prompt, tools)
+}
+
+// Ping pings every distinct client once and fails if any of them fails,
+// since each role needs its own.
+func (r *Router) Ping(ctx context.Context) error {
+	var errs []error
+	seen := map[LLMClient]bool{}
+	for _, role := range append([]Role{RoleDefault}, Roles...) {
+		c := r.For(role)
+		if c == nil || seen[c] {
+			continue
+		}
+		seen[c] = true
+		if err := c.Ping(WithRole(ctx, role)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", role, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/metrics"
+)
+
+// roleRecorder answers with a fixed reply and records the role of each call.
+type roleRecorder struct {
+	reply   string
+	roles   []Role
+	pings   int
+	pingErr error
+}
+
+func (r *roleRecorder) Chat(ctx context.Context, prompt string) (string, error) {
+	r.roles = append(r.roles, RoleFrom(ctx))
+	return r.reply, nil
+}
+
+func (r *roleRecorder) Ping(ctx context.Context) error {
+	r.pings++
+	return r.pingErr
+}
+
+func TestRoleFrom(t *testing.T) {
+	if got := RoleFrom(context.Background()); got != RoleDefault {
+		t.Fatalf("expected default role, got %q", got)
+	}
+	if got := RoleFrom(WithRole(context.Background(), RoleParams)); got != RoleParams {
+		t.Fatalf("expected params role, got %q", got)
+	}
+}
+
+func TestRouter_RoutesHelpersByRole(t *testing.T) {
+	def := &roleRecorder{reply: "resumen"}
+	intent := &roleRecorder{reply: "banking.get_balance"}
+	params := &roleRecorder{reply: `{"accountId":"555"}`}
+	r := NewRouter(def, map[Role]LLMClient{RoleIntent: intent, RoleParams: params, RoleSummary: nil})
+	ctx := context.Background()
+
+	di, err := DetectIntent(ctx, r, "saldo de la 555", map[string]any{"banking.get_balance": true})
+	if err != nil || di.Type != "banking.get_balance" {
+		t.Fatalf("DetectIntent: %+v err=%v", di, err)
+	}
+	got, err := ExtractParams(ctx, r, "saldo de la 555", []string{"accountId"})
+	if err != nil || got["accountId"] != "555" {
+		t.Fatalf("ExtractParams: %v err=%v", got, err)
+	}
+	if out, err := SummarizeResult(ctx, r, "banking.get_balance", map[string]any{}); err != nil || out != "resumen" {
+		t.Fatalf("SummarizeResult: %q err=%v", out, err)
+	}
+
+	if len(intent.roles) != 1 || intent.roles[0] != RoleIntent {
+		t.Fatalf("intent client got %v", intent.roles)
+	}
+	if len(params.roles) != 1 || params.roles[0] != RoleParams {
+		t.Fatalf("params client got %v", params.roles)
+	}
+	// summary has no route (nil is ignored): it goes to the default client
+	if len(def.roles) != 1 || def.roles[0] != RoleSummary {
+		t.Fatalf("default client got %v", def.roles)
+	}
+}
+
+func TestRouter_PingsEachClientOnce(t *testing.T) {
+	def := &roleRecorder{}
+	strong := &roleRecorder{pingErr: errors.New("down")}
+	r := NewRouter(def, map[Role]LLMClient{RoleParams: strong, RoleSummary: strong})
+
+	err := r.Ping(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "params: down") {
+		t.Fatalf("expected params ping error, got %v", err)
+	}
+	if def.pings != 1 || strong.pings != 1 {
+		t.Fatalf("each client must be pinged once, got def=%d strong=%d", def.pings, strong.pings)
+	}
+}
+
+func TestRole_LabelsProviderMetrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":{"role":"assistant","content":"ok"},"done":true}` + "\n"))
+	}))
+	defer ts.Close()
+
+	if _, err := SummarizeResult(context.Background(), NewOllamaClient(ts.URL, "qwen3:0.6b"), "x.y", nil); err != nil {
+		t.Fatalf("SummarizeResult: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	want := `aos_llm_chats_total{outcome="ok",provider="ollama",role="summary"}`
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("missing %s in:\n%s", want, rec.Body.String())
+	}
+}
@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrToolsUnsupported is returned by ChatTools when the provider (or the
+// model) has no function calling; callers fall back to DetectIntent.
+var ErrToolsUnsupported = errors.New("llm: el provider no soporta tool calling")
+
+// ToolSpec is a function offered to the model: its name, what it does and
+// the JSON schema of its arguments.
+type ToolSpec struct {
+	Name        string
+	Description string
+	Parameters  map[string]any
+}
+
+// ToolCall is the function the model chose and its arguments.
+type ToolCall struct {
+	Name      string
+	Arguments map[string]any
+}
+
+// ToolClient is implemented by providers with native function calling.
+// ChatTools returns the first function the model calls.
+type ToolClient interface {
+	ChatTools(ctx context.Context, prompt string, tools []ToolSpec) (*ToolCall, error)
+}
+
+// chatTools uses the function calling of c, or fails with
+// ErrToolsUnsupported when it has none.
+func chatTools(ctx context.Context, c LLMClient, prompt string, tools []ToolSpec) (*ToolCall, error) {
+	if tc, ok := c.(ToolClient); ok {
+		return tc.ChatTools(ctx, prompt, tools)
+	}
+	return nil, ErrToolsUnsupported
+}
+
+// IntentTool describes an intent for DetectIntentWithTools.
+type IntentTool struct {
+	Intent      string
+	Description string
+	Params      []ParamHint
+}
+
+// DetectIntentWithTools offers every intent to the model as a function, so
+// the intent and its params come back in a single call. Params is nil when
+// some argument is invalid, meaning they have to be extracted separately
+// (ExtractParamsWithHints, with its repair round). It fails with
+// ErrToolsUnsupported when the client has no function calling.
+func DetectIntentWithTools(ctx context.Context, c LLMClient, text string, intents []IntentTool) (*DetectedIntent, error) {
+	tools := make([]ToolSpec, 0, len(intents))
+	byName := make(map[string]IntentTool, len(intents))
+	for _, it := range intents {
+		name := toolName(it.Intent)
+		byName[name] = it
+		schema := paramsSchema(it.Params)
+		// las funciones no exigen argumentos: los ausentes se piden luego
+		delete(schema, "required")
+		tools = append(tools, ToolSpec{Name: name, Description: it.Description, Parameters: schema})
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+
+	prompt := fmt.Sprintf(`
+You are the intent router of a multi-domain (banking, devops, CRM, Helpdesk) Agent Orchestration System (AOS).
+Call exactly ONE of the functions: the one that matches the user request.
+Fill only the arguments explicitly present in the message. NEVER invent or guess values.
+
+User message:
+"%s"
+`, text)
+
+	call, err := chatTools(WithRole(ctx, RoleIntent), c, prompt, tools)
+	if err != nil {
+		return nil, err
+	}
+	if call == nil {
+		return nil, errors.New("DetectIntentWithTools: el modelo no ha llamado a ninguna función")
+	}
+	it, ok := byName[call.Name]
+	if !ok {
+		return nil, fmt.Errorf("DetectIntentWithTools: función desconocida %q", call.Name)
+	}
+
+	params := map[string]string{}
+	for k, v := range call.Arguments {
+		if v == nil {
+			continue
+		}
+		if s := strings.TrimSpace(fmt.Sprintf("%v", v)); s != "" {
+			params[k] = s
+		}
+	}
+	if problems := validateParams(params, it.Params); len(problems) > 0 {
+		return &DetectedIntent{Type: it.Intent}, nil
+	}
+	return &DetectedIntent{Type: it.Intent, Params: params}, nil
+}
+
+// toolName turns an intent key into a valid function name
+// (^[a-zA-Z0-9_-]{1,64}$): banking.get_balance -> banking__get_balance.
+func toolName(intent string) string {
+	return strings.ReplaceAll(intent, ".", "__")
+}
@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// toolScript answers ChatTools with a fixed call and keeps the tools it got.
+type toolScript struct {
+	call  *ToolCall
+	err   error
+	tools []ToolSpec
+	role  Role
+}
+
+func (s *toolScript) Ping(ctx context.Context) error { return nil }
+func (s *toolScript) Chat(ctx context.Context, prompt string) (string, error) {
+	return "", errors.New("Chat must not be used")
+}
+func (s *toolScript) ChatTools(ctx context.Context, prompt string, tools []ToolSpec) (*ToolCall, error) {
+	s.tools, s.role = tools, RoleFrom(ctx)
+	return s.call, s.err
+}
+
+func bizumTools() []IntentTool {
+	return []IntentTool{
+		{Intent: "banking.get_balance", Description: "Saldo de una cuenta", Params: []ParamHint{{Name: "accountId"}}},
+		{Intent: "banking.send_bizum", Description: "Envía un bizum", Params: amountHints()},
+	}
+}
+
+func TestDetectIntentWithTools(t *testing.T) {
+	c := &toolScript{call: &ToolCall{Name: "banking__send_bizum", Arguments: map[string]any{"amount": 20, "kind": "bizum"}}}
+	di, err := DetectIntentWithTools(context.Background(), c, "20 euros por bizum", bizumTools())
+	if err != nil {
+		t.Fatalf("DetectIntentWithTools: %v", err)
+	}
+	if di.Type != "banking.send_bizum" || di.Params["amount"] != "20" || di.Params["kind"] != "bizum" {
+		t.Fatalf("unexpected %+v", di)
+	}
+	if c.role != RoleIntent {
+		t.Fatalf("expected intent role, got %q", c.role)
+	}
+
+	if len(c.tools) != 2 || c.tools[0].Name != "banking__get_balance" || c.tools[1].Description != "Envía un bizum" {
+		t.Fatalf("unexpected tools %+v", c.tools)
+	}
+	if _, ok := c.tools[1].Parameters["required"]; ok {
+		t.Fatalf("function arguments must be optional: %v", c.tools[1].Parameters)
+	}
+	if _, ok := c.tools[1].Parameters["properties"].(map[string]any)["amount"]; !ok {
+		t.Fatalf("missing amount in %v", c.tools[1].Parameters)
+	}
+}
+
+func TestDetectIntentWithTools_InvalidArgumentsAreExtractedAgain(t *testing.T) {
+	c := &toolScript{call: &ToolCall{Name: "banking__send_bizum", Arguments: map[string]any{"amount": "veinte"}}}
+	di, err := DetectIntentWithTools(context.Background(), c, "veinte euros", bizumTools())
+	if err != nil || di.Type != "banking.send_bizum" || di.Params != nil {
+		t.Fatalf("expected the intent without params, got %+v err=%v", di, err)
+	}
+}
+
+func TestDetectIntentWithTools_Errors(t *testing.T) {
+	ctx := context.Background()
+	if _, err := DetectIntentWithTools(ctx, &jsonScript{}, "hola", bizumTools()); !errors.Is(err, ErrToolsUnsupported) {
+		t.Fatalf("expected ErrToolsUnsupported, got %v", err)
+	}
+	if _, err := DetectIntentWithTools(ctx, &toolScript{}, "hola", bizumTools()); err == nil {
+		t.Fatalf("expected error when no function is called")
+	}
+	c := &toolScript{call: &ToolCall{Name: "crm__delete_all"}}
+	if _, err := DetectIntentWithTools(ctx, c, "hola", bizumTools()); err == nil || !strings.Contains(err.Error(), "crm__delete_all") {
+		t.Fatalf("expected unknown function error, got %v", err)
+	}
+}
+
+func TestFallbackClient_ChatToolsSkipsUnsupportedProvider(t *testing.T) {
+	plain := &fakeProvider{name: "plain"}
+	tools := &toolScript{call: &ToolCall{Name: "x"}}
+	f := NewFallbackClient(time.Minute, NamedClient{Name: "plain", Client: plain}, NamedClient{Name: "tools", Client: tools})
+
+	call, err := f.ChatTools(context.Background(), "hola", nil)
+	if err != nil || call.Name != "x" {
+		t.Fatalf("expected the call of the second provider, got %+v err=%v", call, err)
+	}
+	if !f.Status()[0].Healthy {
+		t.Fatalf("a provider without tool calling must stay healthy")
+	}
+}
+
+func TestRouter_ChatToolsUsesIntentClient(t *testing.T) {
+	intent := &toolScript{call: &ToolCall{Name: "banking__get_balance"}}
+	r := NewRouter(&fakeProvider{name: "plain"}, map[Role]LLMClient{RoleIntent: intent})
+	di, err := DetectIntentWithTools(context.Background(), r, "saldo", bizumTools())
+	if err != nil || di.Type != "banking.get_balance" {
+		t.Fatalf("unexpected %+v err=%v", di, err)
+	}
+}
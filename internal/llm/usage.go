@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ccastromar/aos-agent-orchestration-system/internal/metrics"
+)
+
+// Usage accumulates the tokens of the calls made with a context returned by
+// WithUsage. Providers that do not report usage leave it untouched.
+type Usage struct {
+	mu     sync.Mutex
+	input  int
+	output int
+}
+
+// Tokens returns the input and output tokens counted so far.
+func (u *Usage) Tokens() (input, output int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.input, u.output
+}
+
+func (u *Usage) add(input, output int) {
+	u.mu.Lock()
+	u.input += input
+	u.output += output
+	u.mu.Unlock()
+}
+
+type usageKey struct{}
+
+// WithUsage returns ctx with u as the sink of the token usage of its calls.
+func WithUsage(ctx context.Context, u *Usage) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, usageKey{}, u)
+}
+
+// recordUsage adds the tokens of one call to aos_llm_tokens_total and to
+// the Usage of ctx, if any.
+func recordUsage(ctx context.Context, provider string, input, output int) {
+	role := string(RoleFrom(ctx))
+	metrics.LLMTokens.Add(map[string]string{"provider": provider, "role": role, "kind": "input"}, float64(input))
+	metrics.LLMTokens.Add(map[string]string{"provider": provider, "role": role, "kind": "output"}, float64(output))
+	if u, ok := ctx.Value(usageKey{}).(*Usage); ok && u != nil {
+		u.add(input, output)
+	}
+}
@@ -53,6 +53,14 @@ func (cv *CounterVec) Inc(lbls map[string]string) {
     cv.mu.Unlock()
 }
 
+// Add increases the counter by v (e.g. a number of tokens).
+func (cv *CounterVec) Add(lbls map[string]string, v float64) {
+    key := makeKey(lbls)
+    cv.mu.Lock()
+    cv.values[key] += v
+    cv.mu.Unlock()
+}
+
 // SummaryVec stores count and sum; we export metric_count and metric_sum.
 type SummaryVec struct {
     Name string
@@ -82,9 +90,13 @@ var (
 
     BusMessages  = NewCounterVec("aos_bus_messages_total", "Bus messages by target and result", "target", "result") // result=sent|dropped
 
-    LLMPings     = NewCounterVec("aos_llm_pings_total", "LLM Ping calls", "provider", "outcome") // outcome=ok|error
-    LLMChats     = NewCounterVec("aos_llm_chats_total", "LLM Chat calls", "provider", "outcome")
-    LLMChatDur   = NewSummaryVec("aos_llm_chat_seconds", "LLM Chat duration seconds", "provider", "outcome")
+    // role=default|intent|params|summary (see llm.Role)
+    LLMPings     = NewCounterVec("aos_llm_pings_total", "LLM Ping calls", "provider", "outcome", "role") // outcome=ok|error
+    LLMChats     = NewCounterVec("aos_llm_chats_total", "LLM Chat calls", "provider", "outcome", "role")
+    LLMChatDur   = NewSummaryVec("aos_llm_chat_seconds", "LLM Chat duration seconds", "provider", "outcome", "role")
+    LLMFallbacks = NewCounterVec("aos_llm_fallbacks_total", "LLM calls handed to the next provider", "from", "to", "role")
+    LLMRepairs   = NewCounterVec("aos_llm_repairs_total", "ExtractParams replies sent back to the model to be fixed", "outcome") // outcome=ok|failed
+    LLMTokens    = NewCounterVec("aos_llm_tokens_total", "LLM tokens reported by the provider", "provider", "role", "kind") // kind=input|output
 )
 
 // ServeHTTP exposes all metrics in Prometheus text format.
@@ -130,4 +142,7 @@ func ServeHTTP(w http.ResponseWriter, r *http.Request) {
     dumpCounter(LLMPings)
     dumpCounter(LLMChats)
     dumpSummary(LLMChatDur)
+    dumpCounter(LLMFallbacks)
+    dumpCounter(LLMRepairs)
+    dumpCounter(LLMTokens)
 }
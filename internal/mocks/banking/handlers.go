@@ -2,8 +2,10 @@ package banking
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 )
 
 func RegisterHandlers(mux *http.ServeMux) {
@@ -11,6 +13,7 @@ func RegisterHandlers(mux *http.ServeMux) {
 	mux.HandleFunc("/mock/core/movements", getMovements)
 	mux.HandleFunc("/mock/core/creditcard", getCreditCard)
 	mux.HandleFunc("/mock/payments/bizum", postBizumPayment)
+	mux.HandleFunc("/mock/payments/bizum/reverse", postBizumReverse)
 	mux.HandleFunc("/mock/aml/check", postAmlCheck)
 	mux.HandleFunc("/mock/notifications/send", postSendNotification)
 }
@@ -68,11 +71,23 @@ func postBizumPayment(w http.ResponseWriter, r *http.Request) {
 	json.NewDecoder(r.Body).Decode(&body)
 	resp := map[string]any{
 		"status": "ok",
+		"txId":   fmt.Sprintf("BZ-%d", time.Now().UnixNano()),
 		"detail": body,
 	}
 	json.NewEncoder(w).Encode(resp)
 }
 
+func postBizumReverse(w http.ResponseWriter, r *http.Request) {
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	log.Println("[MOCK BIZUM REVERSE]", body)
+	resp := map[string]any{
+		"status": "reversed",
+		"txId":   body["txId"],
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
 func postAmlCheck(w http.ResponseWriter, r *http.Request) {
 	var body map[string]any
 	json.NewDecoder(r.Body).Decode(&body)
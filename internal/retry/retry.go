@@ -0,0 +1,48 @@
+// Package retry holds the backoff shared by the LLM clients and the
+// pipeline steps.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// DefaultMaxDelay caps the backoff when the caller does not set a limit.
+const DefaultMaxDelay = time.Second
+
+// Delay returns the wait before retrying after the given attempt (1-based):
+// exponential from base (100ms, 200ms, 400ms...), capped to max and with
+// +/- 10% jitter.
+func Delay(base time.Duration, attempt int, max time.Duration) time.Duration {
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = DefaultMaxDelay
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := base << (attempt - 1)
+	if delay > max || delay <= 0 {
+		delay = max
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay/5) + 1))
+	return delay - delay/10 + jitter
+}
+
+// Wait sleeps for d or until ctx is done, whichever happens first.
+func Wait(ctx context.Context, d time.Duration) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,28 @@
+package retry
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDelay_GrowsAndIsCapped(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond} {
+		d := Delay(base, attempt, time.Second)
+		if d < want*9/10 || d > want*11/10 {
+			t.Fatalf("attempt %d: delay %s out of range around %s", attempt, d, want)
+		}
+	}
+	if d := Delay(base, 10, 500*time.Millisecond); d > 550*time.Millisecond {
+		t.Fatalf("delay must be capped, got %s", d)
+	}
+}
+
+func TestWait_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Wait(ctx, time.Minute); err == nil {
+		t.Fatalf("expected context error")
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
@@ -38,58 +40,148 @@ func ExecuteTool(t config.Tool, params map[string]string) (map[string]any, error
     return ExecuteToolCtx(context.Background(), t, params)
 }
 
+// HTTPError es la respuesta no exitosa (>= 300) de una tool. Conserva el
+// código de estado para poder decidir reintentos.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("[HTTP %d] %s", e.StatusCode, e.Body)
+}
+
+// IsTimeout indica si el error de una tool se debe a un timeout.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+// RenderedRequest es la petición HTTP de una tool ya renderizada con los
+// parámetros, lista para enviarse (o para mostrarse antes de enviarla).
+type RenderedRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    map[string]string `json:"body,omitempty"`
+}
+
 // ExecuteToolCtx ejecuta una tool HTTP, renderizando la URL y el body con parámetros.
 func ExecuteToolCtx(ctx context.Context, t config.Tool, params map[string]string) (map[string]any, error) {
+	req, err := RenderRequest(t, params)
+	if err != nil {
+		return nil, err
+	}
+	return SendRequest(ctx, t, req)
+}
+
+// RenderRequest renderiza URL, headers y body de una tool sin ejecutarla.
+func RenderRequest(t config.Tool, params map[string]string) (RenderedRequest, error) {
+	rr, err := renderRequest(t, func(tpl string) (string, error) {
+		return RenderTemplateString(tpl, params)
+	})
+	if err != nil {
+		return RenderedRequest{}, err
+	}
+
+	// 2b. Renderizar headers (opcional)
+	rr.Headers, err = RenderTemplateMap(t.Headers, params)
+	if err != nil {
+		return RenderedRequest{}, fmt.Errorf("error renderizando headers: %w", err)
+	}
+	return rr, nil
+}
+
+// RenderRequestWithSteps renderiza la petición exponiendo además las salidas
+// de los steps previos bajo .steps (ver TemplateData).
+func RenderRequestWithSteps(t config.Tool, params map[string]string, steps map[string]any) (RenderedRequest, error) {
+	data := TemplateData(params, steps)
+	rr, err := renderRequest(t, func(tpl string) (string, error) {
+		return RenderTemplateData(tpl, data)
+	})
+	if err != nil {
+		return RenderedRequest{}, err
+	}
+
+	rr.Headers = map[string]string{}
+	for k, v := range t.Headers {
+		rendered, err := RenderTemplateData(v, data)
+		if err != nil {
+			return RenderedRequest{}, fmt.Errorf("error renderizando headers: %w", err)
+		}
+		rr.Headers[k] = rendered
+	}
+	return rr, nil
+}
+
+// renderRequest renderiza URL y body; las cabeceras las resuelve cada llamador.
+
+func renderRequest(t config.Tool, render func(string) (string, error)) (RenderedRequest, error) {
 
 	// 🔥 1. Renderizar la URL
-	finalURL, err := RenderTemplateString(t.URL, params)
+	finalURL, err := render(t.URL)
 	if err != nil {
-		return nil, fmt.Errorf("error renderizando URL: %w", err)
+		return RenderedRequest{}, fmt.Errorf("error renderizando URL: %w", err)
 	}
 
 	// 🔥 2. Renderizar el body
 	bodyParams := map[string]string{}
 	for k, v := range t.Body {
-		rendered, err := RenderTemplateString(v, params)
+		rendered, err := render(v)
 		if err != nil {
-			return nil, fmt.Errorf("error renderizando body: %w", err)
+			return RenderedRequest{}, fmt.Errorf("error renderizando body: %w", err)
 		}
 		bodyParams[k] = rendered
 	}
 
-	// 2b. Renderizar headers (opcional)
-	renderedHeaders, err := RenderTemplateMap(t.Headers, params)
-	if err != nil {
-		return nil, fmt.Errorf("error renderizando headers: %w", err)
-	}
+	return RenderedRequest{
+		Method: t.Method,
+		URL:    finalURL,
+		Body:   bodyParams,
+	}, nil
+}
+
+// SendRequest envía una petición ya renderizada respetando el timeout de la tool.
+func SendRequest(ctx context.Context, t config.Tool, rr RenderedRequest) (map[string]any, error) {
+	out, _, err := SendRequestStatus(ctx, t, rr)
+	return out, err
+}
+
+// SendRequestStatus es SendRequest devolviendo también el código HTTP de la
+// respuesta (0 si no la hubo).
+func SendRequestStatus(ctx context.Context, t config.Tool, rr RenderedRequest) (map[string]any, int, error) {
+	var err error
 
 	// 3. Serializar body
 	var payload []byte
-	if len(bodyParams) > 0 {
-		payload, err = json.Marshal(bodyParams)
+	if len(rr.Body) > 0 {
+		payload, err = json.Marshal(rr.Body)
 		if err != nil {
-			return nil, fmt.Errorf("error serializando body JSON: %w", err)
+			return nil, 0, fmt.Errorf("error serializando body JSON: %w", err)
 		}
 	} else {
 		payload = []byte("{}")
 	}
 
-	log.Printf("[Execute][DEBUG] finalURL=%s", finalURL)
+	log.Printf("[Execute][DEBUG] finalURL=%s", rr.URL)
 
 	// 4. Crear request
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	req, err := http.NewRequestWithContext(ctx, t.Method, finalURL, bytes.NewReader(payload))
+	req, err := http.NewRequestWithContext(ctx, rr.Method, rr.URL, bytes.NewReader(payload))
 	if err != nil {
-		return nil, fmt.Errorf("error creando request: %w", err)
+		return nil, 0, fmt.Errorf("error creando request: %w", err)
 	}
 	// Establecer cabeceras
 	// Content-Type por defecto si no se definió en headers
-	if _, ok := renderedHeaders["Content-Type"]; !ok {
+	if _, ok := rr.Headers["Content-Type"]; !ok {
 		req.Header.Set("Content-Type", "application/json")
 	}
-	for k, v := range renderedHeaders {
+	for k, v := range rr.Headers {
 		req.Header.Set(k, v)
 	}
 
@@ -109,27 +201,27 @@ func ExecuteToolCtx(ctx context.Context, t config.Tool, params map[string]string
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error ejecutando HTTP request: %w", err)
+		return nil, 0, fmt.Errorf("error ejecutando HTTP request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 6. Leer respuesta
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error leyendo respuesta: %w", err)
+		return nil, resp.StatusCode, fmt.Errorf("error leyendo respuesta: %w", err)
 	}
 
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("[HTTP %d] %s", resp.StatusCode, string(respBody))
+		return nil, resp.StatusCode, &HTTPError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	// 7. Parsear JSON
 	out := map[string]any{}
 	if len(respBody) > 0 {
 		if err := json.Unmarshal(respBody, &out); err != nil {
-			return nil, fmt.Errorf("error parseando JSON respuesta: %w", err)
+			return nil, resp.StatusCode, fmt.Errorf("error parseando JSON respuesta: %w", err)
 		}
 	}
 
-	return out, nil
+	return out, resp.StatusCode, nil
 }
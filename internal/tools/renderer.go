@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"text/template"
 )
 
@@ -41,6 +42,85 @@ func RenderTemplateString(tpl string, params map[string]string) (string, error)
 	return buf.String(), nil
 }
 
+// TemplateData combina los params planos con las salidas de los steps
+// previos, accesibles como {{ .steps.crm_create_ticket.ticketId }} o, si el
+// id del step lleva puntos, {{ (index .steps "banking.aml_risk_check").riskLevel }}.
+// Los null de las salidas se copian como cadena vacía.
+func TemplateData(params map[string]string, steps map[string]any) map[string]any {
+	data := make(map[string]any, len(params)+1)
+	for k, v := range params {
+		data[k] = v
+	}
+	normalized := make(map[string]any, len(steps))
+	for k, v := range steps {
+		normalized[k] = emptyNils(v)
+	}
+	data["steps"] = normalized
+	return data
+}
+
+// emptyNils copia v cambiando los nil por "", para que text/template no los
+// imprima como "<no value>".
+func emptyNils(v any) any {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, e := range x {
+			out[k] = emptyNils(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = emptyNils(e)
+		}
+		return out
+	}
+	return v
+}
+
+// RenderTemplateData procesa un template contra datos anidados (ver
+// TemplateData). Si el template no lee .steps se renderiza solo con los
+// params, como RenderTemplateString, y un param ausente queda vacío. Con
+// .steps, text/template imprime un campo ausente como "<no value>": para un
+// campo opcional usa orEmpty, {{ .steps.crm_create_ticket.owner | orEmpty }}.
+func RenderTemplateData(tpl string, data map[string]any) (string, error) {
+	if !strings.Contains(tpl, ".steps") {
+		params := make(map[string]string, len(data))
+		for k, v := range data {
+			if s, ok := v.(string); ok {
+				params[k] = s
+			}
+		}
+		return RenderTemplateString(tpl, params)
+	}
+
+	t, err := template.New("tpl").
+		Option("missingkey=zero").
+		Funcs(template.FuncMap{
+			"env": func(name string) string { return os.Getenv(name) },
+			"orEmpty": func(v any) any {
+				if v == nil {
+					return ""
+				}
+				return v
+			},
+		}).
+		Parse(tpl)
+	if err != nil {
+		return "", fmt.Errorf("error parseando template string: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error ejecutando template string: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
 // RenderTemplateMap procesa UN MAP de strings.
 // Sirve para el body de las tools, por ejemplo:
 //
@@ -94,3 +174,28 @@ func DebugRender(label, tpl string, params map[string]string) {
 		log.Printf("[TEMPLATE][%s] %s => %s", label, tpl, out)
 	}
 }
+
+// sensitiveHeaderHints identifica cabeceras cuyo valor no debe mostrarse.
+var sensitiveHeaderHints = []string{"authorization", "cookie", "token", "secret", "key", "password"}
+
+// MaskHeaders devuelve una copia de las cabeceras con los secretos enmascarados,
+// para poder mostrar una petición renderizada sin filtrar credenciales.
+func MaskHeaders(h map[string]string) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		lk := strings.ToLower(k)
+		masked := false
+		for _, hint := range sensitiveHeaderHints {
+			if strings.Contains(lk, hint) {
+				masked = true
+				break
+			}
+		}
+		if masked && v != "" {
+			out[k] = "****"
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -2,6 +2,7 @@ package tools_test
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -121,6 +122,10 @@ func TestExecuteTool_HTTPErrorIncludesStatus(t *testing.T) {
  _, err := tools.ExecuteTool(tool, map[string]string{"x": "1"})
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "HTTP 500")
+
+	var httpErr *tools.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	require.Equal(t, http.StatusInternalServerError, httpErr.StatusCode)
 }
 
 func TestExecuteTool_HeadersWithEnv(t *testing.T) {
@@ -178,3 +183,79 @@ func TestExecuteTool_HeadersWithAPIKeyEnv(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "Bearer banking-abc-123", gotAuth)
 }
+
+func TestRenderRequest_DoesNotCallAndRendersAllParts(t *testing.T) {
+	t.Setenv("API_TOKEN", "secret123")
+
+	tool := config.Tool{
+		Name:   "payments_bizum_send",
+		Method: "POST",
+		URL:    "http://payments.local/bizum?to={{ .toPhone }}",
+		Headers: map[string]string{
+			"Authorization": "Bearer {{ env \"API_TOKEN\" }}",
+			"X-Channel":     "aos",
+		},
+		Body: map[string]string{"amount": "{{ .amount }}"},
+	}
+
+	rr, err := tools.RenderRequest(tool, map[string]string{"toPhone": "600111222", "amount": "25"})
+	require.NoError(t, err)
+	require.Equal(t, "POST", rr.Method)
+	require.Equal(t, "http://payments.local/bizum?to=600111222", rr.URL)
+	require.Equal(t, "25", rr.Body["amount"])
+	require.Equal(t, "Bearer secret123", rr.Headers["Authorization"])
+
+	masked := tools.MaskHeaders(rr.Headers)
+	require.Equal(t, "****", masked["Authorization"])
+	require.Equal(t, "aos", masked["X-Channel"])
+	// original map must not be modified
+	require.Equal(t, "Bearer secret123", rr.Headers["Authorization"])
+}
+
+func TestRenderRequestWithSteps_ExposesPreviousOutputs(t *testing.T) {
+	tool := config.Tool{
+		Name:   "crm_add_note",
+		Method: "POST",
+		URL:    "http://crm.local/tickets/{{ .steps.crm_create_ticket.ticketId }}/notes",
+		Body: map[string]string{
+			"text":   "{{ .text }}",
+			"risk":   `{{ (index .steps "banking.aml_risk_check").riskLevel }}`,
+			"absent": "{{ .steps.crm_create_ticket.missing | orEmpty }}",
+			"note":   "{{ .note }}",
+		},
+	}
+	steps := map[string]any{
+		"crm_create_ticket":      map[string]any{"ticketId": "T-42"},
+		"banking.aml_risk_check": map[string]any{"riskLevel": "LOW"},
+	}
+
+	rr, err := tools.RenderRequestWithSteps(tool, map[string]string{"text": "hola"}, steps)
+	require.NoError(t, err)
+	require.Equal(t, "http://crm.local/tickets/T-42/notes", rr.URL)
+	require.Equal(t, "hola", rr.Body["text"])
+	require.Equal(t, "LOW", rr.Body["risk"])
+	require.Equal(t, "", rr.Body["absent"])
+	require.Equal(t, "", rr.Body["note"], "a missing param renders empty")
+}
+
+func TestRenderRequestWithSteps_KeepsLiteralNoValueText(t *testing.T) {
+	tool := config.Tool{
+		Name:   "crm_add_note",
+		Method: "POST",
+		URL:    "http://crm.local/notes",
+		Body: map[string]string{
+			"text":   "{{ .text }}",
+			"status": "{{ .steps.crm_create_ticket.status }}",
+			"owner":  "{{ with .steps.crm_create_ticket }}{{ .owner }}{{ end }}",
+		},
+	}
+	steps := map[string]any{
+		"crm_create_ticket": map[string]any{"status": "<no value>", "owner": nil},
+	}
+
+	rr, err := tools.RenderRequestWithSteps(tool, map[string]string{"text": "dice <no value>"}, steps)
+	require.NoError(t, err)
+	require.Equal(t, "dice <no value>", rr.Body["text"])
+	require.Equal(t, "<no value>", rr.Body["status"])
+	require.Equal(t, "", rr.Body["owner"], "a null output renders empty")
+}
@@ -10,16 +10,18 @@ import (
 )
 
 type Event struct {
-    Time     time.Time
-    Agent    string
-    Kind     string
-    Message  string
-    Duration string
+    Seq      int       `json:"seq"` // posición en la timeline del task, desde 1
+    Time     time.Time `json:"time"`
+    Agent    string    `json:"agent"`
+    Kind     string    `json:"kind"`
+    Message  string    `json:"message"`
+    Duration string    `json:"duration,omitempty"`
 }
 
 type UIStore struct {
-    mu    sync.RWMutex
-    tasks map[string][]Event
+    mu        sync.RWMutex
+    tasks     map[string][]Event
+    listeners []func(taskID string, ev Event)
 }
 
 func NewUIStore() *UIStore {
@@ -31,9 +33,9 @@ func NewUIStore() *UIStore {
 // AddEvent registra un evento para un task.
 func (s *UIStore) AddEvent(taskID, agent, kind, msg, duration string) {
     s.mu.Lock()
-    defer s.mu.Unlock()
 
     ev := Event{
+        Seq:      len(s.tasks[taskID]) + 1,
         Time:     time.Now(),
         Agent:    agent,
         Kind:     kind,
@@ -41,6 +43,27 @@ func (s *UIStore) AddEvent(taskID, agent, kind, msg, duration string) {
         Duration: duration,
     }
     s.tasks[taskID] = append(s.tasks[taskID], ev)
+    listeners := s.listeners
+    s.mu.Unlock()
+
+    // Los listeners se llaman fuera del lock para que puedan leer el store.
+    for _, fn := range listeners {
+        fn(taskID, ev)
+    }
+}
+
+// OnEvent registra una función que recibe cada evento en cuanto se añade.
+func (s *UIStore) OnEvent(fn func(taskID string, ev Event)) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.listeners = append(s.listeners, fn)
+}
+
+// Events devuelve una copia de los eventos de un task, en orden.
+func (s *UIStore) Events(taskID string) []Event {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    return append([]Event(nil), s.tasks[taskID]...)
 }
 
 // snapshot devuelve una copia segura de los datos.
@@ -116,3 +116,26 @@ func TestHandleTask_OK(t *testing.T) {
         t.Fatalf("expected event messages in body, got: %s", body)
     }
 }
+
+func TestUIStore_OnEventAndEvents(t *testing.T) {
+    s := NewUIStore()
+    var got []string
+    s.OnEvent(func(taskID string, ev Event) {
+        got = append(got, taskID+":"+ev.Message)
+    })
+    s.AddEvent("task1", "agentA", "info", "hello", "")
+    s.AddEvent("task2", "agentA", "info", "world", "")
+
+    if len(got) != 2 || got[0] != "task1:hello" || got[1] != "task2:world" {
+        t.Fatalf("listener should receive every event, got %v", got)
+    }
+    s.AddEvent("task1", "agentA", "info", "again", "")
+    evs := s.Events("task1")
+    if len(evs) != 2 || evs[0].Message != "hello" || evs[0].Seq != 1 || evs[1].Seq != 2 {
+        t.Fatalf("expected task1 events numbered from 1, got %+v", evs)
+    }
+    evs[0].Message = "hacked"
+    if s.Events("task1")[0].Message != "hello" {
+        t.Fatalf("Events must return a copy")
+    }
+}